@@ -0,0 +1,161 @@
+package live_sdk_go
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// MinAudioLevel is the level of digital silence, in -dBov
+	MinAudioLevel = 127
+
+	defaultAudioLevelWindow      = 200 * time.Millisecond
+	defaultVoiceActivityLevel    = 45
+	defaultVoiceActivityHangover = 300 * time.Millisecond
+)
+
+type AudioLevelMeterOptions struct {
+	// Window is the duration levels are smoothed over, 200ms when 0
+	Window time.Duration
+	// VoiceActivityLevel is the level, in -dBov, audio at least as loud as is considered voice. 45 when 0
+	VoiceActivityLevel uint8
+	// VoiceActivityHangover is how long voice activity lasts after the level drops, so pauses between words don't
+	// interrupt it. 300ms when 0
+	VoiceActivityHangover time.Duration
+}
+
+// AudioLevelMeter measures the level of audio in -dBov, as carried by the RFC 6464 header extension (0 is the loudest,
+// 127 silence), smoothed over a window. Levels are computed from PCM frames, or supplied by the caller when the audio
+// is encoded elsewhere.
+// It implements CurrentAudioLevel and CurrentVoiceActivity, so it can be embedded into an AudioSampleProvider
+type AudioLevelMeter struct {
+	opts AudioLevelMeterOptions
+
+	lock   sync.Mutex
+	frames []audioLevelFrame
+	level  uint8
+	voice  bool
+	// duration of audio since the level was last above VoiceActivityLevel
+	sinceVoice time.Duration
+}
+
+type audioLevelFrame struct {
+	// mean square of the samples, relative to full scale
+	power    float64
+	duration time.Duration
+}
+
+func NewAudioLevelMeter(opts AudioLevelMeterOptions) *AudioLevelMeter {
+	if opts.Window <= 0 {
+		opts.Window = defaultAudioLevelWindow
+	}
+	if opts.VoiceActivityLevel == 0 {
+		opts.VoiceActivityLevel = defaultVoiceActivityLevel
+	}
+	if opts.VoiceActivityHangover <= 0 {
+		opts.VoiceActivityHangover = defaultVoiceActivityHangover
+	}
+	return &AudioLevelMeter{
+		opts:       opts,
+		level:      MinAudioLevel,
+		sinceVoice: opts.VoiceActivityHangover,
+	}
+}
+
+// WritePCM measures a frame of 16 bit PCM samples lasting duration, 20ms when 0
+func (m *AudioLevelMeter) WritePCM(pcm []int16, duration time.Duration) {
+	if len(pcm) == 0 {
+		return
+	}
+	sum := 0.0
+	for _, s := range pcm {
+		v := float64(s) / -math.MinInt16
+		sum += v * v
+	}
+	m.write(sum/float64(len(pcm)), false, duration)
+}
+
+// WriteLevel adds a level, in -dBov, measured over duration, 20ms when 0
+func (m *AudioLevelMeter) WriteLevel(level uint8, duration time.Duration) {
+	m.WriteVoiceLevel(level, false, duration)
+}
+
+// WriteVoiceLevel adds a level like WriteLevel, along with the voice activity flag of the RFC 6464 header extension.
+// The audio counts as voice when the flag is set, whatever its level
+func (m *AudioLevelMeter) WriteVoiceLevel(level uint8, voice bool, duration time.Duration) {
+	power := 0.0
+	if level < MinAudioLevel {
+		power = math.Pow(10, -float64(level)/10)
+	}
+	m.write(power, voice, duration)
+}
+
+func (m *AudioLevelMeter) write(power float64, voice bool, duration time.Duration) {
+	if duration <= 0 {
+		duration = defaultOpusFrameDuration
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.frames = append(m.frames, audioLevelFrame{power: power, duration: duration})
+	var total time.Duration
+	for _, f := range m.frames {
+		total += f.duration
+	}
+	// drop the frames out of the window, keeping the last one
+	for len(m.frames) > 1 && total-m.frames[0].duration >= m.opts.Window {
+		total -= m.frames[0].duration
+		m.frames = m.frames[1:]
+	}
+
+	energy := 0.0
+	for _, f := range m.frames {
+		energy += f.power * float64(f.duration)
+	}
+	m.level = powerToAudioLevel(energy / float64(total))
+
+	if voice || m.level <= m.opts.VoiceActivityLevel {
+		m.sinceVoice = 0
+	} else if m.sinceVoice < m.opts.VoiceActivityHangover {
+		m.sinceVoice += duration
+	}
+	m.voice = m.sinceVoice < m.opts.VoiceActivityHangover
+}
+
+// CurrentAudioLevel returns the smoothed level in -dBov, MinAudioLevel before any audio was measured
+func (m *AudioLevelMeter) CurrentAudioLevel() uint8 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.level
+}
+
+// CurrentVoiceActivity returns true while the audio is loud enough to be voice
+func (m *AudioLevelMeter) CurrentVoiceActivity() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.voice
+}
+
+// audioLevelToLinear converts a level in -dBov to an amplitude between 0 and 1, like the levels of speakers
+func audioLevelToLinear(level uint8) float32 {
+	if level >= MinAudioLevel {
+		return 0
+	}
+	return float32(math.Pow(10, -float64(level)/20))
+}
+
+func powerToAudioLevel(power float64) uint8 {
+	if power <= 0 {
+		return MinAudioLevel
+	}
+	level := math.Round(-10 * math.Log10(power))
+	switch {
+	case level < 0:
+		return 0
+	case level > MinAudioLevel:
+		return MinAudioLevel
+	}
+	return uint8(level)
+}
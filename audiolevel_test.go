@@ -0,0 +1,116 @@
+package live_sdk_go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+)
+
+func sineFrame(amplitude float64, samples int) []int16 {
+	pcm := make([]int16, samples)
+	for i := range pcm {
+		pcm[i] = int16(amplitude * math.MaxInt16 * math.Sin(2*math.Pi*float64(i)/16))
+	}
+	return pcm
+}
+
+func TestAudioLevelMeter(t *testing.T) {
+	frame := 20 * time.Millisecond
+
+	t.Run("levels", func(t *testing.T) {
+		for _, tc := range []struct {
+			pcm   []int16
+			level uint8
+		}{
+			{[]int16{math.MaxInt16, math.MinInt16}, 0},
+			{sineFrame(1, 160), 3},
+			{sineFrame(0.01, 160), 43},
+			{make([]int16, 160), MinAudioLevel},
+		} {
+			m := NewAudioLevelMeter(AudioLevelMeterOptions{})
+			require.Equal(t, uint8(MinAudioLevel), m.CurrentAudioLevel())
+			m.WritePCM(tc.pcm, frame)
+			require.Equal(t, tc.level, m.CurrentAudioLevel())
+		}
+	})
+
+	t.Run("smoothing", func(t *testing.T) {
+		m := NewAudioLevelMeter(AudioLevelMeterOptions{Window: 100 * time.Millisecond})
+		for i := 0; i < 5; i++ {
+			m.WriteLevel(30, frame)
+		}
+		require.Equal(t, uint8(30), m.CurrentAudioLevel())
+
+		// the level decays as silence fills the window
+		last := m.CurrentAudioLevel()
+		for i := 0; i < 4; i++ {
+			m.WriteLevel(MinAudioLevel, frame)
+			require.Greater(t, m.CurrentAudioLevel(), last)
+			last = m.CurrentAudioLevel()
+		}
+		m.WriteLevel(MinAudioLevel, frame)
+		require.Equal(t, uint8(MinAudioLevel), m.CurrentAudioLevel())
+	})
+
+	t.Run("voice activity", func(t *testing.T) {
+		m := NewAudioLevelMeter(AudioLevelMeterOptions{
+			Window:                frame,
+			VoiceActivityLevel:    40,
+			VoiceActivityHangover: 60 * time.Millisecond,
+		})
+		m.WriteLevel(50, frame)
+		require.False(t, m.CurrentVoiceActivity())
+		m.WriteLevel(40, frame)
+		require.True(t, m.CurrentVoiceActivity())
+
+		// lasts for the hangover after the level drops
+		for i := 0; i < 2; i++ {
+			m.WriteLevel(60, frame)
+			require.True(t, m.CurrentVoiceActivity())
+		}
+		m.WriteLevel(60, frame)
+		require.False(t, m.CurrentVoiceActivity())
+
+		// the voice flag of the sender counts as activity, whatever the level
+		m.WriteVoiceLevel(60, true, frame)
+		require.True(t, m.CurrentVoiceActivity())
+		require.Equal(t, uint8(60), m.CurrentAudioLevel())
+	})
+}
+
+func TestG711SampleProviderLevel(t *testing.T) {
+	pcm := &bytes.Buffer{}
+	require.NoError(t, binary.Write(pcm, binary.LittleEndian, sineFrame(0.1, 8000)))
+	require.NoError(t, binary.Write(pcm, binary.LittleEndian, make([]int16, 16000)))
+	reader, err := sdkcodecs.NewPCMReader(pcm, sdkcodecs.PCMFormat{SampleRate: 16000, Channels: 1})
+	require.NoError(t, err)
+	provider, err := NewG711SampleProvider(reader, webrtc.MimeTypePCMU)
+	require.NoError(t, err)
+
+	var provided AudioSampleProvider = provider
+	_, ok := provided.(VoiceActivitySampleProvider)
+	require.True(t, ok)
+
+	// half a second of tone
+	for i := 0; i < 25; i++ {
+		_, err := provider.NextSample()
+		require.NoError(t, err)
+	}
+	require.InDelta(t, 23, provider.CurrentAudioLevel(), 1)
+	require.True(t, provider.CurrentVoiceActivity())
+
+	// followed by silence, longer than the window and the hangover
+	for i := 0; i < 30; i++ {
+		_, err := provider.NextSample()
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint8(MinAudioLevel), provider.CurrentAudioLevel())
+	require.False(t, provider.CurrentVoiceActivity())
+}
@@ -2,7 +2,11 @@ package live_sdk_go
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/twitchtv/twirp"
@@ -14,7 +18,7 @@ type authBase struct {
 }
 
 func (b authBase) withAuth(ctx context.Context, grant auth.VideoGrant) (context.Context, error) {
-	at := auth.NewAccessToken(b.apiSecret, b.apiSecret)
+	at := auth.NewAccessToken(b.apiKey, b.apiSecret)
 	at.AddGrant(&grant)
 	token, err := at.ToJWT()
 	if err != nil {
@@ -29,3 +33,26 @@ func newHeaderWithToken(token string) http.Header {
 	header.Set("Authorization", "Bearer "+token)
 	return header
 }
+
+// TokenProvider returns a fresh access token for the room. It's called before every resume or restart,
+// and when the current token is about to expire
+type TokenProvider func(ctx context.Context) (string, error)
+
+// tokenExpiry returns when a JWT expires, without verifying it
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Expiry int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Expiry == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Expiry, 0), true
+}
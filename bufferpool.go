@@ -0,0 +1,19 @@
+package live_sdk_go
+
+import "sync"
+
+// packetBufferPool holds the buffers packets are read into, shared by the tracks of all rooms
+var packetBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, rtpInboundMTU)
+		return &b
+	},
+}
+
+func getPacketBuffer() *[]byte {
+	return packetBufferPool.Get().(*[]byte)
+}
+
+func putPacketBuffer(b *[]byte) {
+	packetBufferPool.Put(b)
+}
@@ -31,6 +31,13 @@ type ParticipantCallback struct {
 	OnTrackUnpublished func(publication *RemoteTrackPublication, rp *RemoteParticipant)
 	// 收到数据通知
 	OnDataReceived func(data []byte, rp *RemoteParticipant)
+	// OnDataReceivedWithTopic is called along with OnDataReceived, topic is empty when the sender didn't set one
+	OnDataReceivedWithTopic func(data []byte, topic string, rp *RemoteParticipant)
+
+	// for local participant
+	// OnSubscribedQualityChanged is called when the qualities of a published track subscribers want change,
+	// layers that are disabled are paused until enabled again
+	OnSubscribedQualityChanged func(pub *LocalTrackPublication, qualities []*livekit.SubscribedQuality, lp *LocalParticipant)
 }
 
 func NewParticipantCallback() *ParticipantCallback {
@@ -46,6 +53,8 @@ func NewParticipantCallback() *ParticipantCallback {
 		OnTrackPublished:           func(publication *RemoteTrackPublication, rp *RemoteParticipant) {},
 		OnTrackUnpublished:         func(publication *RemoteTrackPublication, rp *RemoteParticipant) {},
 		OnDataReceived:             func(data []byte, rp *RemoteParticipant) {},
+		OnDataReceivedWithTopic:    func(data []byte, topic string, rp *RemoteParticipant) {},
+		OnSubscribedQualityChanged: func(pub *LocalTrackPublication, qualities []*livekit.SubscribedQuality, lp *LocalParticipant) {},
 	}
 }
 func (cb *ParticipantCallback) Merge(other *ParticipantCallback) {
@@ -82,16 +91,34 @@ func (cb *ParticipantCallback) Merge(other *ParticipantCallback) {
 	if other.OnDataReceived != nil {
 		cb.OnDataReceived = other.OnDataReceived
 	}
+	if other.OnDataReceivedWithTopic != nil {
+		cb.OnDataReceivedWithTopic = other.OnDataReceivedWithTopic
+	}
+	if other.OnSubscribedQualityChanged != nil {
+		cb.OnSubscribedQualityChanged = other.OnSubscribedQualityChanged
+	}
 }
 
 type RoomCallback struct {
-	OnDisconnected            func()
+	// OnDisconnected is called when the room is disconnected by the server or fails to reconnect, not on Disconnect
+	OnDisconnected func()
+	// OnDisconnectedWithReason is called along with OnDisconnected, reason tells a server kick,
+	// duplicate identity or room deletion apart from reconnect failure (JOIN_FAILURE).
+	// Unlike OnDisconnected, it's also called on Disconnect (CLIENT_INITIATED)
+	OnDisconnectedWithReason  func(reason livekit.DisconnectReason)
 	OnParticipantConnected    func(*RemoteParticipant)
 	OnParticipantDisconnected func(*RemoteParticipant)
 	OnActiveSpeakersChanged   func([]Participant)
 	OnRoomMetadataChanged     func(metadata string)
 	OnReconnecting            func()
 	OnReconnected             func()
+	// OnConnectionStateChanged is called on every connection state transition,
+	// reason is set when disconnected because of a failure or server leave, nil on local Disconnect
+	OnConnectionStateChanged func(state ConnectionState, reason error)
+	// OnStats is called periodically when enabled with WithStatsInterval
+	OnStats func(stats *RoomStats)
+	// OnTokenRefreshed is called when the server or the TokenProvider replaces the token used to reconnect
+	OnTokenRefreshed func(token string)
 
 	// participant events are sent to the room as well
 	ParticipantCallback
@@ -103,12 +130,16 @@ func NewRoomCallback() *RoomCallback {
 		ParticipantCallback: *pc,
 
 		OnDisconnected:            func() {},
+		OnDisconnectedWithReason:  func(reason livekit.DisconnectReason) {},
 		OnParticipantConnected:    func(participant *RemoteParticipant) {},
 		OnParticipantDisconnected: func(participant *RemoteParticipant) {},
 		OnActiveSpeakersChanged:   func(participants []Participant) {},
 		OnRoomMetadataChanged:     func(metadata string) {},
 		OnReconnecting:            func() {},
 		OnReconnected:             func() {},
+		OnConnectionStateChanged:  func(state ConnectionState, reason error) {},
+		OnStats:                   func(stats *RoomStats) {},
+		OnTokenRefreshed:          func(token string) {},
 	}
 }
 
@@ -120,6 +151,9 @@ func (cb *RoomCallback) Merge(other *RoomCallback) {
 	if other.OnDisconnected != nil {
 		cb.OnDisconnected = other.OnDisconnected
 	}
+	if other.OnDisconnectedWithReason != nil {
+		cb.OnDisconnectedWithReason = other.OnDisconnectedWithReason
+	}
 	if other.OnParticipantConnected != nil {
 		cb.OnParticipantConnected = other.OnParticipantConnected
 	}
@@ -138,5 +172,14 @@ func (cb *RoomCallback) Merge(other *RoomCallback) {
 	if other.OnReconnected != nil {
 		cb.OnReconnected = other.OnReconnected
 	}
+	if other.OnConnectionStateChanged != nil {
+		cb.OnConnectionStateChanged = other.OnConnectionStateChanged
+	}
+	if other.OnStats != nil {
+		cb.OnStats = other.OnStats
+	}
+	if other.OnTokenRefreshed != nil {
+		cb.OnTokenRefreshed = other.OnTokenRefreshed
+	}
 	cb.ParticipantCallback.Merge(&other.ParticipantCallback)
 }
@@ -0,0 +1,21 @@
+package live_sdk_go
+
+// ConnectionState is the state of the connection to the room
+type ConnectionState string
+
+const (
+	// ConnectionStateConnecting initial join in progress
+	ConnectionStateConnecting ConnectionState = "connecting"
+	// ConnectionStateConnected signal and primary peer connection are established
+	ConnectionStateConnected ConnectionState = "connected"
+	// ConnectionStateResuming reconnecting while keeping the existing session
+	ConnectionStateResuming ConnectionState = "resuming"
+	// ConnectionStateRestarting rejoining the room with a new session
+	ConnectionStateRestarting ConnectionState = "restarting"
+	// ConnectionStateDisconnected no longer connected, will not reconnect
+	ConnectionStateDisconnected ConnectionState = "disconnected"
+)
+
+func (s ConnectionState) String() string {
+	return string(s)
+}
@@ -0,0 +1,189 @@
+package live_sdk_go
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	// with chunking, payloads larger than this are split, which keeps each message well within the SCTP message size
+	maxDataPayloadSize = 15 * 1024
+
+	// topics starting with lk. are used internally, and never delivered to data callbacks
+	internalTopicPrefix = "lk."
+	dataChunkTopic      = "lk.chunk"
+
+	// message id, chunk index, chunk count and topic length
+	dataChunkHeaderSize = 8 + 4 + 4 + 2
+	maxDataChunkCount   = 4096
+	dataChunkTimeout    = 30 * time.Second
+)
+
+type DataPublishOption func(*dataPublishOptions)
+
+type dataPublishOptions struct {
+	kind                  livekit.DataPacket_Kind
+	topic                 string
+	destinationSids       []string
+	destinationIdentities []string
+	chunking              bool
+}
+
+// WithDataPublishReliable sends over the reliable data channel when true (the default), the lossy one otherwise.
+// Only reliable data can be larger than a single message
+func WithDataPublishReliable(reliable bool) DataPublishOption {
+	return func(o *dataPublishOptions) {
+		if reliable {
+			o.kind = livekit.DataPacket_RELIABLE
+		} else {
+			o.kind = livekit.DataPacket_LOSSY
+		}
+	}
+}
+
+// WithDataPublishTopic sets the topic of the packet, delivered to OnDataReceivedWithTopic
+func WithDataPublishTopic(topic string) DataPublishOption {
+	return func(o *dataPublishOptions) {
+		o.topic = topic
+	}
+}
+
+// WithDataPublishDestinationSids limits the recipients to these participants, everyone in the room receives the packet by default
+func WithDataPublishDestinationSids(sids ...string) DataPublishOption {
+	return func(o *dataPublishOptions) {
+		o.destinationSids = append(o.destinationSids, sids...)
+	}
+}
+
+// WithDataPublishDestinationIdentities limits the recipients to the participants with these identities
+func WithDataPublishDestinationIdentities(identities ...string) DataPublishOption {
+	return func(o *dataPublishOptions) {
+		o.destinationIdentities = append(o.destinationIdentities, identities...)
+	}
+}
+
+// WithDataPublishChunking splits reliable data larger than a single data channel message into lk.chunk packets,
+// lossy data that large fails with ErrDataTooLarge. Chunks are only reassembled by this SDK, other LiveKit SDKs
+// receive them as separate packets. Without it, data is sent as a single packet whatever its size
+func WithDataPublishChunking(enabled bool) DataPublishOption {
+	return func(o *dataPublishOptions) {
+		o.chunking = enabled
+	}
+}
+
+// encodeDataChunks splits data into chunk payloads, each carrying the original topic
+func encodeDataChunks(messageID uint64, topic string, data []byte) ([][]byte, error) {
+	chunkSize := maxDataPayloadSize - dataChunkHeaderSize - len(topic)
+	if chunkSize <= 0 {
+		return nil, ErrInvalidParameter
+	}
+	count := (len(data) + chunkSize - 1) / chunkSize
+	if count > maxDataChunkCount {
+		return nil, ErrDataTooLarge
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := make([]byte, dataChunkHeaderSize, dataChunkHeaderSize+len(topic)+end-i*chunkSize)
+		binary.BigEndian.PutUint64(chunk[0:], messageID)
+		binary.BigEndian.PutUint32(chunk[8:], uint32(i))
+		binary.BigEndian.PutUint32(chunk[12:], uint32(count))
+		binary.BigEndian.PutUint16(chunk[16:], uint16(len(topic)))
+		chunk = append(chunk, topic...)
+		chunk = append(chunk, data[i*chunkSize:end]...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+type dataChunk struct {
+	messageID uint64
+	index     uint32
+	count     uint32
+	topic     string
+	data      []byte
+}
+
+func decodeDataChunk(payload []byte) (*dataChunk, bool) {
+	if len(payload) < dataChunkHeaderSize {
+		return nil, false
+	}
+	c := &dataChunk{
+		messageID: binary.BigEndian.Uint64(payload[0:]),
+		index:     binary.BigEndian.Uint32(payload[8:]),
+		count:     binary.BigEndian.Uint32(payload[12:]),
+	}
+	topicLen := int(binary.BigEndian.Uint16(payload[16:]))
+	if len(payload) < dataChunkHeaderSize+topicLen || c.count == 0 || c.count > maxDataChunkCount || c.index >= c.count {
+		return nil, false
+	}
+	c.topic = string(payload[dataChunkHeaderSize : dataChunkHeaderSize+topicLen])
+	c.data = payload[dataChunkHeaderSize+topicLen:]
+	return c, true
+}
+
+type chunkedMessage struct {
+	chunks   [][]byte
+	received uint32
+	size     int
+	started  time.Time
+}
+
+// dataReassembler joins the chunks of the large messages sent by a participant
+type dataReassembler struct {
+	lock     sync.Mutex
+	messages map[uint64]*chunkedMessage
+}
+
+// push adds a chunk, and returns the complete message and its topic once all chunks have arrived
+func (r *dataReassembler) push(payload []byte) ([]byte, string, bool) {
+	c, ok := decodeDataChunk(payload)
+	if !ok {
+		return nil, "", false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	if r.messages == nil {
+		r.messages = make(map[uint64]*chunkedMessage)
+	}
+	for id, m := range r.messages {
+		if now.Sub(m.started) > dataChunkTimeout {
+			delete(r.messages, id)
+		}
+	}
+
+	m := r.messages[c.messageID]
+	if m == nil {
+		m = &chunkedMessage{
+			chunks:  make([][]byte, c.count),
+			started: now,
+		}
+		r.messages[c.messageID] = m
+	}
+	if int(c.count) != len(m.chunks) || m.chunks[c.index] != nil {
+		return nil, "", false
+	}
+	m.chunks[c.index] = append([]byte(nil), c.data...)
+	m.received++
+	m.size += len(c.data)
+	if m.received < c.count {
+		return nil, "", false
+	}
+
+	delete(r.messages, c.messageID)
+	data := make([]byte, 0, m.size)
+	for _, chunk := range m.chunks {
+		data = append(data, chunk...)
+	}
+	return data, c.topic, true
+}
@@ -0,0 +1,172 @@
+package live_sdk_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestDataChunks(t *testing.T) {
+	data := make([]byte, 3*maxDataPayloadSize+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	chunks, err := encodeDataChunks(7, "files", data)
+	require.NoError(t, err)
+	require.Len(t, chunks, 4)
+	for _, c := range chunks {
+		require.LessOrEqual(t, len(c), maxDataPayloadSize)
+	}
+
+	// chunks can arrive in any order
+	r := &dataReassembler{}
+	for _, i := range []int{2, 0, 3} {
+		_, _, complete := r.push(chunks[i])
+		require.False(t, complete)
+	}
+	// duplicates are ignored
+	_, _, complete := r.push(chunks[0])
+	require.False(t, complete)
+
+	message, topic, complete := r.push(chunks[1])
+	require.True(t, complete)
+	require.Equal(t, "files", topic)
+	require.Equal(t, data, message)
+	require.Empty(t, r.messages)
+
+	_, _, complete = r.push([]byte{1, 2, 3})
+	require.False(t, complete)
+}
+
+// connectRelayedRooms joins two participants that receive each other's data packets through the test server
+func connectRelayedRooms(t *testing.T, srv *testserver.Server, aliceCB, bobCB *RoomCallback) (*Room, *Room) {
+	var lock sync.Mutex
+	// forwards the packets published by a session to the other participant
+	relay := make(map[*testserver.Session]func(packet *livekit.DataPacket))
+	srv.OnDataPacket = func(s *testserver.Session, packet *livekit.DataPacket) {
+		lock.Lock()
+		forward := relay[s]
+		lock.Unlock()
+		if forward != nil {
+			forward(packet)
+		}
+	}
+
+	alice, aliceSess := connectTestRoom(t, srv, "alice", aliceCB)
+	bob, bobSess := connectTestRoom(t, srv, "bob", bobCB)
+	bobInfo, err := aliceSess.AddParticipant("bob")
+	require.NoError(t, err)
+	aliceInfo, err := bobSess.AddParticipant("alice")
+	require.NoError(t, err)
+
+	forwardTo := func(sess *testserver.Session, senderSid string) func(packet *livekit.DataPacket) {
+		return func(packet *livekit.DataPacket) {
+			packet = proto.Clone(packet).(*livekit.DataPacket)
+			user := packet.GetUser()
+			user.ParticipantSid = senderSid
+			user.DestinationSids = nil
+			_ = sess.SendData(packet)
+		}
+	}
+	lock.Lock()
+	relay[aliceSess] = forwardTo(bobSess, aliceInfo.Sid)
+	relay[bobSess] = forwardTo(aliceSess, bobInfo.Sid)
+	lock.Unlock()
+
+	require.Eventually(t, func() bool {
+		return alice.GetParticipantByIdentity("bob") != nil && bob.GetParticipantByIdentity("alice") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	// wait for the data channels in both directions
+	require.Eventually(t, func() bool {
+		_, err := alice.LocalParticipant.PerformRPC(context.Background(), "bob", "ping", "")
+		var rpcErr *RPCError
+		return errors.As(err, &rpcErr) && rpcErr.Code == RPCErrUnsupportedMethod
+	}, 10*time.Second, 100*time.Millisecond)
+	return alice, bob
+}
+
+func TestPublishDataTopicAndChunks(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	type received struct {
+		data  []byte
+		topic string
+	}
+	data := make(chan received, 10)
+	alice, _ := connectRelayedRooms(t, srv, nil, &RoomCallback{
+		ParticipantCallback: ParticipantCallback{
+			OnDataReceivedWithTopic: func(payload []byte, topic string, rp *RemoteParticipant) {
+				data <- received{data: payload, topic: topic}
+			},
+		},
+	})
+
+	require.NoError(t, alice.LocalParticipant.PublishDataPacket([]byte("hello"),
+		WithDataPublishTopic("chat"), WithDataPublishDestinationIdentities("bob")))
+	large := bytes.Repeat([]byte("0123456789"), 10*maxDataPayloadSize/10)
+	require.NoError(t, alice.LocalParticipant.PublishDataPacket(large, WithDataPublishTopic("files"), WithDataPublishChunking(true)))
+
+	select {
+	case r := <-data:
+		require.Equal(t, "chat", r.topic)
+		require.Equal(t, []byte("hello"), r.data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+	select {
+	case r := <-data:
+		require.Equal(t, "files", r.topic)
+		require.Equal(t, large, r.data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no chunked data received")
+	}
+
+	// internal topics aren't delivered
+	require.NoError(t, alice.LocalParticipant.PublishDataPacket([]byte("internal"), WithDataPublishTopic("lk.test")))
+	require.NoError(t, alice.LocalParticipant.PublishDataPacket([]byte("after"), WithDataPublishTopic("chat")))
+	select {
+	case r := <-data:
+		require.Equal(t, "chat", r.topic)
+		require.Equal(t, []byte("after"), r.data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+
+	require.ErrorIs(t, alice.LocalParticipant.PublishDataPacket(large, WithDataPublishReliable(false), WithDataPublishChunking(true)), ErrDataTooLarge)
+	require.ErrorIs(t, alice.LocalParticipant.PublishDataPacket([]byte("hello"), WithDataPublishDestinationIdentities("carol")), ErrParticipantNotFound)
+}
+
+func TestPublishDataSinglePacket(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	packets := make(chan *livekit.DataPacket, 10)
+	srv.OnDataPacket = func(s *testserver.Session, packet *livekit.DataPacket) {
+		packets <- packet
+	}
+	room, _ := connectTestRoom(t, srv, "bot", nil)
+
+	// data larger than a chunk is only chunked when asked for
+	data := bytes.Repeat([]byte("0123456789"), 2*maxDataPayloadSize/10)
+	require.NoError(t, room.LocalParticipant.PublishData(data, livekit.DataPacket_RELIABLE, nil))
+	require.NoError(t, room.LocalParticipant.PublishDataPacket(data, WithDataPublishTopic("files")))
+	for _, topic := range []string{"", "files"} {
+		select {
+		case packet := <-packets:
+			require.Equal(t, topic, packet.GetUser().GetTopic())
+			require.Equal(t, data, packet.GetUser().Payload)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no data received")
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package live_sdk_go
 
 import (
+	"context"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
@@ -10,6 +11,9 @@ import (
 	"github.com/pion/webrtc/v3"
 
 	"github.com/livekit/protocol/livekit"
+
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
+	"github.com/liuhailove/live-sdk-go/pkg/metrics"
 )
 
 // ICE server：
@@ -27,58 +31,69 @@ import (
 // 如何区分呢?
 // 如果只需要实现1对1或者1对多通信, 我们只需要信令server及ICE server.
 // 如果要实现多对多通信, 则需要全部(也就是需要信令server, ICE server, webrtc server).
-//
 const (
 	reliableDataChannelName = "_reliable"
 	lossyDataChannelName    = "_lossy"
 
-	maxReconnectCount        = 10
-	initialReconnectInterval = 300 * time.Millisecond
-	maxReconnectInterval     = 60 * time.Second
+	tokenRefreshMargin = time.Minute
+	tokenRetryInterval = 5 * time.Second
 )
 
 type RTCEngine struct {
-	publisher          *PCTransport
-	subscriber         *PCTransport
-	client             *SignalClient
-	dclock             sync.RWMutex
-	reliableDC         *webrtc.DataChannel
-	lossyDC            *webrtc.DataChannel
-	reliableDCSub      *webrtc.DataChannel
-	lossyDCSub         *webrtc.DataChannel
+	publisher     *PCTransport
+	subscriber    *PCTransport
+	client        *SignalClient
+	dclock        sync.RWMutex
+	reliableDC    *webrtc.DataChannel
+	lossyDC       *webrtc.DataChannel
+	reliableDCSub *webrtc.DataChannel
+	lossyDCSub    *webrtc.DataChannel
+	// publishes waiting for the server, by track cid
+	pendingPublishLock sync.Mutex
+	pendingPublishes   map[string]chan *livekit.TrackPublishedResponse
 	trackPublishedChan chan *livekit.TrackPublishedResponse
 	subscriberPrimary  bool
 	hasConnected       atomic.Bool
 	hasPublish         atomic.Bool
 	closed             atomic.Bool
 	reconnecting       atomic.Bool
+	connectionState    atomic.String
 
-	url        string
+	// url of the region joined, resumes and restarts go back to it
+	url        atomic.String
+	region     atomic.String
 	token      atomic.String
 	connParams *ConnectParams
+	// refreshes the token ahead of its expiry when there is a TokenProvider
+	tokenLock  sync.Mutex
+	tokenTimer *time.Timer
 
 	JoinTimeout time.Duration
 
 	// callbacks
-	OnDisconnected          func()
-	OnMediaTrack            func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver)
-	OnParticipantUpdate     func([]*livekit.ParticipantInfo)
-	OnActiveSpeakersChanged func([]*livekit.SpeakerInfo)
-	OnDataReceived          func(userPacket *livekit.UserPacket)
-	OnConnectionQuality     func([]*livekit.ConnectionQualityInfo)
-	OnRoomUpdate            func(room *livekit.Room)
-	OnRestarting            func()
-	OnRestarted             func(response *livekit.JoinResponse)
-	OnResuming              func()
-	OnResumed               func()
+	OnDisconnected           func(reason livekit.DisconnectReason)
+	OnMediaTrack             func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver)
+	OnParticipantUpdate      func([]*livekit.ParticipantInfo)
+	OnActiveSpeakersChanged  func([]*livekit.SpeakerInfo)
+	OnDataReceived           func(userPacket *livekit.UserPacket)
+	OnConnectionQuality      func([]*livekit.ConnectionQualityInfo)
+	OnRoomUpdate             func(room *livekit.Room)
+	OnRestarting             func()
+	OnRestarted              func(response *livekit.JoinResponse)
+	OnResuming               func()
+	OnResumed                func()
+	OnConnectionStateChanged func(state ConnectionState, reason error)
+	OnTokenRefreshed         func(token string)
 }
 
 func NewRTCEngine() *RTCEngine {
 	e := &RTCEngine{
 		client:             NewSignalClient(),
+		pendingPublishes:   make(map[string]chan *livekit.TrackPublishedResponse),
 		trackPublishedChan: make(chan *livekit.TrackPublishedResponse, 1),
 		JoinTimeout:        15 * time.Second,
 	}
+	e.connectionState.Store(string(ConnectionStateDisconnected))
 
 	e.client.OnParticipantUpdate = func(info []*livekit.ParticipantInfo) {
 		if f := e.OnParticipantUpdate; f != nil {
@@ -103,7 +118,7 @@ func NewRTCEngine() *RTCEngine {
 	}
 	e.client.OnLeave = e.handleLeave
 	e.client.OnTokenRefresh = func(refreshToken string) {
-		e.token.Store(refreshToken)
+		e.setToken(refreshToken, true)
 	}
 	e.client.OnClose = func() {
 		e.handleDisconnect(false)
@@ -113,14 +128,38 @@ func NewRTCEngine() *RTCEngine {
 }
 
 func (e *RTCEngine) Join(url string, token string, params *ConnectParams) (*livekit.JoinResponse, error) {
-	res, err := e.client.Join(url, token, params)
+	return e.JoinContext(context.Background(), url, token, params)
+}
+
+// JoinContext joins the room, giving up when ctx is done or JoinTimeout elapses, whichever comes first
+func (e *RTCEngine) JoinContext(ctx context.Context, url string, token string, params *ConnectParams) (*livekit.JoinResponse, error) {
+	// restarts go through Join as well, their state is driven by handleDisconnect
+	initialJoin := !e.hasConnected.Load()
+	if initialJoin {
+		e.setConnectionState(ConnectionStateConnecting, nil)
+	}
+	start := time.Now()
+	res, err := e.join(ctx, url, token, params)
+	if initialJoin {
+		if err != nil {
+			e.setConnectionState(ConnectionStateDisconnected, err)
+		} else {
+			metrics.RecordJoin(time.Since(start))
+			e.setConnectionState(ConnectionStateConnected, nil)
+		}
+	}
+	return res, err
+}
+
+func (e *RTCEngine) join(ctx context.Context, url string, token string, params *ConnectParams) (*livekit.JoinResponse, error) {
+	res, url, err := e.joinSignal(ctx, url, token, params)
 	if err != nil {
 		return nil, err
 	}
 
-	e.url = url
-	e.token.Store(token)
+	e.url.Store(url)
 	e.connParams = params
+	e.setToken(token, false)
 
 	if err := e.configure(res); err != nil {
 		return nil, err
@@ -133,7 +172,7 @@ func (e *RTCEngine) Join(url string, token string, params *ConnectParams) (*live
 		e.publisher.Negotiate()
 	}
 
-	if err = e.waitUntilConnected(); err != nil {
+	if err = e.waitUntilConnected(ctx); err != nil {
 		return nil, err
 	}
 	e.hasConnected.Store(true)
@@ -152,6 +191,34 @@ func (e *RTCEngine) Close() {
 	}
 
 	e.client.Close()
+
+	e.tokenLock.Lock()
+	if e.tokenTimer != nil {
+		e.tokenTimer.Stop()
+	}
+	e.tokenLock.Unlock()
+}
+
+// ConnectionState returns the current state of the connection
+func (e *RTCEngine) ConnectionState() ConnectionState {
+	return ConnectionState(e.connectionState.Load())
+}
+
+func (e *RTCEngine) setConnectionState(state ConnectionState, reason error) {
+	if ConnectionState(e.connectionState.Swap(string(state))) == state {
+		return
+	}
+	logger.Infow("connection state changed", "state", state, "reason", reason)
+	if f := e.OnConnectionStateChanged; f != nil {
+		f(state, reason)
+	}
+}
+
+func (e *RTCEngine) reconnectPolicy() ReconnectPolicy {
+	if e.connParams != nil && e.connParams.ReconnectPolicy != nil {
+		return e.connParams.ReconnectPolicy
+	}
+	return NewDefaultReconnectPolicy()
 }
 
 func (e *RTCEngine) IsConnected() bool {
@@ -164,10 +231,46 @@ func (e *RTCEngine) IsConnected() bool {
 	return e.publisher.IsConnected()
 }
 
+// TrackPublishedChan receives the responses to published tracks, they are dropped while the channel is full.
+//
+// Deprecated: PublishTrack waits for the response to its own track, nothing has to be read from the channel
 func (e *RTCEngine) TrackPublishedChan() <-chan *livekit.TrackPublishedResponse {
 	return e.trackPublishedChan
 }
 
+// addTrack requests the server to publish a track, and waits for the response to its cid
+func (e *RTCEngine) addTrack(req *livekit.AddTrackRequest) (*livekit.TrackPublishedResponse, error) {
+	published := make(chan *livekit.TrackPublishedResponse, 1)
+	e.pendingPublishLock.Lock()
+	if _, ok := e.pendingPublishes[req.Cid]; ok {
+		e.pendingPublishLock.Unlock()
+		return nil, ErrTrackPublishing
+	}
+	e.pendingPublishes[req.Cid] = published
+	e.pendingPublishLock.Unlock()
+	defer func() {
+		e.pendingPublishLock.Lock()
+		delete(e.pendingPublishes, req.Cid)
+		e.pendingPublishLock.Unlock()
+	}()
+
+	err := e.client.SendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_AddTrack{
+			AddTrack: req,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-published:
+		return res, nil
+	case <-time.After(trackPublishTimeout):
+		return nil, ErrTrackPublishTimeout
+	}
+}
+
 func (e *RTCEngine) setRTT(rtt uint32) {
 	if pc := e.publisher; pc != nil {
 		pc.SetRTT(rtt)
@@ -181,14 +284,17 @@ func (e *RTCEngine) setRTT(rtt uint32) {
 func (e *RTCEngine) configure(res *livekit.JoinResponse) error {
 	iceServers := FromProtoIceServers(res.IceServers)
 	configuration := webrtc.Configuration{ICEServers: iceServers}
+	if e.connParams != nil {
+		configuration.ICETransportPolicy = e.connParams.ICETransportPolicy
+	}
 	if res.GetClientConfiguration().GetForceRelay() == livekit.ClientConfigSetting_ENABLED {
 		configuration.ICETransportPolicy = webrtc.ICETransportPolicyRelay
 	}
 	var err error
-	if e.publisher, err = NewPCTransport(configuration); err != nil {
+	if e.publisher, err = newPCTransport(configuration, e.connParams, false); err != nil {
 		return err
 	}
-	if e.subscriber, err = NewPCTransport(configuration); err != nil {
+	if e.subscriber, err = newPCTransport(configuration, e.connParams, true); err != nil {
 		return err
 	}
 
@@ -229,6 +335,12 @@ func (e *RTCEngine) configure(res *livekit.JoinResponse) error {
 		}
 	})
 
+	e.subscriber.pc.OnTrack(func(remote *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		if e.OnMediaTrack != nil {
+			e.OnMediaTrack(remote, receiver)
+		}
+	})
+
 	e.subscriber.pc.OnDataChannel(func(c *webrtc.DataChannel) {
 		e.dclock.Lock()
 		defer e.dclock.Unlock()
@@ -318,10 +430,12 @@ func (e *RTCEngine) GetDataChannelSub(kind livekit.DataPacket_Kind) *webrtc.Data
 	return e.lossyDCSub
 }
 
-func (e *RTCEngine) waitUntilConnected() error {
+func (e *RTCEngine) waitUntilConnected(ctx context.Context) error {
 	timeout := time.After(e.JoinTimeout)
 	for {
 		select {
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-timeout:
 			return ErrConnectionTimeout
 		case <-time.After(10 * time.Millisecond):
@@ -334,7 +448,7 @@ func (e *RTCEngine) waitUntilConnected() error {
 
 func (e *RTCEngine) ensurePublisherConnected(ensureDataReady bool) error {
 	if !e.subscriberPrimary {
-		return e.waitUntilConnected()
+		return e.waitUntilConnected(context.Background())
 	}
 
 	if e.publisher.IsConnected() && (!ensureDataReady || e.dataPubChannelReady()) {
@@ -365,7 +479,22 @@ func (e *RTCEngine) dataPubChannelReady() bool {
 }
 
 func (e *RTCEngine) handleLocalTrackPublished(res *livekit.TrackPublishedResponse) {
-	e.trackPublishedChan <- res
+	select {
+	case e.trackPublishedChan <- res:
+	default:
+	}
+
+	e.pendingPublishLock.Lock()
+	published := e.pendingPublishes[res.Cid]
+	e.pendingPublishLock.Unlock()
+	if published == nil {
+		logger.Debugw("track published response for unknown track", "cid", res.Cid)
+		return
+	}
+	select {
+	case published <- res:
+	default:
+	}
 }
 
 func (e *RTCEngine) handleDataPacket(msg webrtc.DataChannelMessage) {
@@ -373,6 +502,7 @@ func (e *RTCEngine) handleDataPacket(msg webrtc.DataChannelMessage) {
 	if err != nil {
 		return
 	}
+	metrics.RecordDataPacket(metrics.DirectionReceived, packet.Kind.String())
 	switch msg := packet.Value.(type) {
 	case *livekit.DataPacket_Speaker:
 		if e.OnActiveSpeakersChanged != nil {
@@ -407,47 +537,70 @@ func (e *RTCEngine) handleDisconnect(fullReconnect bool) {
 
 	go func() {
 		defer e.reconnecting.Store(false)
-		var reconnectCount int
-		for ; reconnectCount < maxReconnectCount; reconnectCount++ {
-			if fullReconnect {
-				if reconnectCount == 0 && e.OnRestarting != nil {
-					e.OnRestarting()
+		policy := e.reconnectPolicy()
+		start := time.Now()
+		notifiedResuming, notifiedRestarting := false, false
+		attempt := ReconnectAttempt{FullReconnect: fullReconnect}
+		for ; ; attempt.Attempt++ {
+			attempt.Elapsed = time.Since(start)
+			var err error
+			if policy.ShouldResume(attempt) {
+				e.setConnectionState(ConnectionStateResuming, nil)
+				if !notifiedResuming && e.OnResuming != nil {
+					e.OnResuming()
 				}
-				logger.Infow("restarting connection...", "reconnectCount", reconnectCount)
-				if err := e.restartConnection(); err != nil {
-					logger.Errorw("restart connection failed", err)
-				} else {
-					return
+				notifiedResuming = true
+				logger.Infow("resuming connection...", "reconnectCount", attempt.Attempt)
+				if err = e.resumeConnection(); err != nil {
+					logger.Errorw("resume connection failed", err)
 				}
+				metrics.RecordReconnect(metrics.ReconnectTypeResume, err == nil)
 			} else {
-				if reconnectCount == 0 && e.OnResuming != nil {
-					e.OnResuming()
+				e.setConnectionState(ConnectionStateRestarting, nil)
+				if !notifiedRestarting && e.OnRestarting != nil {
+					e.OnRestarting()
 				}
-				logger.Infow("resuming connection...", "reconnectCount", reconnectCount)
-				if err := e.resumeConnection(); err != nil {
-					logger.Errorw("resume connection failed", err)
-				} else {
-					return
+				notifiedRestarting = true
+				logger.Infow("restarting connection...", "reconnectCount", attempt.Attempt)
+				if err = e.restartConnection(); err != nil {
+					logger.Errorw("restart connection failed", err)
 				}
+				metrics.RecordReconnect(metrics.ReconnectTypeRestart, err == nil)
+			}
+			if err == nil {
+				return
+			}
+			if e.closed.Load() {
+				return
 			}
 
-			delay := time.Duration(reconnectCount*reconnectCount) * initialReconnectInterval
-			if delay > maxReconnectInterval {
+			attempt.LastError = err
+			attempt.Elapsed = time.Since(start)
+			delay, retry := policy.NextRetryDelay(attempt)
+			if !retry {
 				break
 			}
-			if reconnectCount < maxReconnectCount-1 {
-				time.Sleep(delay)
-			}
+			time.Sleep(delay)
 		}
 
+		e.setConnectionState(ConnectionStateDisconnected, ErrReconnectExhausted)
 		if e.OnDisconnected != nil {
-			e.OnDisconnected()
+			e.OnDisconnected(livekit.DisconnectReason_JOIN_FAILURE)
 		}
 	}()
 }
 
 func (e *RTCEngine) resumeConnection() error {
-	_, err := e.client.Join(e.url, e.token.Load(), &ConnectParams{Reconnect: true})
+	e.refreshToken()
+
+	// keeps the network settings of the join
+	params := &ConnectParams{Reconnect: true}
+	if e.connParams != nil {
+		params.Proxy = e.connParams.Proxy
+		params.TLSConfig = e.connParams.TLSConfig
+		params.RootCAs = e.connParams.RootCAs
+	}
+	_, err := e.client.Join(e.url.Load(), e.token.Load(), params)
 	if err != nil {
 		return err
 	}
@@ -461,10 +614,11 @@ func (e *RTCEngine) resumeConnection() error {
 		})
 	}
 
-	if err := e.waitUntilConnected(); err != nil {
+	if err := e.waitUntilConnected(context.Background()); err != nil {
 		return err
 	}
 
+	e.setConnectionState(ConnectionStateConnected, nil)
 	if e.OnResumed != nil {
 		e.OnResumed()
 	}
@@ -484,11 +638,13 @@ func (e *RTCEngine) restartConnection() error {
 		e.subscriber.Close()
 	}
 
-	res, err := e.Join(e.url, e.token.Load(), e.connParams)
+	e.refreshToken()
+	res, err := e.Join(e.url.Load(), e.token.Load(), e.connParams)
 	if err != nil {
 		return err
 	}
 
+	e.setConnectionState(ConnectionStateConnected, nil)
 	if e.OnRestarted != nil {
 		e.OnRestarted(res)
 	}
@@ -496,6 +652,73 @@ func (e *RTCEngine) restartConnection() error {
 	return nil
 }
 
+func (e *RTCEngine) keyProvider() *e2ee.KeyProvider {
+	if e.connParams != nil {
+		return e.connParams.KeyProvider
+	}
+	return nil
+}
+
+func (e *RTCEngine) tokenProvider() TokenProvider {
+	if e.connParams != nil {
+		return e.connParams.TokenProvider
+	}
+	return nil
+}
+
+// setToken stores the token used to reconnect, and schedules its refresh ahead of its expiry
+func (e *RTCEngine) setToken(token string, notify bool) {
+	if e.token.Swap(token) == token {
+		return
+	}
+	if notify && e.OnTokenRefreshed != nil {
+		e.OnTokenRefreshed(token)
+	}
+
+	e.tokenLock.Lock()
+	defer e.tokenLock.Unlock()
+	if e.tokenTimer != nil {
+		e.tokenTimer.Stop()
+		e.tokenTimer = nil
+	}
+	expiry, ok := tokenExpiry(token)
+	if !ok || e.tokenProvider() == nil || e.closed.Load() {
+		return
+	}
+	// a minute ahead, or halfway through for short lived tokens
+	ahead := time.Until(expiry) / 2
+	if ahead > tokenRefreshMargin {
+		ahead = tokenRefreshMargin
+	}
+	e.tokenTimer = time.AfterFunc(time.Until(expiry)-ahead, func() {
+		if !e.refreshToken() && time.Now().Before(expiry) && !e.closed.Load() {
+			e.tokenLock.Lock()
+			if e.token.Load() == token {
+				e.tokenTimer.Reset(tokenRetryInterval)
+			}
+			e.tokenLock.Unlock()
+		}
+	})
+}
+
+// refreshToken replaces the token with one from the TokenProvider, if any. The current token is kept when the
+// provider fails, it might still be valid
+func (e *RTCEngine) refreshToken() bool {
+	provider := e.tokenProvider()
+	if provider == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.JoinTimeout)
+	defer cancel()
+	token, err := provider(ctx)
+	if err != nil || token == "" {
+		logger.Warnw("could not refresh token", err)
+		return false
+	}
+	e.setToken(token, true)
+	return true
+}
+
 func (e *RTCEngine) createPublisherAnswerAndSend() error {
 	answer, err := e.subscriber.pc.CreateAnswer(nil)
 	if err != nil {
@@ -518,8 +741,9 @@ func (e *RTCEngine) handleLeave(leave *livekit.LeaveRequest) {
 		e.handleDisconnect(true)
 	} else {
 		logger.Infow("Leave room", "reason", leave.GetReason())
+		e.setConnectionState(ConnectionStateDisconnected, ErrLeaveRequested)
 		if e.OnDisconnected != nil {
-			e.OnDisconnected()
+			e.OnDisconnected(leave.GetReason())
 		}
 	}
 }
@@ -1,11 +1,15 @@
 package live_sdk_go
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrURLNotProvided           = errors.New("URL was not provided")
 	ErrConnectionTimeout        = errors.New("could not connect after timeout")
 	ErrTrackPublishTimeout      = errors.New("timed out publishing track")
+	ErrTrackPublishing          = errors.New("a track with the same id is being published")
 	ErrCannotDetermineMime      = errors.New("cannot determine mimetype from file extension")
 	ErrUnsupportedFileType      = errors.New("ReaderSampleProvider does not support this mime type")
 	ErrUnsupportedSimulcastKind = errors.New("simulcast is only supported for video")
@@ -14,4 +18,38 @@ var (
 	ErrInvalidParameter         = errors.New("invalid parameter")
 	ErrCannotConnectSignal      = errors.New("could not establish signal connection")
 	ErrCannotDialSignal         = errors.New("could not dial signal connection")
+	ErrUnauthorized             = errors.New("unauthorized")
+	ErrRoomNotFound             = errors.New("room not found")
+	ErrServerUnavailable        = errors.New("server unavailable")
+	ErrReconnectExhausted       = errors.New("gave up reconnecting")
+	ErrLeaveRequested           = errors.New("server requested to leave the room")
+	ErrTrackNotSubscribed       = errors.New("track is not subscribed")
+	ErrSampleReaderExists       = errors.New("track is already read by a sample reader")
+	ErrNoDepacketizerForCodec   = errors.New("no depacketizer for codec")
+	ErrParticipantNotFound      = errors.New("participant not found")
+	ErrDataTooLarge             = errors.New("data is too large to publish")
+	ErrRoomManagerClosed        = errors.New("room manager is closed")
+	ErrUnsupportedProxy         = errors.New("unsupported proxy")
+	ErrSignalTimeout            = errors.New("server did not answer pings on the signal connection")
+	ErrE2EEUnsupportedTrack     = errors.New("end-to-end encryption requires a LocalSampleTrack")
 )
+
+// JoinError is returned when the server rejects a signal connection.
+// It wraps one of ErrUnauthorized, ErrRoomNotFound, ErrServerUnavailable or ErrCannotConnectSignal,
+// so it can be matched with errors.Is
+type JoinError struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *JoinError) Error() string {
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Err.Error(), e.Message)
+}
+
+func (e *JoinError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,88 @@
+package live_sdk_go
+
+import (
+	"io"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+)
+
+const defaultG711FrameDuration = 20 * time.Millisecond
+
+// G711SampleProvider encodes PCM audio with G.711, µ-law (PCMU) or A-law (PCMA).
+// The audio is downmixed to mono and resampled to 8 kHz, its level is measured before encoding
+type G711SampleProvider struct {
+	BaseSampleProvider
+	Mime          string
+	FrameDuration time.Duration
+	LevelMeter    *AudioLevelMeter
+
+	reader    *sdkcodecs.PCMReader
+	resampler *sdkcodecs.Resampler
+}
+
+// NewG711SampleProvider creates a provider encoding the audio of reader, mime has to be webrtc.MimeTypePCMU or
+// webrtc.MimeTypePCMA
+func NewG711SampleProvider(reader *sdkcodecs.PCMReader, mime string) (*G711SampleProvider, error) {
+	if mime != webrtc.MimeTypePCMU && mime != webrtc.MimeTypePCMA {
+		return nil, ErrUnsupportedFileType
+	}
+	return &G711SampleProvider{
+		Mime:          mime,
+		FrameDuration: defaultG711FrameDuration,
+		LevelMeter:    NewAudioLevelMeter(AudioLevelMeterOptions{}),
+		reader:        reader,
+		resampler:     sdkcodecs.NewResampler(reader.Format().SampleRate, sdkcodecs.G711ClockRate),
+	}, nil
+}
+
+func (p *G711SampleProvider) NextSample() (media.Sample, error) {
+	format := p.reader.Format()
+	frames := int(p.FrameDuration.Seconds() * float64(format.SampleRate))
+	if frames <= 0 {
+		frames = 1
+	}
+
+	var pcm []int16
+	// resampling may not output any sample for very short frames
+	for len(pcm) == 0 {
+		samples, err := p.reader.ReadSamples(frames)
+		if err != nil {
+			return media.Sample{}, err
+		}
+		pcm = p.resampler.Resample(sdkcodecs.DownmixToMono(samples, format.Channels))
+	}
+
+	duration := time.Duration(len(pcm)) * time.Second / sdkcodecs.G711ClockRate
+	p.LevelMeter.WritePCM(pcm, duration)
+
+	var data []byte
+	if p.Mime == webrtc.MimeTypePCMA {
+		data = sdkcodecs.EncodePCMA(pcm)
+	} else {
+		data = sdkcodecs.EncodePCMU(pcm)
+	}
+	return media.Sample{
+		Data:     data,
+		Duration: duration,
+	}, nil
+}
+
+func (p *G711SampleProvider) CurrentAudioLevel() uint8 {
+	return p.LevelMeter.CurrentAudioLevel()
+}
+
+func (p *G711SampleProvider) CurrentVoiceActivity() bool {
+	return p.LevelMeter.CurrentVoiceActivity()
+}
+
+// newPCMReader reads raw PCM audio when its format is given, a WAV file otherwise
+func newPCMReader(in io.Reader, format *sdkcodecs.PCMFormat) (*sdkcodecs.PCMReader, error) {
+	if format != nil {
+		return sdkcodecs.NewPCMReader(in, *format)
+	}
+	return sdkcodecs.NewWAVReader(in)
+}
@@ -3,9 +3,14 @@ package live_sdk_go
 import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 	"sort"
+	"sync"
 	"time"
+
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
+	"github.com/liuhailove/live-sdk-go/pkg/metrics"
 )
 
 const (
@@ -15,6 +20,10 @@ const (
 type LocalParticipant struct {
 	baseParticipant
 	engine *RTCEngine
+
+	getParticipantByIdentity func(identity string) *RemoteParticipant
+	dataMessageID            atomic.Uint64
+	rpc                      rpcState
 }
 
 func newLocalParticipant(engine *RTCEngine, roomcallback *RoomCallback) *LocalParticipant {
@@ -24,7 +33,55 @@ func newLocalParticipant(engine *RTCEngine, roomcallback *RoomCallback) *LocalPa
 	}
 }
 
+// TrackToPublish is a track published with PublishTracks
+type TrackToPublish struct {
+	Track   webrtc.TrackLocal
+	Options *TrackPublicationOptions
+}
+
+// PublishTrack publishes a track to the room, it can be called concurrently
 func (p *LocalParticipant) PublishTrack(track webrtc.TrackLocal, opts *TrackPublicationOptions) (*LocalTrackPublication, error) {
+	pub, err := p.publishTrack(track, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.engine.publisher.Negotiate()
+
+	logger.Infow("published track", "name", pub.Name(), "source", pub.Source().String())
+
+	return pub, nil
+}
+
+// PublishTracks publishes several tracks at once, e.g. the microphone and camera, negotiating them in a single offer.
+// The publications are returned in the order of tracks. When some tracks fail, the error of the first is returned
+// along with the publications of the others, failed ones are nil
+func (p *LocalParticipant) PublishTracks(tracks []TrackToPublish) ([]*LocalTrackPublication, error) {
+	pubs := make([]*LocalTrackPublication, len(tracks))
+	errs := make([]error, len(tracks))
+	var wg sync.WaitGroup
+	for i, t := range tracks {
+		wg.Add(1)
+		go func(i int, t TrackToPublish) {
+			defer wg.Done()
+			pubs[i], errs[i] = p.publishTrack(t.Track, t.Options)
+		}(i, t)
+	}
+	wg.Wait()
+
+	p.engine.publisher.Negotiate()
+
+	for i, err := range errs {
+		if err != nil {
+			return pubs, err
+		}
+		logger.Infow("published track", "name", pubs[i].Name(), "source", pubs[i].Source().String())
+	}
+	return pubs, nil
+}
+
+// publishTrack publishes the track on the server and adds it to the publisher, without negotiating
+func (p *LocalParticipant) publishTrack(track webrtc.TrackLocal, opts *TrackPublicationOptions) (*LocalTrackPublication, error) {
 	if opts == nil {
 		opts = &TrackPublicationOptions{}
 	}
@@ -38,6 +95,11 @@ func (p *LocalParticipant) PublishTrack(track webrtc.TrackLocal, opts *TrackPubl
 		}
 	}
 
+	cryptor, err := p.newFrameCryptor(track)
+	if err != nil {
+		return nil, err
+	}
+
 	pub := NewLocalTrackPublication(kind, track, *opts, p.engine.client)
 	pub.OnRttUpdate(func(rtt uint32) {
 		p.engine.setRTT(rtt)
@@ -52,6 +114,12 @@ func (p *LocalParticipant) PublishTrack(track webrtc.TrackLocal, opts *TrackPubl
 		Height:     uint32(opts.VideoHeight),
 		DisableDtx: opts.DisableDTX,
 		Stereo:     opts.Stereo,
+		DisableRed: opts.DisableRED,
+	}
+	if cryptor != nil {
+		req.Encryption = livekit.Encryption_GCM
+		// redundant frames would be sent in the clear
+		req.DisableRed = true
 	}
 	if kind == TrackKindVideo {
 		// single layer
@@ -63,23 +131,16 @@ func (p *LocalParticipant) PublishTrack(track webrtc.TrackLocal, opts *TrackPubl
 			},
 		}
 	}
-	err := p.engine.client.SendRequest(&livekit.SignalRequest{
-		Message: &livekit.SignalRequest_AddTrack{
-			AddTrack: req,
-		},
-	})
+	pubRes, err := p.engine.addTrack(req)
 	if err != nil {
 		return nil, err
 	}
 
-	pubChan := p.engine.TrackPublishedChan()
-	var pubRes *livekit.TrackPublishedResponse
-
-	select {
-	case pubRes = <-pubChan:
-		break
-	case <-time.After(trackPublishTimeout):
-		return nil, ErrTrackPublishTimeout
+	if st, ok := track.(*LocalSampleTrack); ok {
+		if kind == TrackKindAudio {
+			st.SetRED(!req.DisableRed)
+		}
+		st.setCryptor(cryptor)
 	}
 
 	// add transceivers
@@ -90,18 +151,27 @@ func (p *LocalParticipant) PublishTrack(track webrtc.TrackLocal, opts *TrackPubl
 		return nil, err
 	}
 
-	pub.setSender(transceiver.Sender())
+	pub.setSender(transceiver.Sender(), p.engine.publisher)
 
 	pub.updateInfo(pubRes.Track)
 	p.addPublication(pub)
 
-	p.engine.publisher.Negotiate()
-
-	logger.Infow("published track", "name", opts.Name, "source", opts.Source.String())
-
 	return pub, nil
 }
 
+// newFrameCryptor returns the cryptor encrypting the frames of track when E2EE is enabled, nil otherwise
+func (p *LocalParticipant) newFrameCryptor(track webrtc.TrackLocal) (*e2ee.FrameCryptor, error) {
+	keyProvider := p.engine.keyProvider()
+	if keyProvider == nil {
+		return nil, nil
+	}
+	st, ok := track.(*LocalSampleTrack)
+	if !ok {
+		return nil, ErrE2EEUnsupportedTrack
+	}
+	return e2ee.NewFrameCryptor(keyProvider, p.Identity(), st.Codec().MimeType)
+}
+
 // PublishSimulcastTrack publishes up to three layers to the server
 func (p *LocalParticipant) PublishSimulcastTrack(tracks []*LocalSampleTrack, opts *TrackPublicationOptions) (*LocalTrackPublication, error) {
 	if len(tracks) == 0 {
@@ -139,30 +209,33 @@ func (p *LocalParticipant) PublishSimulcastTrack(tracks []*LocalSampleTrack, opt
 		layers = append(layers, st.videoLayer)
 	}
 
-	err := p.engine.client.SendRequest(&livekit.SignalRequest{
-		Message: &livekit.SignalRequest_AddTrack{
-			AddTrack: &livekit.AddTrackRequest{
-				Cid:    mainTrack.ID(),
-				Name:   opts.Name,
-				Source: opts.Source,
-				Type:   pub.Kind().ProtoType(),
-				Width:  mainTrack.videoLayer.Width,
-				Height: mainTrack.videoLayer.Height,
-				Layers: layers,
-			},
-		},
+	cryptors := make([]*e2ee.FrameCryptor, len(tracks))
+	for i, st := range tracks {
+		var err error
+		if cryptors[i], err = p.newFrameCryptor(st); err != nil {
+			return nil, err
+		}
+	}
+	encryption := livekit.Encryption_NONE
+	if cryptors[0] != nil {
+		encryption = livekit.Encryption_GCM
+	}
+
+	pubRes, err := p.engine.addTrack(&livekit.AddTrackRequest{
+		Cid:        mainTrack.ID(),
+		Name:       opts.Name,
+		Source:     opts.Source,
+		Type:       pub.Kind().ProtoType(),
+		Width:      mainTrack.videoLayer.Width,
+		Height:     mainTrack.videoLayer.Height,
+		Layers:     layers,
+		Encryption: encryption,
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	pubChan := p.engine.TrackPublishedChan()
-	var pubRes *livekit.TrackPublishedResponse
-	select {
-	case pubRes = <-pubChan:
-		break
-	case <-time.After(trackPublishTimeout):
-		return nil, ErrTrackPublishTimeout
+	for i, st := range tracks {
+		st.setCryptor(cryptors[i])
 	}
 
 	// add transceivers
@@ -178,7 +251,7 @@ func (p *LocalParticipant) PublishSimulcastTrack(tracks []*LocalSampleTrack, opt
 				return nil, err
 			}
 			sender = transceiver.Sender()
-			pub.setSender(sender)
+			pub.setSender(sender, p.engine.publisher)
 		} else {
 			if err = sender.AddEncoding(st); err != nil {
 				return nil, err
@@ -239,16 +312,64 @@ func (p *LocalParticipant) closeTracks() {
 }
 
 func (p *LocalParticipant) PublishData(data []byte, kind livekit.DataPacket_Kind, destinationSid []string) error {
+	return p.sendDataPacket(data, kind, "", destinationSid)
+}
+
+// PublishDataPacket publishes data reliably to everyone in the room, unless changed by the options.
+// Large data is only chunked with WithDataPublishChunking
+func (p *LocalParticipant) PublishDataPacket(data []byte, opts ...DataPublishOption) error {
+	options := &dataPublishOptions{kind: livekit.DataPacket_RELIABLE}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	destinationSids := options.destinationSids
+	for _, identity := range options.destinationIdentities {
+		rp := p.getParticipantByIdentity(identity)
+		if rp == nil {
+			return ErrParticipantNotFound
+		}
+		destinationSids = append(destinationSids, rp.SID())
+	}
+	if !options.chunking {
+		return p.sendDataPacket(data, options.kind, options.topic, destinationSids)
+	}
+	return p.publishDataChunks(data, options.kind, options.topic, destinationSids)
+}
+
+func (p *LocalParticipant) publishDataChunks(data []byte, kind livekit.DataPacket_Kind, topic string, destinationSids []string) error {
+	if len(data) <= maxDataPayloadSize {
+		return p.sendDataPacket(data, kind, topic, destinationSids)
+	}
+	if kind != livekit.DataPacket_RELIABLE {
+		return ErrDataTooLarge
+	}
+
+	chunks, err := encodeDataChunks(p.dataMessageID.Inc(), topic, data)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := p.sendDataPacket(chunk, kind, dataChunkTopic, destinationSids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *LocalParticipant) sendDataPacket(data []byte, kind livekit.DataPacket_Kind, topic string, destinationSids []string) error {
+	userPacket := &livekit.UserPacket{
+		// this is enforced on the server side, setting for completeness
+		ParticipantSid:  p.sid,
+		Payload:         data,
+		DestinationSids: destinationSids,
+	}
+	if topic != "" {
+		userPacket.Topic = &topic
+	}
 	packet := &livekit.DataPacket{
-		Kind: kind,
-		Value: &livekit.DataPacket_User{
-			User: &livekit.UserPacket{
-				// this is enforced on the server side, setting for completeness
-				ParticipantSid:  p.sid,
-				Payload:         data,
-				DestinationSids: destinationSid,
-			},
-		},
+		Kind:  kind,
+		Value: &livekit.DataPacket_User{User: userPacket},
 	}
 
 	if err := p.engine.ensurePublisherConnected(true); err != nil {
@@ -261,7 +382,11 @@ func (p *LocalParticipant) PublishData(data []byte, kind livekit.DataPacket_Kind
 		return err
 	}
 
-	return p.engine.GetDataChannel(kind).Send(encoded)
+	if err := p.engine.GetDataChannel(kind).Send(encoded); err != nil {
+		return err
+	}
+	metrics.RecordDataPacket(metrics.DirectionSent, kind.String())
+	return nil
 }
 
 func (p *LocalParticipant) UnpublishTrack(sid string) error {
@@ -277,6 +402,8 @@ func (p *LocalParticipant) UnpublishTrack(sid string) error {
 		return nil
 	}
 
+	pub.removeStats()
+
 	var err error
 	if localTrack, ok := pub.track.(webrtc.TrackLocal); ok {
 		for _, sender := range p.engine.publisher.pc.GetSenders() {
@@ -343,6 +470,20 @@ func (p *LocalParticipant) updateInfo(info *livekit.ParticipantInfo) {
 	}
 }
 
+// handleSubscribedQualityUpdate pauses the layers of a published track that no one subscribes to (dynacast)
+func (p *LocalParticipant) handleSubscribedQualityUpdate(update *livekit.SubscribedQualityUpdate) {
+	pub := p.getLocalPublication(update.TrackSid)
+	if pub == nil {
+		logger.Warnw("received subscribed quality update for unknown track", nil, "trackID", update.TrackSid)
+		return
+	}
+	logger.Debugw("subscribed quality changed", "trackID", update.TrackSid, "qualities", update.SubscribedQualities)
+	pub.setSubscribedQualities(update.SubscribedQualities)
+
+	p.Callback.OnSubscribedQualityChanged(pub, update.SubscribedQualities, p)
+	p.roomCallback.OnSubscribedQualityChanged(pub, update.SubscribedQualities, p)
+}
+
 func (p *LocalParticipant) getLocalPublication(sid string) *LocalTrackPublication {
 	if pub, ok := p.getPublication(sid).(*LocalTrackPublication); ok {
 		return pub
@@ -0,0 +1,268 @@
+package live_sdk_go
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func newTestAudioTrack(t *testing.T) *LocalSampleTrack {
+	track, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	require.NoError(t, err)
+	require.NoError(t, track.StartWrite(NewNullSampleProvider(16000), nil))
+	return track
+}
+
+func TestConcurrentPublishTrack(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	const numTracks = 6
+	// the first published is answered last
+	srv.PublishDelay = func(req *livekit.AddTrackRequest) time.Duration {
+		var i int
+		_, _ = fmt.Sscanf(req.Name, "track-%d", &i)
+		return time.Duration(numTracks-i) * 30 * time.Millisecond
+	}
+	room, sess := connectTestRoom(t, srv, "bot", nil)
+	defer room.Disconnect()
+
+	pubs := make([]*LocalTrackPublication, numTracks)
+	errs := make([]error, numTracks)
+	var wg sync.WaitGroup
+	for i := 0; i < numTracks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pubs[i], errs[i] = room.LocalParticipant.PublishTrack(newTestAudioTrack(t), &TrackPublicationOptions{
+				Name: fmt.Sprintf("track-%d", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	sids := make(map[string]string)
+	for _, ti := range sess.PublishedTracks() {
+		sids[ti.Name] = ti.Sid
+	}
+	require.Len(t, sids, numTracks)
+	for i, pub := range pubs {
+		require.NoError(t, errs[i])
+		require.Equal(t, fmt.Sprintf("track-%d", i), pub.Name())
+		require.Equal(t, sids[pub.Name()], pub.SID())
+	}
+	require.Len(t, room.LocalParticipant.Tracks(), numTracks)
+
+	// a cid can only be published once at a time
+	track := newTestAudioTrack(t)
+	srv.PublishDelay = func(req *livekit.AddTrackRequest) time.Duration {
+		return time.Second
+	}
+	published := make(chan error, 1)
+	go func() {
+		_, err := room.LocalParticipant.PublishTrack(track, nil)
+		published <- err
+	}()
+	// the first publish is pending once the server received its request
+	require.Eventually(t, func() bool {
+		for _, req := range sess.Requests() {
+			if req.GetAddTrack().GetCid() == track.ID() {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err := room.LocalParticipant.PublishTrack(track, nil)
+	require.ErrorIs(t, err, ErrTrackPublishing)
+	require.NoError(t, <-published)
+}
+
+func TestPublishTracks(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	srv.PublishDelay = func(req *livekit.AddTrackRequest) time.Duration {
+		if req.Type == livekit.TrackType_AUDIO {
+			return 100 * time.Millisecond
+		}
+		return 0
+	}
+	room, sess := connectTestRoom(t, srv, "bot", nil)
+	defer room.Disconnect()
+
+	video, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000})
+	require.NoError(t, err)
+	require.NoError(t, video.StartWrite(NewNullSampleProvider(100000), nil))
+
+	pubs, err := room.LocalParticipant.PublishTracks([]TrackToPublish{
+		{Track: newTestAudioTrack(t), Options: &TrackPublicationOptions{Name: "mic"}},
+		{Track: video, Options: &TrackPublicationOptions{Name: "camera"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, pubs, 2)
+	require.Equal(t, "mic", pubs[0].Name())
+	require.Equal(t, livekit.TrackSource_MICROPHONE, pubs[0].Source())
+	require.Equal(t, "camera", pubs[1].Name())
+	require.Equal(t, livekit.TrackSource_CAMERA, pubs[1].Source())
+
+	// both tracks are in the first offer with media
+	require.Eventually(t, func() bool {
+		for _, req := range sess.Requests() {
+			if offer := req.GetOffer(); offer != nil && strings.Contains(offer.Sdp, "a=sendonly") {
+				return strings.Contains(offer.Sdp, "m=audio") && strings.Contains(offer.Sdp, "m=video")
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// pausableProvider counts the samples pulled from it
+type pausableProvider struct {
+	NullSampleProvider
+	samples atomic.Int32
+	paused  atomic.Bool
+}
+
+func (p *pausableProvider) NextSample() (media.Sample, error) {
+	p.samples.Inc()
+	return p.NullSampleProvider.NextSample()
+}
+
+func (p *pausableProvider) OnPause() {
+	p.paused.Store(true)
+}
+
+func (p *pausableProvider) OnResume() {
+	p.paused.Store(false)
+}
+
+func TestSubscribedQualityUpdate(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	updates := make(chan []*livekit.SubscribedQuality, 2)
+	cb := NewRoomCallback()
+	cb.OnSubscribedQualityChanged = func(pub *LocalTrackPublication, qualities []*livekit.SubscribedQuality, lp *LocalParticipant) {
+		updates <- qualities
+	}
+	room, sess := connectTestRoom(t, srv, "bot", cb)
+	defer room.Disconnect()
+
+	var tracks []*LocalSampleTrack
+	providers := make(map[livekit.VideoQuality]*pausableProvider)
+	for _, layer := range []*livekit.VideoLayer{
+		{Quality: livekit.VideoQuality_LOW, Width: 320, Height: 180},
+		{Quality: livekit.VideoQuality_MEDIUM, Width: 640, Height: 360},
+		{Quality: livekit.VideoQuality_HIGH, Width: 1280, Height: 720},
+	} {
+		track, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+			WithSimulcast("camera", layer))
+		require.NoError(t, err)
+		provider := &pausableProvider{NullSampleProvider: *NewNullSampleProvider(100000)}
+		require.NoError(t, track.StartWrite(provider, nil))
+		providers[layer.Quality] = provider
+		tracks = append(tracks, track)
+	}
+	pub, err := room.LocalParticipant.PublishSimulcastTrack(tracks, &TrackPublicationOptions{Name: "camera"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		for _, p := range providers {
+			if p.samples.Load() == 0 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, sess.SendSubscribedQualityUpdate(pub.SID(),
+		&livekit.SubscribedQuality{Quality: livekit.VideoQuality_LOW, Enabled: true},
+		&livekit.SubscribedQuality{Quality: livekit.VideoQuality_MEDIUM, Enabled: true},
+		&livekit.SubscribedQuality{Quality: livekit.VideoQuality_HIGH, Enabled: false},
+	))
+	select {
+	case qualities := <-updates:
+		require.Len(t, qualities, 3)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnSubscribedQualityChanged not called")
+	}
+	high := providers[livekit.VideoQuality_HIGH]
+	require.True(t, pub.GetSimulcastTrack(livekit.VideoQuality_HIGH).IsPaused())
+	require.False(t, pub.GetSimulcastTrack(livekit.VideoQuality_LOW).IsPaused())
+	require.True(t, high.paused.Load())
+	require.False(t, providers[livekit.VideoQuality_LOW].paused.Load())
+
+	// the paused layer isn't pulled anymore, the others are
+	time.Sleep(100 * time.Millisecond)
+	highSamples := high.samples.Load()
+	lowSamples := providers[livekit.VideoQuality_LOW].samples.Load()
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, highSamples, high.samples.Load())
+	require.Greater(t, providers[livekit.VideoQuality_LOW].samples.Load(), lowSamples)
+
+	require.NoError(t, sess.SendSubscribedQualityUpdate(pub.SID(),
+		&livekit.SubscribedQuality{Quality: livekit.VideoQuality_HIGH, Enabled: true},
+	))
+	<-updates
+	require.False(t, high.paused.Load())
+	require.Eventually(t, func() bool {
+		return high.samples.Load() > highSamples
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPublishRED(t *testing.T) {
+	for _, disableRED := range []bool{false, true} {
+		srv := testserver.New()
+		received := make(chan *webrtc.TrackRemote, 1)
+		srv.OnTrack = func(s *testserver.Session, track *webrtc.TrackRemote) {
+			received <- track
+		}
+		room, _ := connectTestRoom(t, srv, "bot", nil)
+
+		track := newTestAudioTrack(t)
+		_, err := room.LocalParticipant.PublishTrack(track, &TrackPublicationOptions{Name: "mic", DisableRED: disableRED})
+		require.NoError(t, err)
+
+		var remote *webrtc.TrackRemote
+		select {
+		case remote = <-received:
+		case <-time.After(10 * time.Second):
+			t.Fatal("server did not receive published media")
+		}
+		require.Equal(t, !disableRED, track.IsRED())
+		if disableRED {
+			require.Equal(t, webrtc.MimeTypeOpus, remote.Codec().MimeType)
+		} else {
+			require.Equal(t, mimeTypeRED, remote.Codec().MimeType)
+			// packets carry the two previous frames once sent
+			var primaries [][]byte
+			for len(primaries) < 3 {
+				pkt, _, err := remote.ReadRTP()
+				require.NoError(t, err)
+				redundant, primary, err := sdkcodecs.UnmarshalRED(pkt.Payload)
+				require.NoError(t, err)
+				require.Equal(t, uint8(111), primary.PayloadType)
+				require.LessOrEqual(t, len(redundant), 2)
+				if len(redundant) == 2 && len(primaries) == 0 {
+					primaries = append(primaries, redundant[0].Data, redundant[1].Data)
+				}
+				if len(primaries) > 0 {
+					primaries = append(primaries, primary.Data)
+				}
+			}
+		}
+
+		room.Disconnect()
+		srv.Close()
+	}
+}
@@ -2,6 +2,7 @@ package live_sdk_go
 
 import (
 	"context"
+	"fmt"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/utils"
 	"github.com/pion/interceptor"
@@ -16,15 +17,24 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
 )
 
 const (
 	rtpOutboundMTU = 1200
 	rtpInboundMTU  = 1500
+	rtpHeaderSize  = 12
+
+	// number of previous frames carried by RED packets
+	redDistance = 2
 )
 
 type SampleWriteOptions struct {
 	AudioLevel *uint8
+	// VoiceActivity sets the voice flag of the audio level, if any
+	VoiceActivity bool
 }
 
 // LocalSampleTrack is a local track that simplifies writing samples.
@@ -49,6 +59,21 @@ type LocalSampleTrack struct {
 	videoLayer      *livekit.VideoLayer
 	onRTCP          func(packet rtcp.Packet)
 
+	// paused when no one subscribes to the layer, resume is closed when it's enabled again
+	paused bool
+	resume chan struct{}
+
+	// opus is sent as RED when enabled and negotiated, packets are then written to redTrack
+	redEnabled bool
+	redTrack   *webrtc.TrackLocalStaticRTP
+	redEncoder *sdkcodecs.REDEncoder
+
+	// frames are encrypted end-to-end when set. Parameter sets written on their own are held back until the next
+	// slice, encryptedTS makes IVs unique. Both are guarded by lock
+	cryptor     *e2ee.FrameCryptor
+	heldNALUs   []byte
+	encryptedTS uint32
+
 	cancelWrite func()
 	provider    SampleProvider
 	onBind      func()
@@ -140,19 +165,72 @@ func (s *LocalSampleTrack) IsBound() bool {
 	return s.bound.Load()
 }
 
+// SetRED sends opus frames as RED (RFC 2198) carrying the previous frames as redundancy, when negotiated.
+// It applies the next time the track is bound
+func (s *LocalSampleTrack) SetRED(enabled bool) {
+	s.lock.Lock()
+	s.redEnabled = enabled
+	s.lock.Unlock()
+}
+
+// IsRED returns true when the track is bound with RED
+func (s *LocalSampleTrack) IsRED() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.redEncoder != nil
+}
+
+func (s *LocalSampleTrack) setCryptor(cryptor *e2ee.FrameCryptor) {
+	s.lock.Lock()
+	s.cryptor = cryptor
+	s.lock.Unlock()
+}
+
+// IsEncrypted returns true when frames are encrypted end-to-end
+func (s *LocalSampleTrack) IsEncrypted() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.cryptor != nil
+}
+
 // Bind is an interface for TrackLocal, not for external consumption
 func (s *LocalSampleTrack) Bind(t webrtc.TrackLocalContext) (webrtc.RTPCodecParameters, error) {
-	codec, err := s.rtpTrack.Bind(t)
+	s.lock.RLock()
+	redEnabled := s.redEnabled
+	s.lock.RUnlock()
+
+	var redEncoder *sdkcodecs.REDEncoder
+	var redTrack *webrtc.TrackLocalStaticRTP
+	if redEnabled {
+		if red, primaryPT, ok := redCodecFor(s.rtpTrack.Codec(), t.CodecParameters()); ok {
+			var err error
+			if redTrack, err = webrtc.NewTrackLocalStaticRTP(red.RTPCodecCapability, s.ID(), s.StreamID()); err != nil {
+				return webrtc.RTPCodecParameters{}, err
+			}
+			redEncoder = sdkcodecs.NewREDEncoder(primaryPT, redDistance, rtpOutboundMTU-rtpHeaderSize)
+		}
+	}
+
+	var codec webrtc.RTPCodecParameters
+	var err error
+	if redTrack != nil {
+		codec, err = redTrack.Bind(t)
+	} else {
+		codec, err = s.rtpTrack.Bind(t)
+	}
 	if err != nil {
 		return codec, err
 	}
 
-	payloader, err := payloaderForCodec(codec.RTPCodecCapability)
+	// packets of the primary codec are wrapped into RED when writing
+	payloader, err := payloaderForCodec(s.rtpTrack.Codec())
 	if err != nil {
 		return codec, err
 	}
 
 	s.lock.Lock()
+	s.redTrack = redTrack
+	s.redEncoder = redEncoder
 	s.ssrc = t.SSRC()
 	for _, ext := range t.HeaderExtensions() {
 		if ext.URI == sdp.AudioLevelURI {
@@ -202,6 +280,12 @@ func (s *LocalSampleTrack) Unbind(t webrtc.TrackLocalContext) error {
 	onUnbind := s.onUnbind
 	s.bound.Store(false)
 	cancel := s.cancelWrite
+	rtpTrack := s.rtpTrack
+	if s.redTrack != nil {
+		rtpTrack = s.redTrack
+	}
+	s.redTrack = nil
+	s.redEncoder = nil
 	s.lock.Unlock()
 
 	var err error
@@ -215,7 +299,7 @@ func (s *LocalSampleTrack) Unbind(t webrtc.TrackLocalContext) error {
 	if onUnbind != nil {
 		go onUnbind()
 	}
-	unbindErr := s.rtpTrack.Unbind(t)
+	unbindErr := rtpTrack.Unbind(t)
 	if unbindErr != nil {
 		return unbindErr
 	}
@@ -262,12 +346,53 @@ func (s *LocalSampleTrack) OnUnbind(f func()) {
 	s.lock.Unlock()
 }
 
+// IsPaused returns true when the server doesn't forward the layer because no one subscribes to it,
+// samples aren't pulled from the SampleProvider meanwhile
+func (s *LocalSampleTrack) IsPaused() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.paused
+}
+
+// setLayerEnabled pauses or resumes writing samples of the provider, a PausableSampleProvider is notified
+func (s *LocalSampleTrack) setLayerEnabled(enabled bool) {
+	s.lock.Lock()
+	if s.paused == !enabled {
+		s.lock.Unlock()
+		return
+	}
+	s.paused = !enabled
+	if s.paused {
+		s.resume = make(chan struct{})
+	} else {
+		close(s.resume)
+		s.resume = nil
+	}
+	provider := s.provider
+	s.lock.Unlock()
+
+	if p, ok := provider.(PausableSampleProvider); ok {
+		if enabled {
+			p.OnResume()
+		} else {
+			p.OnPause()
+		}
+	}
+}
+
 func (s *LocalSampleTrack) WriteSample(sample media.Sample, opts *SampleWriteOptions) error {
 	s.lock.RLock()
 	p := s.packetizer
 	clockRate := s.clockRate
 	transceiver := s.transceiver
 	ssrcAcked := s.ssrcAcked
+	rtpTrack := s.rtpTrack
+	redEncoder := s.redEncoder
+	if s.redTrack != nil {
+		rtpTrack = s.redTrack
+	}
+	cryptor := s.cryptor
+	ssrc := s.ssrc
 	s.lock.RUnlock()
 
 	if p == nil {
@@ -289,13 +414,30 @@ func (s *LocalSampleTrack) WriteSample(sample media.Sample, opts *SampleWriteOpt
 	if sample.PrevDroppedPackets > 0 {
 		p.SkipSamples(samples * uint32(sample.PrevDroppedPackets))
 	}
-	packets := p.Packetize(sample.Data, samples)
+	data := sample.Data
+	if cryptor != nil {
+		encrypted, err := s.encryptFrame(cryptor, data, ssrc, samples)
+		if err == e2ee.ErrNoSlice {
+			p.SkipSamples(samples)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data = encrypted
+	}
+	packets := p.Packetize(data, samples)
 
 	var writeErrs []error
 	for _, p := range packets {
+		if redEncoder != nil {
+			p.Payload = redEncoder.Encode(p.Payload, p.Timestamp)
+		}
+
 		if s.audioLevelID != 0 && opts != nil && opts.AudioLevel != nil {
 			ext := rtp.AudioLevelExtension{
 				Level: *opts.AudioLevel,
+				Voice: opts.VoiceActivity,
 			}
 			data, err := ext.Marshal()
 			if err != nil {
@@ -326,7 +468,7 @@ func (s *LocalSampleTrack) WriteSample(sample media.Sample, opts *SampleWriteOpt
 			}
 		}
 
-		if err := s.rtpTrack.WriteRTP(p); err != nil {
+		if err := rtpTrack.WriteRTP(p); err != nil {
 			writeErrs = append(writeErrs, err)
 		}
 	}
@@ -337,6 +479,29 @@ func (s *LocalSampleTrack) WriteSample(sample media.Sample, opts *SampleWriteOpt
 	return nil
 }
 
+// encryptFrame encrypts the frame of a sample, a frame without a slice is held back and e2ee.ErrNoSlice returned
+func (s *LocalSampleTrack) encryptFrame(cryptor *e2ee.FrameCryptor, data []byte, ssrc webrtc.SSRC, samples uint32) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	frame := data
+	if len(s.heldNALUs) > 0 {
+		frame = append(s.heldNALUs, data...)
+		s.heldNALUs = nil
+	}
+	encrypted, err := cryptor.Encrypt(frame, uint32(ssrc), s.encryptedTS)
+	if err == e2ee.ErrNoSlice {
+		// the caller may reuse the buffer of the sample
+		s.heldNALUs = append([]byte(nil), frame...)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.encryptedTS += samples
+	return encrypted, nil
+}
+
 func (s *LocalSampleTrack) Close() error {
 	s.lock.Lock()
 	cancelWrite := s.cancelWrite
@@ -353,7 +518,9 @@ func (s *LocalSampleTrack) Close() error {
 
 func (s *LocalSampleTrack) rtcpWorker(rtcpReader interceptor.RTCPReader) {
 	// read incoming rtcp packets, interceptors require this
-	b := make([]byte, rtpInboundMTU)
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	b := *buf
 	rtcpCB := s.onRTCP
 
 	for {
@@ -403,10 +570,23 @@ func (s *LocalSampleTrack) writeWorker(provider SampleProvider, onComplete func(
 	}
 
 	audioProvider, isAudioProvider := provider.(AudioSampleProvider)
+	vadProvider, isVADProvider := provider.(VoiceActivitySampleProvider)
 
 	nextSampleTime := time.Now()
 	ticker := time.NewTicker(10 * time.Millisecond)
 	for {
+		s.lock.RLock()
+		resume := s.resume
+		s.lock.RUnlock()
+		if resume != nil {
+			select {
+			case <-resume:
+				nextSampleTime = time.Now()
+			case <-ctx.Done():
+				return
+			}
+		}
+
 		sample, err := provider.NextSample()
 		if err == io.EOF {
 			return
@@ -422,9 +602,15 @@ func (s *LocalSampleTrack) writeWorker(provider SampleProvider, onComplete func(
 			opts = &SampleWriteOptions{
 				AudioLevel: &level,
 			}
+			if isVADProvider {
+				opts.VoiceActivity = vadProvider.CurrentVoiceActivity()
+			}
 		}
 
-		if err := s.WriteSample(sample, opts); err != nil {
+		if err := s.WriteSample(sample, opts); err == e2ee.ErrMissingKey {
+			// dropped until the key is set
+			logger.Debugw("could not encrypt sample", "error", err)
+		} else if err != nil {
 			logger.Warnw("could not write sample", err)
 			return
 		}
@@ -445,6 +631,26 @@ func (s *LocalSampleTrack) writeWorker(provider SampleProvider, onComplete func(
 	}
 }
 
+// redCodecFor returns the negotiated RED codec whose primary encoding is codec, along with the payload type of codec
+func redCodecFor(codec webrtc.RTPCodecCapability, negotiated []webrtc.RTPCodecParameters) (webrtc.RTPCodecParameters, uint8, bool) {
+	if !strings.EqualFold(codec.MimeType, webrtc.MimeTypeOpus) {
+		return webrtc.RTPCodecParameters{}, 0, false
+	}
+	for _, primary := range negotiated {
+		if !strings.EqualFold(primary.MimeType, codec.MimeType) {
+			continue
+		}
+		for _, red := range negotiated {
+			// fmtp lists the payload type of each block, e.g. 111/111
+			if strings.EqualFold(red.MimeType, mimeTypeRED) &&
+				strings.HasPrefix(red.SDPFmtpLine, fmt.Sprintf("%d/", primary.PayloadType)) {
+				return red, uint8(primary.PayloadType), true
+			}
+		}
+	}
+	return webrtc.RTPCodecParameters{}, 0, false
+}
+
 // duplicated from pion mediaengine.go
 func payloaderForCodec(codec webrtc.RTPCodecCapability) (rtp.Payloader, error) {
 	switch strings.ToLower(codec.MimeType) {
@@ -456,6 +662,10 @@ func payloaderForCodec(codec webrtc.RTPCodecCapability) (rtp.Payloader, error) {
 		return &codecs.VP8Payloader{EnablePictureID: true}, nil
 	case strings.ToLower(webrtc.MimeTypeVP9):
 		return &codecs.VP9Payloader{}, nil
+	case strings.ToLower(webrtc.MimeTypeH265):
+		return &sdkcodecs.H265Payloader{}, nil
+	case strings.ToLower(webrtc.MimeTypeAV1):
+		return &sdkcodecs.AV1Payloader{}, nil
 	case strings.ToLower(webrtc.MimeTypeG722):
 		return &codecs.G722Payloader{}, nil
 	case strings.ToLower(webrtc.MimeTypePCMU), strings.ToLower(webrtc.MimeTypePCMA):
@@ -0,0 +1,258 @@
+package live_sdk_go
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+
+	sdkinterceptor "github.com/liuhailove/live-sdk-go/pkg/interceptor"
+)
+
+const mimeTypeRED = "audio/red"
+
+// pcAPI creates the peer connections of transports. A single one can be shared by the transports of
+// many rooms, they then use the same MediaEngine, SettingEngine and interceptor registry
+type pcAPI struct {
+	// publishers always negotiate RED, subscribers only when ReceiveRED is set, as the payloads of remote tracks
+	// then change
+	publisher  *webrtc.API
+	subscriber *webrtc.API
+
+	// NewPeerConnection builds the interceptors of the new peer connection, they're attributed
+	// to the transport being created
+	lock     sync.Mutex
+	building *transportInterceptors
+}
+
+// transportInterceptors are the interceptors keeping state for a single transport
+type transportInterceptors struct {
+	statsFactory  *stats.InterceptorFactory
+	statsGetter   stats.Getter
+	frameCounter  *sdkinterceptor.FrameCounterInterceptorFactory
+	nackGenerator *sdkinterceptor.NackGeneratorInterceptorFactory
+	audioLevels   *sdkinterceptor.AudioLevelInterceptorFactory
+}
+
+func newTransportInterceptors() (*transportInterceptors, error) {
+	statsFactory, err := stats.NewInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	ti := &transportInterceptors{
+		statsFactory:  statsFactory,
+		frameCounter:  &sdkinterceptor.FrameCounterInterceptorFactory{},
+		nackGenerator: &sdkinterceptor.NackGeneratorInterceptorFactory{},
+		audioLevels:   &sdkinterceptor.AudioLevelInterceptorFactory{},
+	}
+	statsFactory.OnNewPeerConnection(func(_ string, getter stats.Getter) {
+		ti.statsGetter = getter
+	})
+	return ti, nil
+}
+
+// transportInterceptorFactory delegates to a factory of the transport being created
+type transportInterceptorFactory struct {
+	api     *pcAPI
+	factory func(ti *transportInterceptors) interceptor.Factory
+}
+
+func (f *transportInterceptorFactory) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	return f.factory(f.api.building).NewInterceptor(id)
+}
+
+// newPCAPI creates an api with the network and media settings of params
+func newPCAPI(params *ConnectParams) (*pcAPI, error) {
+	se, err := params.settingEngine()
+	if err != nil {
+		return nil, err
+	}
+	a := &pcAPI{}
+
+	publisherMedia, err := newMediaEngine(params, true)
+	if err != nil {
+		return nil, err
+	}
+	subscriberMedia := publisherMedia
+	if params == nil || !params.ReceiveRED {
+		if subscriberMedia, err = newMediaEngine(params, false); err != nil {
+			return nil, err
+		}
+	}
+
+	i := &interceptor.Registry{}
+
+	// stats interceptors go first so they also see the RTCP generated by the others
+	i.Add(&transportInterceptorFactory{api: a, factory: func(ti *transportInterceptors) interceptor.Factory {
+		return ti.statsFactory
+	}})
+	i.Add(&transportInterceptorFactory{api: a, factory: func(ti *transportInterceptors) interceptor.Factory {
+		return ti.frameCounter
+	}})
+	i.Add(&transportInterceptorFactory{api: a, factory: func(ti *transportInterceptors) interceptor.Factory {
+		return ti.audioLevels
+	}})
+
+	// nack interceptor
+	responder, err := nack.NewResponderInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	i.Add(responder)
+	i.Add(&transportInterceptorFactory{api: a, factory: func(ti *transportInterceptors) interceptor.Factory {
+		return ti.nackGenerator
+	}})
+
+	// rtcp report interceptor
+	if err := webrtc.ConfigureRTCPReports(i); err != nil {
+		return nil, err
+	}
+
+	// twcc interceptor, its feedback and extension are registered with the media engines
+	twccSender, err := twcc.NewSenderInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	i.Add(twccSender)
+
+	if params != nil {
+		for _, f := range params.Interceptors {
+			i.Add(f)
+		}
+	}
+
+	se.SetSRTPProtectionProfiles(dtls.SRTP_AEAD_AES_128_GCM, dtls.SRTP_AES128_CM_HMAC_SHA1_80)
+
+	a.publisher = webrtc.NewAPI(webrtc.WithMediaEngine(publisherMedia), webrtc.WithSettingEngine(se), webrtc.WithInterceptorRegistry(i))
+	a.subscriber = a.publisher
+	if subscriberMedia != publisherMedia {
+		a.subscriber = webrtc.NewAPI(webrtc.WithMediaEngine(subscriberMedia), webrtc.WithSettingEngine(se), webrtc.WithInterceptorRegistry(i))
+	}
+	return a, nil
+}
+
+// newMediaEngine registers the codecs and header extensions of the SDK and params, RED only when red is set
+func newMediaEngine(params *ConnectParams, red bool) (*webrtc.MediaEngine, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	if red {
+		if err := registerRED(m); err != nil {
+			return nil, err
+		}
+	}
+	if err := registerCodecs(m); err != nil {
+		return nil, err
+	}
+	audioLevelExtension := webrtc.RTPHeaderExtensionCapability{URI: sdp.AudioLevelURI}
+	if err := m.RegisterHeaderExtension(audioLevelExtension, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, err
+	}
+	sdesMidExtension := webrtc.RTPHeaderExtensionCapability{URI: sdp.SDESMidURI}
+	if err := m.RegisterHeaderExtension(sdesMidExtension, webrtc.RTPCodecTypeVideo); err != nil {
+		return nil, err
+	}
+	sdesRtpStreamIdExtension := webrtc.RTPHeaderExtensionCapability{URI: sdp.SDESRTPStreamIDURI}
+	if err := m.RegisterHeaderExtension(sdesRtpStreamIdExtension, webrtc.RTPCodecTypeVideo); err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		for _, codec := range params.Codecs {
+			if err := m.RegisterCodec(codec, codecKind(codec.MimeType)); err != nil {
+				return nil, err
+			}
+		}
+		for _, ext := range params.HeaderExtensions {
+			if err := m.RegisterHeaderExtension(ext.Capability, ext.Kind, ext.Directions...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack"}, webrtc.RTPCodecTypeVideo)
+	m.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack", Parameter: "pli"}, webrtc.RTPCodecTypeVideo)
+
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeVideo, webrtc.RTPCodecTypeAudio} {
+		m.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBTransportCC}, kind)
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: sdp.TransportCCURI}, kind); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// registerRED adds RED with opus as primary and redundant encoding
+func registerRED(m *webrtc.MediaEngine) error {
+	red := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: mimeTypeRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"},
+		PayloadType:        63,
+	}
+	return m.RegisterCodec(red, webrtc.RTPCodecTypeAudio)
+}
+
+// registerCodecs adds the video codecs pion doesn't register by default
+func registerCodecs(m *webrtc.MediaEngine) error {
+	videoRTCPFeedback := []webrtc.RTCPFeedback{{Type: "goog-remb"}, {Type: "ccm", Parameter: "fir"}, {Type: "nack"}, {Type: "nack", Parameter: "pli"}}
+	for _, codec := range []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeAV1, ClockRate: 90000, RTCPFeedback: videoRTCPFeedback},
+			PayloadType:        35,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "video/rtx", ClockRate: 90000, SDPFmtpLine: "apt=35"},
+			PayloadType:        36,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH265, ClockRate: 90000, RTCPFeedback: videoRTCPFeedback},
+			PayloadType:        104,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "video/rtx", ClockRate: 90000, SDPFmtpLine: "apt=104"},
+			PayloadType:        105,
+		},
+	} {
+		if err := m.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// codecKind returns the kind of media of a mime type
+func codecKind(mimeType string) webrtc.RTPCodecType {
+	if strings.HasPrefix(strings.ToLower(mimeType), "audio/") {
+		return webrtc.RTPCodecTypeAudio
+	}
+	return webrtc.RTPCodecTypeVideo
+}
+
+func (a *pcAPI) newPeerConnection(configuration webrtc.Configuration, subscriber bool) (*webrtc.PeerConnection, *transportInterceptors, error) {
+	ti, err := newTransportInterceptors()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.building = ti
+	defer func() {
+		a.building = nil
+	}()
+	api := a.publisher
+	if subscriber {
+		api = a.subscriber
+	}
+	pc, err := api.NewPeerConnection(configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pc, ti, nil
+}
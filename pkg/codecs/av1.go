@@ -0,0 +1,264 @@
+// Package codecs packetizes and depacketizes the video codecs pion doesn't fully support, AV1 and H.265,
+// and reads their elementary streams. It also encodes and decodes redundant audio (RED), reads PCM audio from WAV
+// files and encodes it with G.711
+package codecs
+
+import (
+	"errors"
+
+	"github.com/pion/rtp/codecs"
+)
+
+const (
+	obuTypeSequenceHeader    = 1
+	obuTypeTemporalDelimiter = 2
+	obuTypeFrameHeader       = 3
+	obuTypeFrame             = 6
+	obuTypeTileList          = 8
+
+	obuHasExtensionMask byte = 0x04
+	obuHasSizeFieldMask byte = 0x02
+
+	av1ZMask = 0x80
+	av1YMask = 0x40
+	av1NMask = 0x08
+)
+
+var (
+	ErrShortPacket = errors.New("packet is not large enough")
+	ErrInvalidOBU  = errors.New("invalid OBU")
+	ErrInvalidNALU = errors.New("invalid NAL unit")
+)
+
+// obu is an OBU without its size field
+type obu struct {
+	header []byte
+	data   []byte
+}
+
+func (o *obu) obuType() byte {
+	return (o.header[0] >> 3) & 0x0f
+}
+
+// parseOBUs splits a temporal unit in the low overhead bitstream format (AV1 spec section 5).
+// The last OBU may omit its size field, it then extends to the end of the data
+func parseOBUs(tu []byte) ([]obu, error) {
+	var obus []obu
+	for len(tu) > 0 {
+		headerLen := 1
+		if tu[0]&obuHasExtensionMask != 0 {
+			headerLen = 2
+		}
+		if len(tu) < headerLen {
+			return nil, ErrInvalidOBU
+		}
+		o := obu{header: tu[:headerLen]}
+		tu = tu[headerLen:]
+		if o.header[0]&obuHasSizeFieldMask != 0 {
+			size, n, err := readLEB128(tu)
+			if err != nil {
+				return nil, err
+			}
+			tu = tu[n:]
+			if uint64(len(tu)) < size {
+				return nil, ErrInvalidOBU
+			}
+			o.data, tu = tu[:size], tu[size:]
+		} else {
+			o.data, tu = tu, nil
+		}
+		obus = append(obus, o)
+	}
+	return obus, nil
+}
+
+// AV1Payloader payloads temporal units in the low overhead bitstream format according to the RTP payload
+// format for AV1. Temporal delimiters and tile lists are dropped, size fields are removed from OBUs
+type AV1Payloader struct{}
+
+// Payload fragments a temporal unit across one or more byte arrays
+func (p *AV1Payloader) Payload(mtu uint16, payload []byte) [][]byte {
+	obus, err := parseOBUs(payload)
+	if err != nil || int(mtu) < 4 {
+		return nil
+	}
+
+	var payloads [][]byte
+	var elements [][]byte
+	newSequence := false
+	for _, o := range obus {
+		switch o.obuType() {
+		case obuTypeTemporalDelimiter, obuTypeTileList:
+			continue
+		case obuTypeSequenceHeader:
+			newSequence = true
+		}
+		element := make([]byte, 0, len(o.header)+len(o.data))
+		element = append(element, o.header[0]&^obuHasSizeFieldMask)
+		element = append(element, o.header[1:]...)
+		elements = append(elements, append(element, o.data...))
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+
+	// every OBU element is prefixed with its length (W = 0)
+	current := []byte{0}
+	if newSequence {
+		current[0] |= av1NMask
+	}
+	for _, element := range elements {
+		for len(element) > 0 {
+			available := int(mtu) - len(current)
+			if available < 2 {
+				payloads = append(payloads, current)
+				current = []byte{0}
+				available = int(mtu) - 1
+			}
+			size := len(element)
+			if size+leb128Size(uint64(size)) > available {
+				size = available - leb128Size(uint64(available))
+			}
+			current = appendLEB128(current, uint64(size))
+			current = append(current, element[:size]...)
+			element = element[size:]
+			if len(element) > 0 {
+				// the OBU continues in the next packet
+				current[0] |= av1YMask
+				payloads = append(payloads, current)
+				current = []byte{av1ZMask}
+			}
+		}
+	}
+	if len(current) > 1 {
+		payloads = append(payloads, current)
+	}
+	return payloads
+}
+
+// AV1Depacketizer reassembles OBUs from AV1 RTP packets, samples are output in the low overhead bitstream format
+// with size fields, without temporal delimiters. It keeps the OBU fragmented across packets, packets of a track
+// must be unmarshalled in order
+type AV1Depacketizer struct {
+	fragment []byte
+}
+
+// Unmarshal parses an AV1 RTP payload, returning the OBUs completed by it
+func (d *AV1Depacketizer) Unmarshal(payload []byte) ([]byte, error) {
+	packet := &codecs.AV1Packet{}
+	if _, err := packet.Unmarshal(payload); err != nil {
+		d.fragment = nil
+		return nil, err
+	}
+
+	var out []byte
+	last := len(packet.OBUElements) - 1
+	for i, element := range packet.OBUElements {
+		if i == 0 && packet.Z {
+			if d.fragment == nil {
+				// the beginning of the OBU was lost
+				continue
+			}
+			element = append(d.fragment, element...)
+			d.fragment = nil
+		} else if i == 0 {
+			d.fragment = nil
+		}
+		if i == last && packet.Y {
+			d.fragment = append([]byte{}, element...)
+			continue
+		}
+
+		var err error
+		if out, err = appendOBU(out, element); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// IsPartitionHead checks whether the packet doesn't continue an OBU of the previous packet
+func (d *AV1Depacketizer) IsPartitionHead(payload []byte) bool {
+	return len(payload) > 0 && payload[0]&av1ZMask == 0
+}
+
+// IsPartitionTail checks for the marker bit, which is set on the last packet of a temporal unit
+func (d *AV1Depacketizer) IsPartitionTail(marker bool, _ []byte) bool {
+	return marker
+}
+
+// appendOBU appends an OBU element to out with a size field
+func appendOBU(out []byte, element []byte) ([]byte, error) {
+	if len(element) == 0 {
+		return out, nil
+	}
+	headerLen := 1
+	if element[0]&obuHasExtensionMask != 0 {
+		headerLen = 2
+	}
+	if len(element) < headerLen {
+		return nil, ErrInvalidOBU
+	}
+	if (element[0]>>3)&0x0f == obuTypeTemporalDelimiter {
+		return out, nil
+	}
+	if element[0]&obuHasSizeFieldMask != 0 {
+		return append(out, element...), nil
+	}
+	out = append(out, element[0]|obuHasSizeFieldMask)
+	out = append(out, element[1:headerLen]...)
+	out = appendLEB128(out, uint64(len(element)-headerLen))
+	return append(out, element[headerLen:]...), nil
+}
+
+// IsAV1KeyFrame checks whether a temporal unit in the low overhead bitstream format starts with a key frame
+func IsAV1KeyFrame(tu []byte) bool {
+	obus, err := parseOBUs(tu)
+	if err != nil {
+		return false
+	}
+	for _, o := range obus {
+		switch o.obuType() {
+		case obuTypeSequenceHeader:
+			// seq_profile (3), still_picture (1), reduced_still_picture_header (1), which only has key frames
+			if len(o.data) > 0 && o.data[0]&0x08 != 0 {
+				return true
+			}
+		case obuTypeFrameHeader, obuTypeFrame:
+			// show_existing_frame (1), frame_type (2), KEY_FRAME is 0
+			if len(o.data) == 0 || o.data[0]&0x80 != 0 {
+				return false
+			}
+			return o.data[0]&0x60 == 0
+		}
+	}
+	return false
+}
+
+func readLEB128(data []byte) (uint64, int, error) {
+	var value uint64
+	for i := 0; i < 8 && i < len(data); i++ {
+		value |= uint64(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, ErrInvalidOBU
+}
+
+func appendLEB128(out []byte, value uint64) []byte {
+	for value >= 0x80 {
+		out = append(out, byte(value&0x7f)|0x80)
+		value >>= 7
+	}
+	return append(out, byte(value))
+}
+
+func leb128Size(value uint64) int {
+	size := 1
+	for value >= 0x80 {
+		size++
+		value >>= 7
+	}
+	return size
+}
@@ -0,0 +1,104 @@
+package codecs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/samplebuilder"
+)
+
+// testOBU returns an OBU with a size field
+func testOBU(obuType byte, data []byte) []byte {
+	return append(appendLEB128([]byte{obuType<<3 | obuHasSizeFieldMask}, uint64(len(data))), data...)
+}
+
+func testTemporalUnit(key bool, size int) []byte {
+	frame := bytes.Repeat([]byte{0xab}, size)
+	tu := testOBU(obuTypeTemporalDelimiter, nil)
+	if key {
+		tu = append(tu, testOBU(obuTypeSequenceHeader, []byte{0x00, 0x00, 0x00, 0x0a, 0x0b})...)
+		frame[0] = 0x10
+	} else {
+		frame[0] = 0x30
+	}
+	return append(tu, testOBU(obuTypeFrame, frame)...)
+}
+
+func TestAV1PayloaderDepacketizer(t *testing.T) {
+	packetizer := rtp.NewPacketizer(1200, 96, 1, &AV1Payloader{}, rtp.NewRandomSequencer(), 90000)
+	builder := samplebuilder.New(100, &AV1Depacketizer{}, 90000)
+
+	tus := [][]byte{
+		testTemporalUnit(true, 3000),
+		testTemporalUnit(false, 100),
+		testTemporalUnit(false, 1180),
+		testTemporalUnit(false, 2500),
+		testTemporalUnit(false, 10),
+	}
+	var packets []*rtp.Packet
+	for _, tu := range tus {
+		pkts := packetizer.Packetize(tu, 3000)
+		require.NotEmpty(t, pkts)
+		for _, p := range pkts {
+			require.LessOrEqual(t, len(p.Payload), 1200-12)
+		}
+		packets = append(packets, pkts...)
+	}
+	require.True(t, packets[0].Payload[0]&av1NMask != 0)
+
+	var samples [][]byte
+	for _, p := range packets {
+		builder.Push(p)
+		for s := builder.Pop(); s != nil; s = builder.Pop() {
+			samples = append(samples, s.Data)
+		}
+	}
+	require.Len(t, samples, len(tus))
+	for i, tu := range tus {
+		// temporal delimiters are dropped
+		require.Equal(t, tu[2:], samples[i])
+		require.Equal(t, i == 0, IsAV1KeyFrame(samples[i]))
+	}
+}
+
+func TestAV1DepacketizerLostFragment(t *testing.T) {
+	payloads := (&AV1Payloader{}).Payload(500, testTemporalUnit(false, 1200))
+	require.Len(t, payloads, 3)
+	d := &AV1Depacketizer{}
+	require.True(t, d.IsPartitionHead(payloads[0]))
+	require.False(t, d.IsPartitionHead(payloads[1]))
+
+	// without the first fragment, the OBU is dropped
+	for _, payload := range payloads[1:] {
+		out, err := d.Unmarshal(payload)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	}
+}
+
+func TestOBUReader(t *testing.T) {
+	tus := [][]byte{testTemporalUnit(true, 500), testTemporalUnit(false, 20), testTemporalUnit(false, 200)}
+	r := NewOBUReader(bytes.NewReader(bytes.Join(tus, nil)))
+	for _, tu := range tus {
+		read, err := r.NextTemporalUnit()
+		require.NoError(t, err)
+		require.Equal(t, tu, read)
+	}
+	_, err := r.NextTemporalUnit()
+	require.Equal(t, io.EOF, err)
+
+	// truncated OBU
+	r = NewOBUReader(bytes.NewReader(tus[0][:100]))
+	_, err = r.NextTemporalUnit()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// OBU size of 2^56-1, rejected before it's allocated
+	huge := append([]byte{0x32}, bytes.Repeat([]byte{0xff}, 7)...)
+	r = NewOBUReader(bytes.NewReader(append(huge, 0x7f)))
+	_, err = r.NextTemporalUnit()
+	require.ErrorIs(t, err, ErrInvalidOBU)
+}
@@ -0,0 +1,114 @@
+package codecs
+
+import "math/bits"
+
+const (
+	// G711ClockRate is the sample rate of G.711 audio
+	G711ClockRate = 8000
+
+	muLawBias = 0x84
+	muLawClip = 32635
+
+	aLawSignMask = 0xd5
+	aLawToggle   = 0x55
+)
+
+// EncodePCMU encodes 16 bit PCM samples at 8 kHz with G.711 µ-law, one byte per sample
+func EncodePCMU(pcm []int16) []byte {
+	out := make([]byte, len(pcm))
+	for i, s := range pcm {
+		out[i] = linearToMuLaw(s)
+	}
+	return out
+}
+
+// DecodePCMU decodes G.711 µ-law to 16 bit PCM samples
+func DecodePCMU(data []byte) []int16 {
+	out := make([]int16, len(data))
+	for i, b := range data {
+		out[i] = muLawToLinear(b)
+	}
+	return out
+}
+
+// EncodePCMA encodes 16 bit PCM samples at 8 kHz with G.711 A-law, one byte per sample
+func EncodePCMA(pcm []int16) []byte {
+	out := make([]byte, len(pcm))
+	for i, s := range pcm {
+		out[i] = linearToALaw(s)
+	}
+	return out
+}
+
+// DecodePCMA decodes G.711 A-law to 16 bit PCM samples
+func DecodePCMA(data []byte) []int16 {
+	out := make([]int16, len(data))
+	for i, b := range data {
+		out[i] = aLawToLinear(b)
+	}
+	return out
+}
+
+func linearToMuLaw(s int16) byte {
+	sample := int(s)
+	var sign byte
+	if sample < 0 {
+		sample = -sample
+		sign = 0x80
+	}
+	if sample > muLawClip {
+		sample = muLawClip
+	}
+	sample += muLawBias
+	// the segment is the position of the highest bit past the 7 lowest ones
+	exponent := bits.Len(uint(sample>>7)) - 1
+	mantissa := (sample >> (exponent + 3)) & 0x0f
+	return ^(sign | byte(exponent<<4) | byte(mantissa))
+}
+
+func muLawToLinear(b byte) int16 {
+	b = ^b
+	sample := (int(b&0x0f)<<3 + muLawBias) << ((b >> 4) & 0x07)
+	if b&0x80 != 0 {
+		return int16(muLawBias - sample)
+	}
+	return int16(sample - muLawBias)
+}
+
+func linearToALaw(s int16) byte {
+	// A-law quantizes 13 bit samples
+	sample := int(s) >> 3
+	mask := byte(aLawSignMask)
+	if sample < 0 {
+		mask = aLawToggle
+		sample = -sample - 1
+	}
+	segment := bits.Len(uint(sample >> 5))
+	if segment >= 8 {
+		return 0x7f ^ mask
+	}
+	value := byte(segment << 4)
+	if segment < 2 {
+		value |= byte(sample>>1) & 0x0f
+	} else {
+		value |= byte(sample>>segment) & 0x0f
+	}
+	return value ^ mask
+}
+
+func aLawToLinear(b byte) int16 {
+	b ^= aLawToggle
+	sample := int(b&0x0f) << 4
+	switch segment := (b & 0x70) >> 4; segment {
+	case 0:
+		sample += 8
+	case 1:
+		sample += 0x108
+	default:
+		sample = (sample + 0x108) << (segment - 1)
+	}
+	if b&0x80 != 0 {
+		return int16(sample)
+	}
+	return int16(-sample)
+}
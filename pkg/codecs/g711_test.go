@@ -0,0 +1,37 @@
+package codecs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestG711(t *testing.T) {
+	// silence
+	require.Equal(t, []byte{0xff}, EncodePCMU([]int16{0}))
+	require.Equal(t, []byte{0xd5}, EncodePCMA([]int16{0}))
+	require.Equal(t, []int16{0}, DecodePCMU([]byte{0xff}))
+	require.Equal(t, []int16{8}, DecodePCMA([]byte{0xd5}))
+	// full scale
+	require.Equal(t, []byte{0x80, 0x00}, EncodePCMU([]int16{math.MaxInt16, math.MinInt16}))
+	require.Equal(t, []byte{0xaa, 0x2a}, EncodePCMA([]int16{math.MaxInt16, math.MinInt16}))
+
+	// the quantization error grows with the amplitude, up to 1/16 of it
+	for s := math.MinInt16; s <= math.MaxInt16; s += 7 {
+		pcm := []int16{int16(s)}
+		tolerance := math.Abs(float64(s))/16 + 16
+		require.InDelta(t, s, DecodePCMU(EncodePCMU(pcm))[0], tolerance, "µ-law %d", s)
+		require.InDelta(t, s, DecodePCMA(EncodePCMA(pcm))[0], tolerance, "A-law %d", s)
+	}
+
+	// every code decodes to a value encoded to the same code
+	for i := 0; i < 256; i++ {
+		code := []byte{byte(i)}
+		if i != 0x7f {
+			// µ-law has two codes for 0
+			require.Equal(t, code, EncodePCMU(DecodePCMU(code)), "µ-law %x", i)
+		}
+		require.Equal(t, code, EncodePCMA(DecodePCMA(code)), "A-law %x", i)
+	}
+}
@@ -0,0 +1,249 @@
+package codecs
+
+const (
+	h265NALUHeaderSize = 2
+	h265FUHeaderSize   = 1
+
+	h265NALUTypeIRAPFirst         = 16
+	h265NALUTypeIRAPLast          = 23
+	h265NALUTypeVCLLast           = 31
+	h265NALUTypeVPS               = 32
+	h265NALUTypeSPS               = 33
+	h265NALUTypePPS               = 34
+	h265NALUTypeAggregationPacket = 48
+	h265NALUTypeFragmentationUnit = 49
+	h265NALUTypePACI              = 50
+
+	h265FUStartMask byte = 0x80
+	h265FUEndMask   byte = 0x40
+)
+
+var annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+
+func h265NALUType(nalu []byte) byte {
+	return (nalu[0] >> 1) & 0x3f
+}
+
+// H265Payloader payloads H.265 NAL units according to RFC 7798, without DONL. The input may be an Annex-B
+// byte stream or a single NAL unit. Parameter sets are held back and sent in an aggregation packet
+// along with the next NAL unit
+type H265Payloader struct {
+	parameterSets [][]byte
+}
+
+// Payload fragments the NAL units of payload across one or more byte arrays
+func (p *H265Payloader) Payload(mtu uint16, payload []byte) [][]byte {
+	var payloads [][]byte
+	for _, nalu := range SplitAnnexB(payload) {
+		if len(nalu) < h265NALUHeaderSize {
+			continue
+		}
+		switch h265NALUType(nalu) {
+		case h265NALUTypeVPS, h265NALUTypeSPS, h265NALUTypePPS:
+			p.parameterSets = append(p.parameterSets, append([]byte{}, nalu...))
+			continue
+		}
+
+		if len(p.parameterSets) > 0 {
+			payloads = append(payloads, p.aggregate(mtu, p.parameterSets)...)
+			p.parameterSets = nil
+		}
+		payloads = append(payloads, p.payloadNALU(mtu, nalu)...)
+	}
+	return payloads
+}
+
+// aggregate puts as many NAL units as fit in the MTU in aggregation packets
+func (p *H265Payloader) aggregate(mtu uint16, nalus [][]byte) [][]byte {
+	var payloads [][]byte
+	for len(nalus) > 0 {
+		size := h265NALUHeaderSize
+		n := 0
+		for ; n < len(nalus); n++ {
+			if size+2+len(nalus[n]) > int(mtu) {
+				break
+			}
+			size += 2 + len(nalus[n])
+		}
+		if n < 2 {
+			// a single NAL unit isn't aggregated
+			payloads = append(payloads, p.payloadNALU(mtu, nalus[0])...)
+			nalus = nalus[1:]
+			continue
+		}
+
+		// F is set if any unit has it, LayerId and TID are the lowest of all units
+		out := make([]byte, h265NALUHeaderSize, size)
+		var forbidden byte
+		layerID, tid := byte(0x3f), byte(0x07)
+		for _, nalu := range nalus[:n] {
+			forbidden |= nalu[0] & 0x80
+			if l := (nalu[0]&0x01)<<5 | nalu[1]>>3; l < layerID {
+				layerID = l
+			}
+			if t := nalu[1] & 0x07; t < tid {
+				tid = t
+			}
+			out = append(out, byte(len(nalu)>>8), byte(len(nalu)))
+			out = append(out, nalu...)
+		}
+		out[0] = forbidden | h265NALUTypeAggregationPacket<<1 | layerID>>5
+		out[1] = layerID<<3 | tid
+		payloads = append(payloads, out)
+		nalus = nalus[n:]
+	}
+	return payloads
+}
+
+// payloadNALU sends a NAL unit as is, or in fragmentation units when it exceeds the MTU
+func (p *H265Payloader) payloadNALU(mtu uint16, nalu []byte) [][]byte {
+	if len(nalu) <= int(mtu) {
+		return [][]byte{append([]byte{}, nalu...)}
+	}
+
+	maxFragmentSize := int(mtu) - h265NALUHeaderSize - h265FUHeaderSize
+	if maxFragmentSize <= 0 {
+		return nil
+	}
+	naluType := h265NALUType(nalu)
+	data := nalu[h265NALUHeaderSize:]
+	var payloads [][]byte
+	for start := 0; start < len(data); start += maxFragmentSize {
+		end := start + maxFragmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+		fuHeader := naluType
+		if start == 0 {
+			fuHeader |= h265FUStartMask
+		}
+		if end == len(data) {
+			fuHeader |= h265FUEndMask
+		}
+		out := make([]byte, 0, h265NALUHeaderSize+h265FUHeaderSize+end-start)
+		out = append(out, nalu[0]&0x81|h265NALUTypeFragmentationUnit<<1, nalu[1], fuHeader)
+		payloads = append(payloads, append(out, data[start:end]...))
+	}
+	return payloads
+}
+
+// H265Depacketizer reassembles NAL units from RTP packets according to RFC 7798, samples are output as an Annex-B
+// byte stream. It keeps the NAL unit fragmented across packets, packets of a track must be unmarshalled in order
+type H265Depacketizer struct {
+	fragment []byte
+}
+
+// Unmarshal parses an H.265 RTP payload, returning the NAL units completed by it
+func (d *H265Depacketizer) Unmarshal(payload []byte) ([]byte, error) {
+	if len(payload) < h265NALUHeaderSize {
+		return nil, ErrShortPacket
+	}
+
+	switch h265NALUType(payload) {
+	case h265NALUTypeAggregationPacket:
+		d.fragment = nil
+		var out []byte
+		for data := payload[h265NALUHeaderSize:]; len(data) > 0; {
+			if len(data) < 2 {
+				return nil, ErrShortPacket
+			}
+			size := int(data[0])<<8 | int(data[1])
+			data = data[2:]
+			if size < h265NALUHeaderSize || len(data) < size {
+				return nil, ErrInvalidNALU
+			}
+			out = append(out, annexBStartCode...)
+			out = append(out, data[:size]...)
+			data = data[size:]
+		}
+		return out, nil
+	case h265NALUTypeFragmentationUnit:
+		if len(payload) < h265NALUHeaderSize+h265FUHeaderSize {
+			return nil, ErrShortPacket
+		}
+		fuHeader := payload[2]
+		if fuHeader&h265FUStartMask != 0 {
+			// restore the header of the fragmented NAL unit
+			d.fragment = append([]byte{}, annexBStartCode...)
+			d.fragment = append(d.fragment, payload[0]&0x81|(fuHeader&0x3f)<<1, payload[1])
+		} else if d.fragment == nil {
+			// the beginning of the NAL unit was lost
+			return nil, nil
+		}
+		d.fragment = append(d.fragment, payload[3:]...)
+		if fuHeader&h265FUEndMask == 0 {
+			return nil, nil
+		}
+		out := d.fragment
+		d.fragment = nil
+		return out, nil
+	case h265NALUTypePACI:
+		return nil, ErrInvalidNALU
+	default:
+		d.fragment = nil
+		out := make([]byte, 0, len(annexBStartCode)+len(payload))
+		out = append(out, annexBStartCode...)
+		return append(out, payload...), nil
+	}
+}
+
+// IsPartitionHead checks whether the packet starts a NAL unit
+func (d *H265Depacketizer) IsPartitionHead(payload []byte) bool {
+	if len(payload) < h265NALUHeaderSize+h265FUHeaderSize {
+		return len(payload) >= h265NALUHeaderSize
+	}
+	if h265NALUType(payload) == h265NALUTypeFragmentationUnit {
+		return payload[2]&h265FUStartMask != 0
+	}
+	return true
+}
+
+// IsPartitionTail checks for the marker bit, which is set on the last packet of an access unit
+func (d *H265Depacketizer) IsPartitionTail(marker bool, _ []byte) bool {
+	return marker
+}
+
+// IsH265KeyFrame checks whether an Annex-B access unit contains an IRAP picture, which decoding can start at
+func IsH265KeyFrame(frame []byte) bool {
+	for _, nalu := range SplitAnnexB(frame) {
+		if len(nalu) < h265NALUHeaderSize {
+			continue
+		}
+		if t := h265NALUType(nalu); t >= h265NALUTypeIRAPFirst && t <= h265NALUTypeIRAPLast {
+			return true
+		}
+	}
+	return false
+}
+
+// IsH265VCL checks whether a NAL unit holds a slice of a picture
+func IsH265VCL(nalu []byte) bool {
+	return len(nalu) >= h265NALUHeaderSize && h265NALUType(nalu) <= h265NALUTypeVCLLast
+}
+
+// SplitAnnexB returns the NAL units of an Annex-B byte stream, without start codes.
+// Data without a start code is a single NAL unit
+func SplitAnnexB(data []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			end := i
+			for end > start && data[end-1] == 0 {
+				end--
+			}
+			nalus = append(nalus, data[start:end])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(data) {
+		nalus = append(nalus, data[start:])
+	} else if start < 0 && len(data) > 0 {
+		nalus = append(nalus, data)
+	}
+	return nalus
+}
@@ -0,0 +1,98 @@
+package codecs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/jitter"
+)
+
+func testNALU(naluType byte, size int) []byte {
+	nalu := bytes.Repeat([]byte{0xcd}, size)
+	nalu[0] = naluType << 1
+	nalu[1] = 0x01
+	return nalu
+}
+
+func annexB(nalus ...[]byte) []byte {
+	var out []byte
+	for _, nalu := range nalus {
+		out = append(out, annexBStartCode...)
+		out = append(out, nalu...)
+	}
+	return out
+}
+
+func TestH265PayloaderDepacketizer(t *testing.T) {
+	vps, sps, pps := testNALU(h265NALUTypeVPS, 20), testNALU(h265NALUTypeSPS, 40), testNALU(h265NALUTypePPS, 10)
+	idr := testNALU(19, 4000)
+	trail := testNALU(1, 300)
+
+	payloader := &H265Payloader{}
+	// parameter sets are aggregated with the next NAL unit
+	require.Empty(t, payloader.Payload(1188, annexB(vps, sps)))
+	payloads := payloader.Payload(1188, annexB(pps, idr))
+	require.Len(t, payloads, 5)
+	require.Equal(t, byte(h265NALUTypeAggregationPacket), h265NALUType(payloads[0]))
+	for _, payload := range payloads[1:] {
+		require.Equal(t, byte(h265NALUTypeFragmentationUnit), h265NALUType(payload))
+		require.LessOrEqual(t, len(payload), 1188)
+	}
+
+	packetizer := rtp.NewPacketizer(1200, 96, 1, &H265Payloader{}, rtp.NewRandomSequencer(), 90000)
+	depacketizer := &H265Depacketizer{}
+	buffer := jitter.NewBuffer(depacketizer, 90000, time.Second)
+	frames := [][]byte{annexB(vps, sps, pps, idr), annexB(trail), annexB(trail, trail)}
+	var samples [][]byte
+	for _, frame := range frames {
+		for _, p := range packetizer.Packetize(frame, 3000) {
+			buffer.Push(p)
+		}
+		var sample []byte
+		for _, p := range buffer.Pop(false) {
+			data, err := depacketizer.Unmarshal(p.Payload)
+			require.NoError(t, err)
+			sample = append(sample, data...)
+		}
+		samples = append(samples, sample)
+	}
+	require.Equal(t, frames, samples)
+	require.True(t, IsH265KeyFrame(samples[0]))
+	require.False(t, IsH265KeyFrame(samples[1]))
+}
+
+func TestH265DepacketizerLostFragment(t *testing.T) {
+	payloads := (&H265Payloader{}).Payload(500, testNALU(1, 1200))
+	require.Len(t, payloads, 3)
+	d := &H265Depacketizer{}
+	require.True(t, d.IsPartitionHead(payloads[0]))
+	require.False(t, d.IsPartitionHead(payloads[1]))
+	for _, payload := range payloads[1:] {
+		out, err := d.Unmarshal(payload)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	}
+}
+
+func TestH265Reader(t *testing.T) {
+	nalus := [][]byte{testNALU(h265NALUTypeVPS, 20), testNALU(19, 100), testNALU(1, 50)}
+	// 3 and 4 byte start codes, trailing zeros
+	stream := append([]byte{0x00, 0x00, 0x01}, nalus[0]...)
+	stream = append(stream, annexB(nalus[1:]...)...)
+	stream = append(stream, 0x00, 0x00)
+
+	r := NewH265Reader(bytes.NewReader(stream))
+	for _, nalu := range nalus {
+		nal, err := r.NextNAL()
+		require.NoError(t, err)
+		require.Equal(t, nalu, nal.Data)
+		require.Equal(t, h265NALUType(nalu), nal.UnitType)
+	}
+	_, err := r.NextNAL()
+	require.Equal(t, io.EOF, err)
+}
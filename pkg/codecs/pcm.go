@@ -0,0 +1,285 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+	wavMaxFormatSize    = 1024
+
+	// streamed files don't know the size of their data
+	wavUnknownDataSize = 0xffffffff
+)
+
+var (
+	ErrInvalidWAV           = errors.New("invalid WAV file")
+	ErrUnsupportedPCMFormat = errors.New("unsupported PCM format")
+)
+
+// PCMFormat describes interleaved little-endian PCM audio
+type PCMFormat struct {
+	SampleRate int
+	Channels   int
+	// BitsPerSample is 8 (unsigned), 16, 24 or 32 for integer samples, 32 for float ones
+	BitsPerSample int
+	Float         bool
+}
+
+func (f PCMFormat) validate() error {
+	if f.SampleRate <= 0 || f.Channels <= 0 {
+		return ErrUnsupportedPCMFormat
+	}
+	switch {
+	case f.Float && f.BitsPerSample == 32:
+	case !f.Float && (f.BitsPerSample == 8 || f.BitsPerSample == 16 || f.BitsPerSample == 24 || f.BitsPerSample == 32):
+	default:
+		return ErrUnsupportedPCMFormat
+	}
+	return nil
+}
+
+// PCMReader reads PCM audio as 16 bit samples
+type PCMReader struct {
+	r      io.Reader
+	format PCMFormat
+	buf    []byte
+}
+
+// NewPCMReader reads raw PCM audio of the given format, 16 bit when BitsPerSample isn't set
+func NewPCMReader(in io.Reader, format PCMFormat) (*PCMReader, error) {
+	if format.BitsPerSample == 0 {
+		format.BitsPerSample = 16
+	}
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	return &PCMReader{r: in, format: format}, nil
+}
+
+// NewWAVReader reads the header of a WAV file, up to its data
+func NewWAVReader(in io.Reader) (*PCMReader, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, ErrInvalidWAV
+	}
+	if !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return nil, ErrInvalidWAV
+	}
+
+	var format *PCMFormat
+	for {
+		if _, err := io.ReadFull(in, header[:8]); err != nil {
+			return nil, ErrInvalidWAV
+		}
+		id := string(header[0:4])
+		size := binary.LittleEndian.Uint32(header[4:8])
+
+		if id == "data" {
+			if format == nil {
+				return nil, ErrInvalidWAV
+			}
+			r := in
+			if size != 0 && size != wavUnknownDataSize {
+				// other chunks may follow the data
+				r = io.LimitReader(in, int64(size))
+			}
+			return NewPCMReader(r, *format)
+		}
+
+		// chunks are padded to an even size
+		padded := int64(size) + int64(size%2)
+		if id != "fmt " {
+			if _, err := io.CopyN(io.Discard, in, padded); err != nil {
+				return nil, ErrInvalidWAV
+			}
+			continue
+		}
+		if size > wavMaxFormatSize {
+			return nil, ErrInvalidWAV
+		}
+		chunk := make([]byte, padded)
+		if _, err := io.ReadFull(in, chunk); err != nil {
+			return nil, ErrInvalidWAV
+		}
+		f, err := parseWAVFormat(chunk[:size])
+		if err != nil {
+			return nil, err
+		}
+		format = &f
+	}
+}
+
+func parseWAVFormat(chunk []byte) (PCMFormat, error) {
+	if len(chunk) < 16 {
+		return PCMFormat{}, ErrInvalidWAV
+	}
+	tag := binary.LittleEndian.Uint16(chunk[0:2])
+	if tag == wavFormatExtensible {
+		if len(chunk) < 26 {
+			return PCMFormat{}, ErrInvalidWAV
+		}
+		// the sub format GUID starts with the format tag
+		tag = binary.LittleEndian.Uint16(chunk[24:26])
+	}
+	format := PCMFormat{
+		Channels:      int(binary.LittleEndian.Uint16(chunk[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(chunk[4:8])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:16])),
+	}
+	switch tag {
+	case wavFormatPCM:
+	case wavFormatFloat:
+		format.Float = true
+	default:
+		return PCMFormat{}, ErrUnsupportedPCMFormat
+	}
+	return format, format.validate()
+}
+
+// Format returns the format of the audio read
+func (r *PCMReader) Format() PCMFormat {
+	return r.format
+}
+
+// ReadSamples reads up to frames samples of each channel, interleaved. The last samples of the stream may be fewer,
+// io.EOF is returned after them
+func (r *PCMReader) ReadSamples(frames int) ([]int16, error) {
+	sampleSize := r.format.BitsPerSample / 8
+	frameSize := sampleSize * r.format.Channels
+	if cap(r.buf) < frames*frameSize {
+		r.buf = make([]byte, frames*frameSize)
+	}
+	buf := r.buf[:frames*frameSize]
+
+	n, err := io.ReadFull(r.r, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	// incomplete frames are dropped
+	n -= n % frameSize
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	samples := make([]int16, n/sampleSize)
+	for i := range samples {
+		b := buf[i*sampleSize:]
+		switch {
+		case r.format.Float:
+			f := math.Float32frombits(binary.LittleEndian.Uint32(b))
+			samples[i] = floatToSample(f)
+		case sampleSize == 1:
+			samples[i] = int16(b[0]-0x80) << 8
+		case sampleSize == 2:
+			samples[i] = int16(binary.LittleEndian.Uint16(b))
+		case sampleSize == 3:
+			samples[i] = int16(uint16(b[1]) | uint16(b[2])<<8)
+		case sampleSize == 4:
+			samples[i] = int16(binary.LittleEndian.Uint32(b) >> 16)
+		}
+	}
+	return samples, nil
+}
+
+func floatToSample(f float32) int16 {
+	switch {
+	case f >= 1:
+		return math.MaxInt16
+	case f <= -1:
+		return math.MinInt16
+	}
+	return int16(f * math.MaxInt16)
+}
+
+// DownmixToMono averages the channels of interleaved samples
+func DownmixToMono(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		sum := 0
+		for _, s := range samples[i*channels : (i+1)*channels] {
+			sum += int(s)
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
+
+// Resampler converts the sample rate of mono audio with linear interpolation, keeping its state between calls.
+// When downsampling, the audio is low-pass filtered with a moving average first, to limit aliasing
+type Resampler struct {
+	// input samples per output sample
+	step float64
+	// position of the next output sample, from the last input sample of the previous call
+	pos  float64
+	last int16
+
+	window    []int
+	windowPos int
+	windowSum int
+}
+
+func NewResampler(inputRate, outputRate int) *Resampler {
+	r := &Resampler{
+		step: float64(inputRate) / float64(outputRate),
+		pos:  1,
+	}
+	if n := inputRate / outputRate; n > 1 {
+		r.window = make([]int, n)
+	}
+	return r
+}
+
+// Resample returns the samples of in at the output rate
+func (r *Resampler) Resample(in []int16) []int16 {
+	if r.step == 1 || len(in) == 0 {
+		return in
+	}
+
+	// the last sample of the previous call comes first
+	buf := make([]int16, 0, len(in)+1)
+	buf = append(buf, r.last)
+	for _, s := range in {
+		buf = append(buf, r.lowPass(s))
+	}
+
+	out := make([]int16, 0, int(float64(len(in))/r.step)+1)
+	for {
+		i := int(r.pos)
+		frac := r.pos - float64(i)
+		if i >= len(buf) || (i == len(buf)-1 && frac > 0) {
+			break
+		}
+		s := float64(buf[i])
+		if frac > 0 {
+			s += (float64(buf[i+1]) - s) * frac
+		}
+		out = append(out, int16(math.Round(s)))
+		r.pos += r.step
+	}
+	r.pos -= float64(len(buf) - 1)
+	r.last = buf[len(buf)-1]
+	return out
+}
+
+func (r *Resampler) lowPass(s int16) int16 {
+	if r.window == nil {
+		return s
+	}
+	r.windowSum += int(s) - r.window[r.windowPos]
+	r.window[r.windowPos] = int(s)
+	r.windowPos = (r.windowPos + 1) % len(r.window)
+	return int16(r.windowSum / len(r.window))
+}
@@ -0,0 +1,148 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeWAV(t *testing.T, formatChunk []interface{}, data []byte) []byte {
+	fmtChunk := &bytes.Buffer{}
+	for _, v := range formatChunk {
+		require.NoError(t, binary.Write(fmtChunk, binary.LittleEndian, v))
+	}
+	wav := &bytes.Buffer{}
+	wav.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	// chunks before the format are skipped
+	wav.WriteString("LIST\x03\x00\x00\x00abc\x00")
+	wav.WriteString("fmt ")
+	require.NoError(t, binary.Write(wav, binary.LittleEndian, uint32(fmtChunk.Len())))
+	wav.Write(fmtChunk.Bytes())
+	wav.WriteString("data")
+	require.NoError(t, binary.Write(wav, binary.LittleEndian, uint32(len(data))))
+	wav.Write(data)
+	// and the ones after the data aren't read as samples
+	wav.WriteString("LIST\x04\x00\x00\x00abcd")
+	return wav.Bytes()
+}
+
+func TestWAVReader(t *testing.T) {
+	t.Run("PCM", func(t *testing.T) {
+		data := []byte{0x01, 0x00, 0xff, 0xff, 0x00, 0x80, 0xff, 0x7f, 0x10}
+		wav := writeWAV(t, []interface{}{uint16(wavFormatPCM), uint16(2), uint32(16000), uint32(64000), uint16(4), uint16(16)}, data)
+		r, err := NewWAVReader(bytes.NewReader(wav))
+		require.NoError(t, err)
+		require.Equal(t, PCMFormat{SampleRate: 16000, Channels: 2, BitsPerSample: 16}, r.Format())
+
+		samples, err := r.ReadSamples(1)
+		require.NoError(t, err)
+		require.Equal(t, []int16{1, -1}, samples)
+		// the incomplete frame is dropped
+		samples, err = r.ReadSamples(10)
+		require.NoError(t, err)
+		require.Equal(t, []int16{math.MinInt16, math.MaxInt16}, samples)
+		_, err = r.ReadSamples(10)
+		require.Equal(t, io.EOF, err)
+	})
+
+	t.Run("extensible float", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		require.NoError(t, binary.Write(data, binary.LittleEndian, []float32{0, 0.5, -1, 2}))
+		wav := writeWAV(t, []interface{}{uint16(wavFormatExtensible), uint16(1), uint32(48000), uint32(192000), uint16(4),
+			uint16(32), uint16(22), uint16(32), uint32(4), uint16(wavFormatFloat), [14]byte{}}, data.Bytes())
+		r, err := NewWAVReader(bytes.NewReader(wav))
+		require.NoError(t, err)
+		require.Equal(t, PCMFormat{SampleRate: 48000, Channels: 1, BitsPerSample: 32, Float: true}, r.Format())
+
+		samples, err := r.ReadSamples(4)
+		require.NoError(t, err)
+		require.Equal(t, []int16{0, math.MaxInt16 / 2, math.MinInt16, math.MaxInt16}, samples)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		// A-law
+		wav := writeWAV(t, []interface{}{uint16(6), uint16(1), uint32(8000), uint32(8000), uint16(1), uint16(8)}, []byte{0xd5})
+		_, err := NewWAVReader(bytes.NewReader(wav))
+		require.ErrorIs(t, err, ErrUnsupportedPCMFormat)
+
+		_, err = NewWAVReader(bytes.NewReader([]byte("OggS")))
+		require.ErrorIs(t, err, ErrInvalidWAV)
+	})
+}
+
+func TestPCMReader(t *testing.T) {
+	for _, tc := range []struct {
+		bitsPerSample int
+		data          []byte
+	}{
+		{8, []byte{0x80, 0xff, 0x00}},
+		{16, []byte{0x00, 0x00, 0x00, 0x7f, 0x00, 0x80}},
+		{24, []byte{0xff, 0x00, 0x00, 0xff, 0x00, 0x7f, 0xff, 0x00, 0x80}},
+		{32, []byte{0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x7f, 0xff, 0xff, 0x00, 0x80}},
+	} {
+		r, err := NewPCMReader(bytes.NewReader(tc.data), PCMFormat{SampleRate: 8000, Channels: 1, BitsPerSample: tc.bitsPerSample})
+		require.NoError(t, err)
+		samples, err := r.ReadSamples(3)
+		require.NoError(t, err)
+		require.Equal(t, []int16{0, 0x7f00, math.MinInt16}, samples, "%d bits", tc.bitsPerSample)
+	}
+
+	// 16 bit by default
+	r, err := NewPCMReader(bytes.NewReader(nil), PCMFormat{SampleRate: 8000, Channels: 1})
+	require.NoError(t, err)
+	require.Equal(t, 16, r.Format().BitsPerSample)
+
+	_, err = NewPCMReader(bytes.NewReader(nil), PCMFormat{SampleRate: 8000, Channels: 1, BitsPerSample: 12})
+	require.ErrorIs(t, err, ErrUnsupportedPCMFormat)
+}
+
+func TestDownmixToMono(t *testing.T) {
+	require.Equal(t, []int16{15, -1}, DownmixToMono([]int16{10, 20, 1, -3}, 2))
+	require.Equal(t, []int16{1, 2}, DownmixToMono([]int16{1, 2}, 1))
+}
+
+func TestResampler(t *testing.T) {
+	tone := func(rate, samples int) []int16 {
+		pcm := make([]int16, samples)
+		for i := range pcm {
+			pcm[i] = int16(10000 * math.Sin(2*math.Pi*400*float64(i)/float64(rate)))
+		}
+		return pcm
+	}
+	rms := func(pcm []int16) float64 {
+		sum := 0.0
+		for _, s := range pcm {
+			sum += float64(s) * float64(s)
+		}
+		return math.Sqrt(sum / float64(len(pcm)))
+	}
+
+	for _, inputRate := range []int{8000, 16000, 44100, 48000} {
+		r := NewResampler(inputRate, 8000)
+		var out []int16
+		// in 20 ms chunks, the state is kept between them
+		in := tone(inputRate, inputRate)
+		chunk := inputRate / 50
+		for i := 0; i < len(in); i += chunk {
+			out = append(out, r.Resample(in[i:i+chunk])...)
+		}
+		require.InDelta(t, 8000, len(out), 1, "%d Hz", inputRate)
+
+		// compared to the tone generated at 8 kHz, the low-pass filter delays it by less than a sample
+		expected := tone(8000, len(out))
+		require.InDelta(t, rms(expected), rms(out), 200, "%d Hz", inputRate)
+		var diff []int16
+		for i := range out {
+			diff = append(diff, out[i]-expected[i])
+		}
+		require.Less(t, rms(diff), 1000.0, "%d Hz", inputRate)
+	}
+
+	// upsampling
+	out := NewResampler(8000, 16000).Resample([]int16{0, 100, 200})
+	require.Equal(t, []int16{0, 50, 100, 150, 200}, out)
+}
@@ -0,0 +1,147 @@
+package codecs
+
+import (
+	"bufio"
+	"io"
+)
+
+// H265NAL is a NAL unit read from an Annex-B byte stream
+type H265NAL struct {
+	UnitType byte
+	// Data is the NAL unit with its header, without start code
+	Data []byte
+}
+
+// IsVCL checks whether the NAL unit holds a slice of a picture
+func (n *H265NAL) IsVCL() bool {
+	return IsH265VCL(n.Data)
+}
+
+// H265Reader reads NAL units of an H.265 Annex-B byte stream
+type H265Reader struct {
+	r       *bufio.Reader
+	started bool
+	zeros   int
+}
+
+func NewH265Reader(in io.Reader) *H265Reader {
+	return &H265Reader{r: bufio.NewReader(in)}
+}
+
+// NextNAL returns the next NAL unit of the stream, io.EOF at its end
+func (r *H265Reader) NextNAL() (*H265NAL, error) {
+	var nalu []byte
+	for {
+		b, err := r.r.ReadByte()
+		if err == io.EOF && len(nalu) >= h265NALUHeaderSize {
+			// trailing zeros are dropped
+			return &H265NAL{UnitType: h265NALUType(nalu), Data: nalu}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if b == 0 {
+			r.zeros++
+			continue
+		}
+		if b == 1 && r.zeros >= 2 {
+			r.started = true
+			r.zeros = 0
+			if len(nalu) >= h265NALUHeaderSize {
+				return &H265NAL{UnitType: h265NALUType(nalu), Data: nalu}, nil
+			}
+			nalu = nil
+			continue
+		}
+		// bytes before the first start code are ignored
+		if r.started {
+			for ; r.zeros > 0; r.zeros-- {
+				nalu = append(nalu, 0)
+			}
+			nalu = append(nalu, b)
+		}
+		r.zeros = 0
+	}
+}
+
+// maxTemporalUnitSize bounds the temporal units read, so a corrupt size field doesn't allocate the whole memory
+const maxTemporalUnitSize = 16 << 20
+
+// OBUReader reads temporal units of an AV1 stream in the low overhead bitstream format (AV1 spec section 5),
+// e.g. a .obu file. Temporal units start with a temporal delimiter
+type OBUReader struct {
+	r    *bufio.Reader
+	next []byte
+}
+
+func NewOBUReader(in io.Reader) *OBUReader {
+	return &OBUReader{r: bufio.NewReader(in)}
+}
+
+// NextTemporalUnit returns the OBUs of the next temporal unit, io.EOF at the end of the stream
+func (r *OBUReader) NextTemporalUnit() ([]byte, error) {
+	tu := r.next
+	r.next = nil
+	for {
+		o, err := r.readOBU()
+		if err == io.EOF && len(tu) > 0 {
+			return tu, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (o[0]>>3)&0x0f == obuTypeTemporalDelimiter && len(tu) > 0 {
+			r.next = o
+			return tu, nil
+		}
+		if len(tu)+len(o) > maxTemporalUnitSize {
+			return nil, ErrInvalidOBU
+		}
+		tu = append(tu, o...)
+	}
+}
+
+// readOBU reads an OBU, which must have a size field
+func (r *OBUReader) readOBU() ([]byte, error) {
+	header, err := r.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if header&obuHasSizeFieldMask == 0 {
+		return nil, ErrInvalidOBU
+	}
+	o := []byte{header}
+	if header&obuHasExtensionMask != 0 {
+		ext, err := r.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		o = append(o, ext)
+	}
+
+	var size uint64
+	for i := 0; ; i++ {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if i == 8 {
+			return nil, ErrInvalidOBU
+		}
+		o = append(o, b)
+		size |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if size > maxTemporalUnitSize {
+		return nil, ErrInvalidOBU
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return append(o, data...), nil
+}
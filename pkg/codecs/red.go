@@ -0,0 +1,232 @@
+package codecs
+
+import (
+	"errors"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	redBlockHeaderSize   = 4
+	redMaxTimestampDelta = 1<<14 - 1
+	redMaxBlockLength    = 1<<10 - 1
+	// size of the window of sequence numbers REDDecoder remembers
+	redWindowSize = 64
+)
+
+var ErrInvalidRED = errors.New("invalid RED packet")
+
+// REDBlock is a block of a RED packet (RFC 2198)
+type REDBlock struct {
+	PayloadType uint8
+	// TimestampOffset is how much older than the packet the block is, 0 for the primary block
+	TimestampOffset uint16
+	Data            []byte
+}
+
+// UnmarshalRED splits a RED payload into its redundant blocks, oldest first, and its primary block
+func UnmarshalRED(payload []byte) ([]REDBlock, REDBlock, error) {
+	var redundant []REDBlock
+	var lengths []int
+	i := 0
+	for {
+		if i >= len(payload) {
+			return nil, REDBlock{}, ErrInvalidRED
+		}
+		if payload[i]&0x80 == 0 {
+			break
+		}
+		if i+redBlockHeaderSize > len(payload) {
+			return nil, REDBlock{}, ErrInvalidRED
+		}
+		redundant = append(redundant, REDBlock{
+			PayloadType:     payload[i] & 0x7f,
+			TimestampOffset: uint16(payload[i+1])<<6 | uint16(payload[i+2])>>2,
+		})
+		lengths = append(lengths, int(payload[i+2]&0x03)<<8|int(payload[i+3]))
+		i += redBlockHeaderSize
+	}
+	primary := REDBlock{PayloadType: payload[i] & 0x7f}
+	data := payload[i+1:]
+	for j, length := range lengths {
+		if length > len(data) {
+			return nil, REDBlock{}, ErrInvalidRED
+		}
+		redundant[j].Data, data = data[:length], data[length:]
+	}
+	primary.Data = data
+	return redundant, primary, nil
+}
+
+// MarshalRED builds a RED payload of redundant blocks, oldest first, followed by the primary block
+func MarshalRED(redundant []REDBlock, primary REDBlock) ([]byte, error) {
+	size := 1 + len(primary.Data)
+	for _, b := range redundant {
+		if b.TimestampOffset > redMaxTimestampDelta || len(b.Data) > redMaxBlockLength {
+			return nil, ErrInvalidRED
+		}
+		size += redBlockHeaderSize + len(b.Data)
+	}
+
+	out := make([]byte, 0, size)
+	for _, b := range redundant {
+		out = append(out,
+			0x80|b.PayloadType&0x7f,
+			byte(b.TimestampOffset>>6),
+			byte(b.TimestampOffset<<2)|byte(len(b.Data)>>8),
+			byte(len(b.Data)),
+		)
+	}
+	out = append(out, primary.PayloadType&0x7f)
+	for _, b := range redundant {
+		out = append(out, b.Data...)
+	}
+	return append(out, primary.Data...), nil
+}
+
+type redFrame struct {
+	timestamp uint32
+	data      []byte
+}
+
+// REDEncoder adds the previous frames of the primary codec to each packet as redundancy, so a receiver
+// can recover lost packets from the following ones
+type REDEncoder struct {
+	primaryPayloadType uint8
+	distance           int
+	maxSize            int
+	history            []redFrame
+}
+
+// NewREDEncoder creates an encoder carrying up to distance previous frames, as long as the payload stays
+// within maxSize bytes
+func NewREDEncoder(primaryPayloadType uint8, distance int, maxSize int) *REDEncoder {
+	return &REDEncoder{
+		primaryPayloadType: primaryPayloadType,
+		distance:           distance,
+		maxSize:            maxSize,
+	}
+}
+
+// Encode returns the RED payload of a frame of the primary codec sent with timestamp
+func (e *REDEncoder) Encode(payload []byte, timestamp uint32) []byte {
+	var redundant []REDBlock
+	size := 1 + len(payload)
+	// newest first, so the closest frames are kept when the payload is full
+	for i := len(e.history) - 1; i >= 0; i-- {
+		h := e.history[i]
+		offset := timestamp - h.timestamp
+		if offset == 0 || offset > redMaxTimestampDelta || len(h.data) > redMaxBlockLength ||
+			size+redBlockHeaderSize+len(h.data) > e.maxSize {
+			break
+		}
+		size += redBlockHeaderSize + len(h.data)
+		redundant = append([]REDBlock{{
+			PayloadType:     e.primaryPayloadType,
+			TimestampOffset: uint16(offset),
+			Data:            h.data,
+		}}, redundant...)
+	}
+
+	out, err := MarshalRED(redundant, REDBlock{PayloadType: e.primaryPayloadType, Data: payload})
+	if err != nil {
+		// blocks are checked above
+		out = payload
+	}
+
+	if e.distance > 0 {
+		e.history = append(e.history, redFrame{timestamp: timestamp, data: append([]byte{}, payload...)})
+		if len(e.history) > e.distance {
+			e.history = e.history[len(e.history)-e.distance:]
+		}
+	}
+	return out
+}
+
+// REDDecoder extracts the primary packets of a RED stream, and recovers lost ones from the redundant blocks of
+// the following packets. Like browsers do, each redundant block is expected to be the frame of a previous packet
+type REDDecoder struct {
+	initialized bool
+	lastSeq     uint16
+	// bit i is set once lastSeq-i has been output
+	received uint64
+}
+
+func NewREDDecoder() *REDDecoder {
+	return &REDDecoder{}
+}
+
+// Decode returns the packets of the primary codec carried by a RED packet: the recovered packets that were lost,
+// oldest first, then its primary packet. Packets that were already output are not returned again
+func (d *REDDecoder) Decode(pkt *rtp.Packet) ([]*rtp.Packet, error) {
+	redundant, primary, err := UnmarshalRED(pkt.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkts []*rtp.Packet
+	if d.initialized {
+		for i, b := range redundant {
+			seq := pkt.SequenceNumber - uint16(len(redundant)-i)
+			if d.isReceived(seq) {
+				continue
+			}
+			recovered := &rtp.Packet{Header: pkt.Header, Payload: b.Data}
+			recovered.Header.SequenceNumber = seq
+			recovered.Header.Timestamp = pkt.Timestamp - uint32(b.TimestampOffset)
+			recovered.Header.PayloadType = b.PayloadType
+			recovered.Header.Marker = false
+			recovered.Header.Extension = false
+			recovered.Header.Extensions = nil
+			pkts = append(pkts, recovered)
+			d.setReceived(seq)
+		}
+	}
+
+	if d.isReceived(pkt.SequenceNumber) {
+		return pkts, nil
+	}
+	p := &rtp.Packet{Header: pkt.Header, Payload: primary.Data}
+	p.Header.PayloadType = primary.PayloadType
+	d.setReceived(pkt.SequenceNumber)
+	return append(pkts, p), nil
+}
+
+func (d *REDDecoder) isReceived(seq uint16) bool {
+	if !d.initialized {
+		return false
+	}
+	diff := d.lastSeq - seq
+	if diff&0x8000 != 0 {
+		// newer than any packet
+		return false
+	}
+	if diff >= redWindowSize {
+		// too old to be recovered
+		return true
+	}
+	return d.received&(1<<diff) != 0
+}
+
+func (d *REDDecoder) setReceived(seq uint16) {
+	if !d.initialized {
+		// packets before the first one aren't recovered
+		d.initialized = true
+		d.lastSeq = seq
+		d.received = ^uint64(0)
+		return
+	}
+	if diff := seq - d.lastSeq; diff != 0 && diff&0x8000 == 0 {
+		if diff >= redWindowSize {
+			d.received = 0
+		} else {
+			d.received <<= diff
+		}
+		d.received |= 1
+		d.lastSeq = seq
+		return
+	}
+	if diff := d.lastSeq - seq; diff < redWindowSize {
+		d.received |= 1 << diff
+	}
+}
@@ -0,0 +1,64 @@
+package codecs
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestREDMarshal(t *testing.T) {
+	redundant := []REDBlock{
+		{PayloadType: 111, TimestampOffset: 1920, Data: []byte{1, 2, 3}},
+		{PayloadType: 111, TimestampOffset: 960, Data: make([]byte, 300)},
+	}
+	primary := REDBlock{PayloadType: 111, Data: []byte{7, 8}}
+	payload, err := MarshalRED(redundant, primary)
+	require.NoError(t, err)
+	require.Len(t, payload, 2*4+1+3+300+2)
+
+	gotRedundant, gotPrimary, err := UnmarshalRED(payload)
+	require.NoError(t, err)
+	require.Equal(t, redundant, gotRedundant)
+	require.Equal(t, primary, gotPrimary)
+
+	_, err = MarshalRED([]REDBlock{{TimestampOffset: 1 << 14}}, primary)
+	require.ErrorIs(t, err, ErrInvalidRED)
+	_, _, err = UnmarshalRED(payload[:20])
+	require.ErrorIs(t, err, ErrInvalidRED)
+}
+
+func TestREDRecovery(t *testing.T) {
+	encoder := NewREDEncoder(111, 2, 1000)
+	var pkts []*rtp.Packet
+	for i := 0; i < 10; i++ {
+		frame := []byte{byte(i), byte(i)}
+		ts := uint32(i * 960)
+		pkts = append(pkts, &rtp.Packet{
+			Header:  rtp.Header{PayloadType: 63, SequenceNumber: uint16(65530 + i), Timestamp: ts},
+			Payload: encoder.Encode(frame, ts),
+		})
+	}
+	redundant, _, err := UnmarshalRED(pkts[5].Payload)
+	require.NoError(t, err)
+	require.Len(t, redundant, 2)
+	require.Equal(t, uint16(1920), redundant[0].TimestampOffset)
+
+	// packets 3 and 4 are lost, 6 arrives late
+	decoder := NewREDDecoder()
+	var out []*rtp.Packet
+	for _, i := range []int{0, 1, 2, 5, 7, 6, 8, 9} {
+		decoded, err := decoder.Decode(pkts[i])
+		require.NoError(t, err)
+		out = append(out, decoded...)
+	}
+	var order []int
+	for _, p := range out {
+		i := int(p.SequenceNumber - 65530)
+		order = append(order, i)
+		require.Equal(t, uint8(111), p.PayloadType)
+		require.Equal(t, uint32(i*960), p.Timestamp)
+		require.Equal(t, []byte{byte(i), byte(i)}, p.Payload)
+	}
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order)
+}
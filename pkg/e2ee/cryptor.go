@@ -0,0 +1,274 @@
+package e2ee
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	ivLength = 12
+	// IV length and key index
+	trailerLength = 2
+
+	// bytes of a VP8 frame left in the clear, the payload header and for key frames the dimensions
+	vp8KeyFrameUnencryptedBytes   = 10
+	vp8DeltaFrameUnencryptedBytes = 3
+	// the TOC byte of opus
+	audioUnencryptedBytes = 1
+
+	h264NALUTypeSliceNonIDR = 1
+	h264NALUTypeSliceIDR    = 5
+	h264NALUTypeAUD         = 9
+	h264NALUTypeFiller      = 12
+	h265NALUTypeVCLLast     = 31
+
+	emulationPreventionByte = 0x03
+)
+
+var (
+	ErrUnsupportedCodec = errors.New("codec does not support end-to-end encryption")
+	ErrInvalidFrame     = errors.New("invalid encrypted frame")
+	// ErrNoSlice is returned when encrypting H.264 or H.265 NAL units without a slice, e.g. parameter sets written
+	// on their own. They should be encrypted along with the next slice, which receivers get them in the same frame with
+	ErrNoSlice = errors.New("frame has no slice")
+)
+
+var annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+
+// FrameCryptor encrypts the frames of a track published by a participant, or decrypts the frames of a track
+// subscribed from one. Encrypted frames are laid out as the unencrypted header, the ciphertext with the GCM tag,
+// the IV, the IV length and the key index
+type FrameCryptor struct {
+	keyProvider         *KeyProvider
+	participantIdentity string
+	mimeType            string
+
+	lock      sync.Mutex
+	sendCount uint32
+}
+
+// NewFrameCryptor creates a cryptor for a track of the codec mimeType, using the keys of participantIdentity.
+// AV1 isn't supported, its packetizer needs to parse the whole frame
+func NewFrameCryptor(keyProvider *KeyProvider, participantIdentity string, mimeType string) (*FrameCryptor, error) {
+	mimeType = strings.ToLower(mimeType)
+	switch {
+	case mimeType == strings.ToLower(webrtc.MimeTypeAV1):
+		return nil, ErrUnsupportedCodec
+	case strings.HasPrefix(mimeType, "audio/"), strings.HasPrefix(mimeType, "video/"):
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+
+	var count [4]byte
+	if _, err := rand.Read(count[:]); err != nil {
+		return nil, err
+	}
+	return &FrameCryptor{
+		keyProvider:         keyProvider,
+		participantIdentity: participantIdentity,
+		mimeType:            mimeType,
+		sendCount:           uint32(binary.BigEndian.Uint16(count[:])),
+	}, nil
+}
+
+// Encrypt encrypts a frame with the current key of the participant, ssrc and timestamp make the IV unique
+func (c *FrameCryptor) Encrypt(frame []byte, ssrc uint32, timestamp uint32) ([]byte, error) {
+	keyIndex, aead, err := c.keyProvider.currentKey(c.participantIdentity)
+	if err != nil {
+		return nil, err
+	}
+	if c.isNALU() {
+		// depacketizers output 4 byte start codes, the unencrypted header must match on both ends
+		frame = c.normalizeAnnexB(frame)
+	}
+	unencrypted, err := c.unencryptedBytes(frame)
+	if err == ErrInvalidFrame && c.isNALU() {
+		return nil, ErrNoSlice
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	iv := c.makeIV(ssrc, timestamp)
+	out := make([]byte, 0, len(frame)+aead.Overhead()+ivLength+trailerLength)
+	out = append(out, frame[:unencrypted]...)
+	tail := aead.Seal(nil, iv, frame[unencrypted:], frame[:unencrypted])
+	tail = append(tail, iv...)
+	tail = append(tail, ivLength, byte(keyIndex))
+	if c.isNALU() {
+		// the ciphertext must not contain start codes
+		tail = escapeRBSP(tail)
+	}
+	return append(out, tail...), nil
+}
+
+// Decrypt decrypts a frame with the key of the participant at the index it carries
+func (c *FrameCryptor) Decrypt(frame []byte) ([]byte, error) {
+	unencrypted, err := c.unencryptedBytes(frame)
+	if err != nil {
+		return nil, err
+	}
+	tail := frame[unencrypted:]
+	if c.isNALU() {
+		tail = unescapeRBSP(tail)
+	}
+	if len(tail) < trailerLength {
+		return nil, ErrInvalidFrame
+	}
+	ivLen := int(tail[len(tail)-2])
+	keyIndex := int(tail[len(tail)-1])
+	if ivLen != ivLength || len(tail) < trailerLength+ivLen {
+		return nil, ErrInvalidFrame
+	}
+	iv := tail[len(tail)-trailerLength-ivLen : len(tail)-trailerLength]
+	ciphertext := tail[:len(tail)-trailerLength-ivLen]
+
+	plaintext, err := c.keyProvider.open(c.participantIdentity, keyIndex, iv, ciphertext, frame[:unencrypted])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, unencrypted+len(plaintext))
+	out = append(out, frame[:unencrypted]...)
+	return append(out, plaintext...), nil
+}
+
+// makeIV builds the IV from the ssrc, the timestamp and a frame counter, like clients do
+func (c *FrameCryptor) makeIV(ssrc uint32, timestamp uint32) []byte {
+	c.lock.Lock()
+	sendCount := c.sendCount
+	c.sendCount++
+	c.lock.Unlock()
+
+	iv := make([]byte, ivLength)
+	binary.BigEndian.PutUint32(iv[0:], ssrc)
+	binary.BigEndian.PutUint32(iv[4:], timestamp)
+	binary.BigEndian.PutUint32(iv[8:], timestamp-sendCount%0xffff)
+	return iv
+}
+
+func (c *FrameCryptor) isNALU() bool {
+	return c.mimeType == strings.ToLower(webrtc.MimeTypeH264) || c.mimeType == strings.ToLower(webrtc.MimeTypeH265)
+}
+
+// unencryptedBytes returns the length of the header of a frame left in the clear
+func (c *FrameCryptor) unencryptedBytes(frame []byte) (int, error) {
+	var n int
+	switch c.mimeType {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		if len(frame) == 0 {
+			return 0, ErrInvalidFrame
+		}
+		n = vp8DeltaFrameUnencryptedBytes
+		if frame[0]&0x01 == 0 {
+			n = vp8KeyFrameUnencryptedBytes
+		}
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		n = 0
+	case strings.ToLower(webrtc.MimeTypeH264):
+		// up to the first byte after the header of the first slice
+		n = -1
+		for _, i := range naluIndices(frame) {
+			if t := frame[i] & 0x1f; t == h264NALUTypeSliceIDR || t == h264NALUTypeSliceNonIDR {
+				n = i + 2
+				break
+			}
+		}
+	case strings.ToLower(webrtc.MimeTypeH265):
+		n = -1
+		for _, i := range naluIndices(frame) {
+			if (frame[i]>>1)&0x3f <= h265NALUTypeVCLLast {
+				n = i + 3
+				break
+			}
+		}
+	default:
+		n = audioUnencryptedBytes
+	}
+	if n < 0 || n > len(frame) {
+		return 0, ErrInvalidFrame
+	}
+	return n, nil
+}
+
+// normalizeAnnexB rewrites a frame with 4 byte start codes, without the NAL units packetizers drop.
+// A frame without start code is a single NAL unit
+func (c *FrameCryptor) normalizeAnnexB(frame []byte) []byte {
+	indices := naluIndices(frame)
+	if len(indices) == 0 {
+		frame = append(append([]byte{}, annexBStartCode...), frame...)
+		indices = []int{len(annexBStartCode)}
+	}
+	out := make([]byte, 0, len(frame)+len(indices))
+	for j, start := range indices {
+		end := len(frame)
+		if j+1 < len(indices) {
+			// before the next start code and the zeros preceding it
+			end = indices[j+1] - 3
+			for end > start && frame[end-1] == 0 {
+				end--
+			}
+		}
+		if end <= start {
+			continue
+		}
+		if c.mimeType == strings.ToLower(webrtc.MimeTypeH264) {
+			if t := frame[start] & 0x1f; t == h264NALUTypeAUD || t == h264NALUTypeFiller {
+				continue
+			}
+		}
+		out = append(out, annexBStartCode...)
+		out = append(out, frame[start:end]...)
+	}
+	return out
+}
+
+// naluIndices returns the indexes of the NAL units of an Annex-B frame, past their start codes
+func naluIndices(frame []byte) []int {
+	var indices []int
+	for i := 0; i+2 < len(frame); i++ {
+		if frame[i] == 0 && frame[i+1] == 0 && frame[i+2] == 1 {
+			indices = append(indices, i+3)
+			i += 2
+		}
+	}
+	return indices
+}
+
+// escapeRBSP inserts emulation prevention bytes, so the data holds no start code
+func escapeRBSP(data []byte) []byte {
+	out := make([]byte, 0, len(data)+len(data)/64)
+	zeros := 0
+	for _, b := range data {
+		if b <= emulationPreventionByte && zeros >= 2 {
+			out = append(out, emulationPreventionByte)
+			zeros = 0
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// unescapeRBSP removes the emulation prevention bytes inserted by escapeRBSP
+func unescapeRBSP(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if i+2 < len(data) && data[i] == 0 && data[i+1] == 0 && data[i+2] == emulationPreventionByte {
+			out = append(out, 0, 0)
+			i += 3
+			continue
+		}
+		out = append(out, data[i])
+		i++
+	}
+	return out
+}
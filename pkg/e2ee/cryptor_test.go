@@ -0,0 +1,176 @@
+package e2ee
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameCryptorRoundTrip(t *testing.T) {
+	provider, err := NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+
+	h264Frame := append([]byte{0, 0, 0, 1, 0x67, 0x42, 0, 0x1f, 0, 0, 0, 1, 0x68, 0xce, 0x3c, 0x80, 0, 0, 0, 1, 0x65, 0x88},
+		bytes.Repeat([]byte{0, 0, 3, 1}, 50)...)
+	h265Frame := append([]byte{0, 0, 0, 1, 0x40, 0x01, 0x0c, 0, 0, 0, 1, 0x26, 0x01, 0xaf},
+		bytes.Repeat([]byte{0, 0, 3}, 50)...)
+	cases := []struct {
+		mimeType    string
+		frame       []byte
+		unencrypted int
+	}{
+		{webrtc.MimeTypeOpus, []byte{0xfc, 1, 2, 3, 4, 5}, 1},
+		{webrtc.MimeTypeVP8, []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0xe0, 0x01, 9, 9, 9}, 10},
+		{webrtc.MimeTypeVP8, []byte{0x31, 0x02, 0x00, 9, 9, 9}, 3},
+		{webrtc.MimeTypeVP9, []byte{0x82, 0x49, 0x83, 0x42}, 0},
+		{webrtc.MimeTypeH264, h264Frame, 22},
+		{webrtc.MimeTypeH265, h265Frame, 14},
+	}
+	for _, c := range cases {
+		t.Run(c.mimeType, func(t *testing.T) {
+			sender, err := NewFrameCryptor(provider, "alice", c.mimeType)
+			require.NoError(t, err)
+			receiver, err := NewFrameCryptor(provider, "alice", c.mimeType)
+			require.NoError(t, err)
+
+			encrypted, err := sender.Encrypt(c.frame, 1234, 90000)
+			require.NoError(t, err)
+			require.Equal(t, c.frame[:c.unencrypted], encrypted[:c.unencrypted])
+			require.NotContains(t, string(encrypted[c.unencrypted:]), string(c.frame[c.unencrypted:]))
+			if c.mimeType == webrtc.MimeTypeH264 || c.mimeType == webrtc.MimeTypeH265 {
+				// no start code past the header
+				require.Len(t, naluIndices(encrypted), len(naluIndices(c.frame[:c.unencrypted])))
+			}
+
+			decrypted, err := receiver.Decrypt(encrypted)
+			require.NoError(t, err)
+			require.Equal(t, c.frame, decrypted)
+		})
+	}
+
+	_, err = NewFrameCryptor(provider, "alice", webrtc.MimeTypeAV1)
+	require.ErrorIs(t, err, ErrUnsupportedCodec)
+}
+
+func TestFrameCryptorNormalizesAnnexB(t *testing.T) {
+	provider, err := NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+	c, err := NewFrameCryptor(provider, "alice", webrtc.MimeTypeH264)
+	require.NoError(t, err)
+
+	// like the packetizer, access unit delimiters are dropped and start codes are 4 bytes long
+	frame := []byte{0, 0, 1, 0x09, 0xf0, 0, 0, 1, 0x67, 0x42, 0, 0, 1, 0x41, 0x9a, 1, 2, 3}
+	encrypted, err := c.Encrypt(frame, 1, 3000)
+	require.NoError(t, err)
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 0, 1, 0x41, 0x9a, 1, 2, 3}, decrypted)
+
+	// frames without a slice can't be encrypted
+	_, err = c.Encrypt([]byte{0, 0, 0, 1, 0x67, 0x42}, 1, 3000)
+	require.ErrorIs(t, err, ErrNoSlice)
+
+	// a NAL unit without start code
+	encrypted, err = c.Encrypt([]byte{0x41, 0x9a, 1, 2, 3}, 1, 6000)
+	require.NoError(t, err)
+	decrypted, err = c.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 1, 0x41, 0x9a, 1, 2, 3}, decrypted)
+}
+
+func TestFrameCryptorUniqueIV(t *testing.T) {
+	provider, err := NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+	c, err := NewFrameCryptor(provider, "alice", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+
+	frame := []byte{0xfc, 1, 2, 3}
+	first, err := c.Encrypt(frame, 1, 960)
+	require.NoError(t, err)
+	second, err := c.Encrypt(frame, 1, 960)
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+}
+
+func TestKeyProviderParticipantKeys(t *testing.T) {
+	provider := NewKeyProvider(KeyProviderOptions{RatchetWindowSize: -1})
+	require.NoError(t, provider.SetKey("alice", []byte("alice-key"), 0))
+	require.NoError(t, provider.SetKey("bob", []byte("bob-key"), 0))
+
+	alice, err := NewFrameCryptor(provider, "alice", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+	encrypted, err := alice.Encrypt([]byte{0xfc, 1, 2, 3}, 1, 960)
+	require.NoError(t, err)
+
+	// frames of alice can't be decrypted with the key of bob
+	bob, err := NewFrameCryptor(provider, "bob", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+	_, err = bob.Decrypt(encrypted)
+	require.ErrorIs(t, err, ErrDecryptionFailed)
+
+	carol, err := NewFrameCryptor(provider, "carol", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+	_, err = carol.Encrypt([]byte{0xfc, 1}, 1, 960)
+	require.ErrorIs(t, err, ErrMissingKey)
+
+	// rotating to another index, frames carry the one they were encrypted with
+	require.NoError(t, provider.SetKey("alice", []byte("alice-key-2"), 1))
+	rotated, err := alice.Encrypt([]byte{0xfc, 4, 5, 6}, 1, 1920)
+	require.NoError(t, err)
+	require.Equal(t, byte(1), rotated[len(rotated)-1])
+	for _, frame := range [][]byte{encrypted, rotated} {
+		_, err := alice.Decrypt(frame)
+		require.NoError(t, err)
+	}
+
+	require.ErrorIs(t, provider.SetKey("alice", []byte("key"), DefaultKeyRingSize), ErrInvalidKeyIndex)
+}
+
+func TestKeyProviderRatchet(t *testing.T) {
+	senderKeys, err := NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+	receiverKeys, err := NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+
+	sender, err := NewFrameCryptor(senderKeys, "alice", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+	receiver, err := NewFrameCryptor(receiverKeys, "alice", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+
+	// the receiver catches up with a sender that ratcheted twice
+	_, err = senderKeys.RatchetSharedKey(0)
+	require.NoError(t, err)
+	material, err := senderKeys.RatchetSharedKey(0)
+	require.NoError(t, err)
+	frame := []byte{0xfc, 1, 2, 3}
+	encrypted, err := sender.Encrypt(frame, 1, 960)
+	require.NoError(t, err)
+	decrypted, err := receiver.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, frame, decrypted)
+
+	// the ratcheted key was kept
+	receiverKeys.lock.RLock()
+	require.Equal(t, material, receiverKeys.keyRings[sharedKeyIdentity].keys[0].material)
+	receiverKeys.lock.RUnlock()
+
+	// a key out of the window is given up on
+	other := NewKeyProvider(KeyProviderOptions{SharedKey: true, RatchetWindowSize: 2})
+	require.NoError(t, other.SetSharedKey([]byte("other"), 0))
+	otherReceiver, err := NewFrameCryptor(other, "alice", webrtc.MimeTypeOpus)
+	require.NoError(t, err)
+	_, err = otherReceiver.Decrypt(encrypted)
+	require.ErrorIs(t, err, ErrDecryptionFailed)
+	other.lock.RLock()
+	require.True(t, other.keyRings[sharedKeyIdentity].keys[0].ratchetFailed)
+	other.lock.RUnlock()
+}
+
+func TestRBSP(t *testing.T) {
+	data := []byte{0, 0, 0, 0, 1, 0, 0, 2, 0, 0, 3, 0, 0, 4, 0}
+	escaped := escapeRBSP(data)
+	require.Empty(t, naluIndices(escaped))
+	require.Equal(t, data, unescapeRBSP(escaped))
+}
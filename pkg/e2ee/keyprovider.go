@@ -0,0 +1,241 @@
+// Package e2ee encrypts media frames end-to-end with AES-GCM, compatible with the frame cryptor of LiveKit clients.
+// Codec headers needed by packetizers and the SFU are left in the clear
+package e2ee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	DefaultRatchetSalt       = "LKFrameEncryptionKey"
+	DefaultRatchetWindowSize = 8
+	DefaultKeyRingSize       = 16
+
+	pbkdf2Iterations  = 100000
+	encryptionKeySize = 16
+	ratchetedKeySize  = 32
+	// keys are shared by all participants in the key ring of the empty identity
+	sharedKeyIdentity = ""
+)
+
+var (
+	ErrMissingKey       = errors.New("no encryption key")
+	ErrInvalidKeyIndex  = errors.New("key index is out of the key ring")
+	ErrDecryptionFailed = errors.New("could not decrypt frame")
+)
+
+type KeyProviderOptions struct {
+	// SharedKey uses the keys set with SetSharedKey for all participants, instead of a key per participant
+	SharedKey bool
+	// RatchetSalt is the salt keys are derived and ratcheted with, DefaultRatchetSalt when empty
+	RatchetSalt []byte
+	// RatchetWindowSize is how many times a key is ratcheted forward when a frame can't be decrypted with it,
+	// following a sender that ratcheted its key. DefaultRatchetWindowSize when 0, negative disables it
+	RatchetWindowSize int
+	// KeyRingSize is the number of key indexes, DefaultKeyRingSize when 0. It can't exceed 256
+	KeyRingSize int
+}
+
+// KeyProvider holds the keys frames are encrypted with, either a key shared by all participants or a key per
+// participant identity. Each participant has a ring of keys, frames carry the index of the key they were encrypted with
+type KeyProvider struct {
+	options KeyProviderOptions
+
+	lock     sync.RWMutex
+	keyRings map[string]*keyRing
+}
+
+type keyRing struct {
+	keys    []*keySet
+	current int
+}
+
+type keySet struct {
+	material []byte
+	aead     cipher.AEAD
+	// ratcheting didn't find a key decrypting the frames of the sender, it isn't tried again until the key is set
+	ratchetFailed bool
+}
+
+func NewKeyProvider(opts KeyProviderOptions) *KeyProvider {
+	if len(opts.RatchetSalt) == 0 {
+		opts.RatchetSalt = []byte(DefaultRatchetSalt)
+	}
+	if opts.RatchetWindowSize == 0 {
+		opts.RatchetWindowSize = DefaultRatchetWindowSize
+	}
+	if opts.KeyRingSize <= 0 || opts.KeyRingSize > 256 {
+		opts.KeyRingSize = DefaultKeyRingSize
+	}
+	return &KeyProvider{
+		options:  opts,
+		keyRings: make(map[string]*keyRing),
+	}
+}
+
+// NewSharedKeyProvider creates a provider encrypting the frames of all participants with key, at index 0
+func NewSharedKeyProvider(key []byte) (*KeyProvider, error) {
+	p := NewKeyProvider(KeyProviderOptions{SharedKey: true})
+	if err := p.SetSharedKey(key, 0); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// IsSharedKey returns true when all participants use the same keys
+func (p *KeyProvider) IsSharedKey() bool {
+	return p.options.SharedKey
+}
+
+// SetSharedKey sets the key of all participants at keyIndex, frames are encrypted with it from now on
+func (p *KeyProvider) SetSharedKey(key []byte, keyIndex int) error {
+	return p.SetKey(sharedKeyIdentity, key, keyIndex)
+}
+
+// SetKey sets the key of a participant at keyIndex, its frames are encrypted with it from now on.
+// The identity is ignored with shared keys
+func (p *KeyProvider) SetKey(participantIdentity string, key []byte, keyIndex int) error {
+	if keyIndex < 0 || keyIndex >= p.options.KeyRingSize {
+		return ErrInvalidKeyIndex
+	}
+	ks, err := p.deriveKeySet(key)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ring := p.keyRing(participantIdentity, true)
+	ring.keys[keyIndex] = ks
+	ring.current = keyIndex
+	return nil
+}
+
+// RatchetSharedKey replaces the shared key at keyIndex with one derived from it, and returns the new key material
+func (p *KeyProvider) RatchetSharedKey(keyIndex int) ([]byte, error) {
+	return p.RatchetKey(sharedKeyIdentity, keyIndex)
+}
+
+// RatchetKey replaces the key of a participant at keyIndex with one derived from it, and returns the new key
+// material. Receivers follow by ratcheting their copy of the key when frames can't be decrypted with it
+func (p *KeyProvider) RatchetKey(participantIdentity string, keyIndex int) ([]byte, error) {
+	if keyIndex < 0 || keyIndex >= p.options.KeyRingSize {
+		return nil, ErrInvalidKeyIndex
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ring := p.keyRing(participantIdentity, false)
+	if ring == nil || ring.keys[keyIndex] == nil {
+		return nil, ErrMissingKey
+	}
+	ks, err := p.deriveKeySet(p.ratchet(ring.keys[keyIndex].material))
+	if err != nil {
+		return nil, err
+	}
+	ring.keys[keyIndex] = ks
+	return ks.material, nil
+}
+
+// currentKey returns the key frames of the participant are encrypted with
+func (p *KeyProvider) currentKey(participantIdentity string) (int, cipher.AEAD, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	ring := p.keyRing(participantIdentity, false)
+	if ring == nil || ring.keys[ring.current] == nil {
+		return 0, nil, ErrMissingKey
+	}
+	return ring.current, ring.keys[ring.current].aead, nil
+}
+
+// open decrypts a frame of the participant with the key at keyIndex. When it fails, the key is ratcheted forward
+// up to the ratchet window, and replaced by the ratcheted key that succeeds
+func (p *KeyProvider) open(participantIdentity string, keyIndex int, iv, ciphertext, additionalData []byte) ([]byte, error) {
+	if keyIndex >= p.options.KeyRingSize {
+		return nil, ErrInvalidKeyIndex
+	}
+	p.lock.RLock()
+	var ks *keySet
+	ratchetFailed := false
+	if ring := p.keyRing(participantIdentity, false); ring != nil && ring.keys[keyIndex] != nil {
+		ks = ring.keys[keyIndex]
+		ratchetFailed = ks.ratchetFailed
+	}
+	p.lock.RUnlock()
+	if ks == nil {
+		return nil, ErrMissingKey
+	}
+
+	plaintext, err := ks.aead.Open(nil, iv, ciphertext, additionalData)
+	if err == nil {
+		return plaintext, nil
+	}
+	if p.options.RatchetWindowSize < 0 || ratchetFailed {
+		return nil, ErrDecryptionFailed
+	}
+
+	material := ks.material
+	for i := 0; i < p.options.RatchetWindowSize; i++ {
+		material = p.ratchet(material)
+		ratcheted, err := p.deriveKeySet(material)
+		if err != nil {
+			return nil, err
+		}
+		if plaintext, err = ratcheted.aead.Open(nil, iv, ciphertext, additionalData); err != nil {
+			continue
+		}
+
+		p.lock.Lock()
+		// unless the key was changed meanwhile
+		if ring := p.keyRing(participantIdentity, false); ring != nil && ring.keys[keyIndex] == ks {
+			ring.keys[keyIndex] = ratcheted
+		}
+		p.lock.Unlock()
+		return plaintext, nil
+	}
+
+	p.lock.Lock()
+	ks.ratchetFailed = true
+	p.lock.Unlock()
+	return nil, ErrDecryptionFailed
+}
+
+// keyRing returns the key ring of a participant, must be called with the lock held
+func (p *KeyProvider) keyRing(participantIdentity string, create bool) *keyRing {
+	if p.options.SharedKey {
+		participantIdentity = sharedKeyIdentity
+	}
+	ring := p.keyRings[participantIdentity]
+	if ring == nil && create {
+		ring = &keyRing{keys: make([]*keySet, p.options.KeyRingSize)}
+		p.keyRings[participantIdentity] = ring
+	}
+	return ring
+}
+
+// deriveKeySet derives the AES-GCM key from key material with PBKDF2, like the WebCrypto deriveKey of clients
+func (p *KeyProvider) deriveKeySet(material []byte) (*keySet, error) {
+	key := pbkdf2.Key(material, p.options.RatchetSalt, pbkdf2Iterations, encryptionKeySize, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keySet{
+		material: append([]byte{}, material...),
+		aead:     aead,
+	}, nil
+}
+
+func (p *KeyProvider) ratchet(material []byte) []byte {
+	return pbkdf2.Key(material, p.options.RatchetSalt, pbkdf2Iterations, ratchetedKeySize, sha256.New)
+}
@@ -0,0 +1,118 @@
+package interceptor
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// AudioLevelHandler is called with the level, in -dBov, and the voice activity flag of each received packet.
+// It runs in the read path of the stream, as each packet is read
+type AudioLevelHandler func(level uint8, voice bool)
+
+// AudioLevelInterceptorFactory reads the audio level header extension (RFC 6464) of the audio streams received
+// by a peer connection
+type AudioLevelInterceptorFactory struct {
+	lock         sync.Mutex
+	interceptors []*AudioLevelInterceptor
+}
+
+// NewInterceptor constructs a new AudioLevelInterceptor
+func (f *AudioLevelInterceptorFactory) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	i := NewAudioLevelInterceptor()
+
+	f.lock.Lock()
+	f.interceptors = append(f.interceptors, i)
+	f.lock.Unlock()
+	return i, nil
+}
+
+// OnAudioLevel sets the handler of the levels of a received stream, until it is unbound. nil removes it
+func (f *AudioLevelInterceptorFactory) OnAudioLevel(ssrc uint32, handler AudioLevelHandler) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, i := range f.interceptors {
+		i.OnAudioLevel(ssrc, handler)
+	}
+}
+
+// AudioLevelInterceptor passes the levels of received audio streams carrying the audio level extension to their
+// handlers
+type AudioLevelInterceptor struct {
+	interceptor.NoOp
+	lock     sync.Mutex
+	handlers map[uint32]AudioLevelHandler
+}
+
+func NewAudioLevelInterceptor() *AudioLevelInterceptor {
+	return &AudioLevelInterceptor{
+		handlers: make(map[uint32]AudioLevelHandler),
+	}
+}
+
+func (l *AudioLevelInterceptor) OnAudioLevel(ssrc uint32, handler AudioLevelHandler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if handler == nil {
+		delete(l.handlers, ssrc)
+	} else {
+		l.handlers[ssrc] = handler
+	}
+}
+
+func (l *AudioLevelInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	extID := audioLevelExtensionID(info)
+	if extID == 0 {
+		return reader
+	}
+	ssrc := info.SSRC
+
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		i, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		header, err := attr.GetRTPHeader(b[:i])
+		if err != nil {
+			return 0, nil, err
+		}
+		data := header.GetExtension(extID)
+		if data == nil {
+			return i, attr, nil
+		}
+
+		l.lock.Lock()
+		handler := l.handlers[ssrc]
+		l.lock.Unlock()
+		var ext rtp.AudioLevelExtension
+		if handler != nil && ext.Unmarshal(data) == nil {
+			handler(ext.Level, ext.Voice)
+		}
+		return i, attr, nil
+	})
+}
+
+func (l *AudioLevelInterceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	l.OnAudioLevel(info.SSRC, nil)
+}
+
+// audioLevelExtensionID returns the id of the audio level extension negotiated for an audio stream, 0 if there's none
+func audioLevelExtensionID(info *interceptor.StreamInfo) uint8 {
+	if !strings.HasPrefix(strings.ToLower(info.MimeType), "audio/") {
+		return 0
+	}
+	for _, ext := range info.RTPHeaderExtensions {
+		if ext.URI == sdp.AudioLevelURI {
+			return uint8(ext.ID)
+		}
+	}
+	return 0
+}
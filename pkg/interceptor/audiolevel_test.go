@@ -0,0 +1,69 @@
+package interceptor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudioLevelInterceptor(t *testing.T) {
+	f := &AudioLevelInterceptorFactory{}
+	i, err := f.NewInterceptor("")
+	require.NoError(t, err)
+
+	extensions := []interceptor.RTPHeaderExtension{{URI: sdp.AudioLevelURI, ID: 3}}
+	audio := NewMockStream(&interceptor.StreamInfo{SSRC: 1, MimeType: "audio/opus", RTPHeaderExtensions: extensions}, i)
+	defer func() {
+		require.NoError(t, audio.Close())
+	}()
+	video := NewMockStream(&interceptor.StreamInfo{SSRC: 2, MimeType: "video/VP8", RTPHeaderExtensions: extensions}, i)
+	defer func() {
+		require.NoError(t, video.Close())
+	}()
+
+	type level struct {
+		level uint8
+		voice bool
+	}
+	levels := make(chan level, 10)
+	for _, ssrc := range []uint32{1, 2} {
+		f.OnAudioLevel(ssrc, func(l uint8, voice bool) {
+			levels <- level{l, voice}
+		})
+	}
+
+	receive := func(stream *MockStream, ext *rtp.AudioLevelExtension) {
+		pkt := &rtp.Packet{Header: rtp.Header{Version: 2}}
+		if ext != nil {
+			data, err := ext.Marshal()
+			require.NoError(t, err)
+			require.NoError(t, pkt.Header.SetExtension(3, data))
+		}
+		stream.ReceiveRTP(pkt)
+		select {
+		case r := <-stream.ReadRTP():
+			require.NoError(t, r.Err)
+		case <-time.After(time.Second):
+			t.Fatal("receiver rtp packet not found")
+		}
+	}
+
+	receive(audio, &rtp.AudioLevelExtension{Level: 30, Voice: true})
+	require.Equal(t, level{30, true}, <-levels)
+	receive(audio, &rtp.AudioLevelExtension{Level: 90})
+	require.Equal(t, level{90, false}, <-levels)
+
+	// packets without the extension and video streams are ignored
+	receive(audio, nil)
+	receive(video, &rtp.AudioLevelExtension{Level: 10})
+	require.Empty(t, levels)
+
+	// until the handler is removed
+	f.OnAudioLevel(1, nil)
+	receive(audio, &rtp.AudioLevelExtension{Level: 30})
+	require.Empty(t, levels)
+}
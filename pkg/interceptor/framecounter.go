@@ -0,0 +1,131 @@
+package interceptor
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"go.uber.org/atomic"
+)
+
+// FrameCounterInterceptorFactory counts the video frames sent and received by a peer connection
+type FrameCounterInterceptorFactory struct {
+	lock         sync.Mutex
+	interceptors []*FrameCounterInterceptor
+}
+
+// NewInterceptor constructs a new FrameCounterInterceptor
+func (f *FrameCounterInterceptorFactory) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	i := NewFrameCounterInterceptor()
+
+	f.lock.Lock()
+	f.interceptors = append(f.interceptors, i)
+	f.lock.Unlock()
+	return i, nil
+}
+
+// Frames returns the number of frames seen on the stream, false if the stream is unknown or not video
+func (f *FrameCounterInterceptorFactory) Frames(ssrc uint32) (uint64, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, i := range f.interceptors {
+		if frames, ok := i.Frames(ssrc); ok {
+			return frames, true
+		}
+	}
+	return 0, false
+}
+
+// FrameCounterInterceptor counts the RTP packets carrying the marker bit of video streams,
+// which marks the last packet of a frame
+type FrameCounterInterceptor struct {
+	interceptor.NoOp
+	lock     sync.Mutex
+	counters map[uint32]*atomic.Uint64
+}
+
+func NewFrameCounterInterceptor() *FrameCounterInterceptor {
+	return &FrameCounterInterceptor{
+		counters: make(map[uint32]*atomic.Uint64),
+	}
+}
+
+func (f *FrameCounterInterceptor) Frames(ssrc uint32) (uint64, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if c, ok := f.counters[ssrc]; ok {
+		return c.Load(), true
+	}
+	return 0, false
+}
+
+func (f *FrameCounterInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	counter := f.addCounter(info)
+	if counter == nil {
+		return writer
+	}
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if header.Marker {
+			counter.Inc()
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func (f *FrameCounterInterceptor) UnbindLocalStream(info *interceptor.StreamInfo) {
+	f.removeCounter(info)
+}
+
+func (f *FrameCounterInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	counter := f.addCounter(info)
+	if counter == nil {
+		return reader
+	}
+
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		i, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		header, err := attr.GetRTPHeader(b[:i])
+		if err != nil {
+			return 0, nil, err
+		}
+		if header.Marker {
+			counter.Inc()
+		}
+		return i, attr, nil
+	})
+}
+
+func (f *FrameCounterInterceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	f.removeCounter(info)
+}
+
+func (f *FrameCounterInterceptor) addCounter(info *interceptor.StreamInfo) *atomic.Uint64 {
+	if !strings.HasPrefix(strings.ToLower(info.MimeType), "video/") {
+		return nil
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	counter, ok := f.counters[info.SSRC]
+	if !ok {
+		counter = atomic.NewUint64(0)
+		f.counters[info.SSRC] = counter
+	}
+	return counter
+}
+
+func (f *FrameCounterInterceptor) removeCounter(info *interceptor.StreamInfo) {
+	f.lock.Lock()
+	delete(f.counters, info.SSRC)
+	f.lock.Unlock()
+}
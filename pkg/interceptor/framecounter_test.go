@@ -0,0 +1,45 @@
+package interceptor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameCounterInterceptor(t *testing.T) {
+	f := &FrameCounterInterceptorFactory{}
+	i, err := f.NewInterceptor("")
+	require.NoError(t, err)
+
+	video := NewMockStream(&interceptor.StreamInfo{SSRC: 1, MimeType: "video/VP8"}, i)
+	defer func() {
+		require.NoError(t, video.Close())
+	}()
+	audio := NewMockStream(&interceptor.StreamInfo{SSRC: 2, MimeType: "audio/opus"}, i)
+	defer func() {
+		require.NoError(t, audio.Close())
+	}()
+
+	for seq, marker := range []bool{false, true, false, false, true, true} {
+		video.ReceiveRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: uint16(seq), Marker: marker}})
+		select {
+		case r := <-video.ReadRTP():
+			require.NoError(t, r.Err)
+		case <-time.After(time.Second):
+			t.Fatal("receiver rtp packet not found")
+		}
+	}
+	frames, ok := f.Frames(1)
+	require.True(t, ok)
+	require.Equal(t, uint64(3), frames)
+
+	require.NoError(t, video.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true}}))
+	frames, _ = f.Frames(1)
+	require.Equal(t, uint64(4), frames)
+
+	_, ok = f.Frames(2)
+	require.False(t, ok, "audio streams should not be counted")
+}
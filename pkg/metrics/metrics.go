@@ -0,0 +1,142 @@
+// Package metrics exports prometheus metrics of the SDK. Collection is opt-in,
+// nothing is recorded until Register is called.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+)
+
+const (
+	namespace = "live_sdk"
+
+	ReconnectTypeResume  = "resume"
+	ReconnectTypeRestart = "restart"
+
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+var (
+	enabled atomic.Bool
+
+	joinLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "join_latency_seconds",
+		Help:      "Time taken to join a room, until the first peer connection is connected",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20},
+	})
+	reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Reconnect attempts by type (resume/restart) and result",
+	}, []string{"type", "result"})
+	signalMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signal_messages_total",
+		Help:      "Signal messages received from the server by type",
+	}, []string{"type"})
+	signalRTT = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "signal_rtt_seconds",
+		Help:      "Round trip time of pings on the signal connection",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2},
+	})
+	dataPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "data_packets_total",
+		Help:      "Data packets sent and received by kind",
+	}, []string{"direction", "kind"})
+	packetsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "track_packets_dropped_total",
+		Help:      "RTP packets of subscribed tracks dropped by the jitter buffer of their sample reader, by track",
+	}, []string{"track"})
+
+	collectors = []prometheus.Collector{joinLatency, reconnects, signalMessages, signalRTT, dataPackets, packetsDropped}
+)
+
+// Register registers the SDK collectors and starts recording, registerer defaults to prometheus.DefaultRegisterer
+func Register(registerer prometheus.Registerer) error {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	for _, c := range collectors {
+		if err := registerer.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				return err
+			}
+		}
+	}
+	enabled.Store(true)
+	return nil
+}
+
+// Unregister stops recording and removes the SDK collectors from registerer
+func Unregister(registerer prometheus.Registerer) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	enabled.Store(false)
+	for _, c := range collectors {
+		registerer.Unregister(c)
+	}
+}
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+func RecordJoin(latency time.Duration) {
+	if !enabled.Load() {
+		return
+	}
+	joinLatency.Observe(latency.Seconds())
+}
+
+func RecordReconnect(reconnectType string, success bool) {
+	if !enabled.Load() {
+		return
+	}
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	reconnects.WithLabelValues(reconnectType, result).Inc()
+}
+
+func RecordSignalMessage(messageType string) {
+	if !enabled.Load() {
+		return
+	}
+	signalMessages.WithLabelValues(messageType).Inc()
+}
+
+func RecordSignalRTT(rtt time.Duration) {
+	if !enabled.Load() {
+		return
+	}
+	signalRTT.Observe(rtt.Seconds())
+}
+
+func RecordDataPacket(direction string, kind string) {
+	if !enabled.Load() {
+		return
+	}
+	dataPackets.WithLabelValues(direction, kind).Inc()
+}
+
+// RecordPacketDropped counts a packet of a track dropped by the jitter buffer, the counters are kept until RemoveTrack
+func RecordPacketDropped(trackSID string) {
+	if !enabled.Load() {
+		return
+	}
+	packetsDropped.WithLabelValues(trackSID).Inc()
+}
+
+// RemoveTrack deletes the drop counters of a track that's gone
+func RemoveTrack(trackSID string) {
+	packetsDropped.DeleteLabelValues(trackSID)
+}
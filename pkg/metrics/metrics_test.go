@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsOptIn(t *testing.T) {
+	RecordSignalMessage("offer")
+	require.Zero(t, testutil.ToFloat64(signalMessages.WithLabelValues("offer")), "recorded before Register")
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, Register(registry))
+	defer Unregister(registry)
+	// registering again is harmless
+	require.NoError(t, Register(registry))
+
+	RecordSignalMessage("offer")
+	RecordSignalMessage("offer")
+	RecordReconnect(ReconnectTypeResume, true)
+	RecordReconnect(ReconnectTypeRestart, false)
+	RecordDataPacket(DirectionSent, "RELIABLE")
+	RecordJoin(300 * time.Millisecond)
+
+	require.Equal(t, float64(2), testutil.ToFloat64(signalMessages.WithLabelValues("offer")))
+	require.Equal(t, float64(1), testutil.ToFloat64(reconnects.WithLabelValues(ReconnectTypeResume, "success")))
+	require.Equal(t, float64(1), testutil.ToFloat64(reconnects.WithLabelValues(ReconnectTypeRestart, "failure")))
+	require.Equal(t, float64(1), testutil.ToFloat64(dataPackets.WithLabelValues(DirectionSent, "RELIABLE")))
+	require.Equal(t, 1, testutil.CollectAndCount(joinLatency))
+
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	require.NotZero(t, count)
+}
+
+func TestPacketsDropped(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, Register(registry))
+	defer Unregister(registry)
+
+	RecordPacketDropped("TR_test")
+	RecordPacketDropped("TR_test")
+	RecordPacketDropped("TR_other")
+	require.Equal(t, float64(2), testutil.ToFloat64(packetsDropped.WithLabelValues("TR_test")))
+	require.Equal(t, 2, testutil.CollectAndCount(packetsDropped))
+
+	RemoveTrack("TR_test")
+	require.Equal(t, 1, testutil.CollectAndCount(packetsDropped))
+	RemoveTrack("TR_other")
+	require.Zero(t, testutil.CollectAndCount(packetsDropped))
+}
@@ -0,0 +1,149 @@
+package recorder
+
+import (
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/liuhailove/live-sdk-go/pkg/codecs"
+)
+
+const (
+	h264NALUSlice = 1
+	h264NALUIDR   = 5
+	h264NALUSPS   = 7
+	h264NALUPPS   = 8
+	h264NALUAUD   = 9
+)
+
+// isKeyFrame tells whether decoding can start at the depacketized frame, audio frames always are
+func isKeyFrame(mimeType string, frame []byte) bool {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		// P bit of the frame tag is 0 for key frames
+		return len(frame) > 0 && frame[0]&0x01 == 0
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		_, _, key := parseVP9Header(frame)
+		return key
+	case strings.ToLower(webrtc.MimeTypeH264):
+		for _, nalu := range splitAnnexB(frame) {
+			if len(nalu) > 0 && nalu[0]&0x1f == h264NALUIDR {
+				return true
+			}
+		}
+		return false
+	case strings.ToLower(webrtc.MimeTypeH265):
+		return codecs.IsH265KeyFrame(frame)
+	case strings.ToLower(webrtc.MimeTypeAV1):
+		return codecs.IsAV1KeyFrame(frame)
+	default:
+		return true
+	}
+}
+
+// frameDimensions returns the size of a key frame, 0 if unknown
+func frameDimensions(mimeType string, frame []byte) (uint32, uint32) {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		// 3 bytes frame tag, 3 bytes start code, then 14 bits width and height with 2 bits scale
+		if len(frame) < 10 || frame[0]&0x01 != 0 || frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+			return 0, 0
+		}
+		width := uint32(frame[6]) | uint32(frame[7]&0x3f)<<8
+		height := uint32(frame[8]) | uint32(frame[9]&0x3f)<<8
+		return width, height
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		width, height, _ := parseVP9Header(frame)
+		return width, height
+	default:
+		return 0, 0
+	}
+}
+
+// parseVP9Header reads the uncompressed header of a VP9 frame, dimensions are only known for key frames
+func parseVP9Header(frame []byte) (uint32, uint32, bool) {
+	r := &bitReader{data: frame}
+	if r.read(2) != 2 { // frame marker
+		return 0, 0, false
+	}
+	profile := r.read(1) | r.read(1)<<1
+	if profile == 3 {
+		r.read(1)
+	}
+	if r.read(1) == 1 { // show existing frame
+		return 0, 0, false
+	}
+	if r.read(1) != 0 { // frame type, 0 is a key frame
+		return 0, 0, false
+	}
+	r.read(2) // show frame, error resilient mode
+	if r.read(24) != 0x498342 {
+		return 0, 0, false
+	}
+	// color config
+	if profile >= 2 {
+		r.read(1) // ten or twelve bit
+	}
+	if colorSpace := r.read(3); colorSpace != 7 { // not sRGB
+		r.read(1) // color range
+		if profile == 1 || profile == 3 {
+			r.read(3) // subsampling x/y, reserved
+		}
+	} else if profile == 1 || profile == 3 {
+		r.read(1)
+	}
+	width := r.read(16) + 1
+	height := r.read(16) + 1
+	if r.overflow {
+		return 0, 0, true
+	}
+	return width, height, true
+}
+
+type bitReader struct {
+	data     []byte
+	pos      int
+	overflow bool
+}
+
+func (r *bitReader) read(bits int) uint32 {
+	var v uint32
+	for i := 0; i < bits; i++ {
+		if r.pos >= len(r.data)*8 {
+			r.overflow = true
+			return 0
+		}
+		bit := (r.data[r.pos/8] >> (7 - uint(r.pos%8))) & 0x01
+		v = v<<1 | uint32(bit)
+		r.pos++
+	}
+	return v
+}
+
+// splitAnnexB returns the NAL units of an Annex-B byte stream, without start codes
+func splitAnnexB(data []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			end := i
+			if end > start && data[end-1] == 0 {
+				// 4 byte start code
+				end--
+			}
+			nalus = append(nalus, data[start:end])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(data) {
+		nalus = append(nalus, data[start:])
+	} else if start < 0 && len(data) > 0 {
+		// no start code, a single NAL unit
+		nalus = append(nalus, data)
+	}
+	return nalus
+}
@@ -0,0 +1,403 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+const (
+	mp4VideoTimescale = 90000
+	mp4AudioTimescale = 48000
+	mp4MovieTimescale = 1000
+
+	mp4SampleFlagsKey    = 0x02000000 // depends on no other sample
+	mp4SampleFlagsNonKey = 0x01010000 // depends on others, non sync sample
+
+	// fragments are cut on video key frames, or after this long
+	mp4VideoFragmentDuration = 5 * time.Second
+	mp4AudioFragmentDuration = time.Second
+)
+
+type mp4Sample struct {
+	data     []byte
+	decode   uint64
+	duration uint32
+	key      bool
+}
+
+type mp4Track struct {
+	info      *trackInfo
+	timescale uint32
+	// the last sample is held until the next one arrives, to know its duration
+	open      *mp4Sample
+	openFrame time.Duration
+	ready     []*mp4Sample
+}
+
+// mp4Writer writes H264 and Opus into a fragmented mp4, playable while it's being written
+type mp4Writer struct {
+	f      *countingFile
+	tracks []*mp4Track
+	video  int
+
+	started  bool
+	firstPTS time.Duration
+	sps, pps []byte
+	sequence uint32
+}
+
+func newMP4Writer(fileName string, tracks []*trackInfo) (*mp4Writer, error) {
+	w := &mp4Writer{video: -1}
+	for i, t := range tracks {
+		track := &mp4Track{info: t}
+		switch {
+		case strings.EqualFold(t.mimeType, webrtc.MimeTypeH264):
+			track.timescale = mp4VideoTimescale
+			if w.video < 0 {
+				w.video = i
+			}
+		case strings.EqualFold(t.mimeType, webrtc.MimeTypeOpus):
+			track.timescale = mp4AudioTimescale
+		default:
+			return nil, ErrUnsupportedCodec
+		}
+		w.tracks = append(w.tracks, track)
+	}
+
+	f, err := createCountingFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	w.f = f
+	return w, nil
+}
+
+func (w *mp4Writer) writeSample(track int, sample media.Sample, pts time.Duration) error {
+	t := w.tracks[track]
+	key := isKeyFrame(t.info.mimeType, sample.Data)
+	data := sample.Data
+	if track == w.video {
+		var sps, pps []byte
+		data, sps, pps = annexBToAVCC(sample.Data)
+		if w.sps == nil && sps != nil && pps != nil {
+			w.sps, w.pps = sps, pps
+		}
+	}
+
+	if !w.started {
+		// the sample description of H264 needs the parameter sets of the first key frame
+		if w.video >= 0 && (track != w.video || w.sps == nil) {
+			return nil
+		}
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+		w.firstPTS = pts
+		w.started = true
+	}
+
+	elapsed := pts - w.firstPTS
+	if elapsed < 0 || len(data) == 0 {
+		return nil
+	}
+	decode := uint64(elapsed) * uint64(t.timescale) / uint64(time.Second)
+	if t.open != nil {
+		if decode <= t.open.decode {
+			// keep timestamps increasing
+			decode = t.open.decode + 1
+		}
+		t.open.duration = uint32(decode - t.open.decode)
+		t.ready = append(t.ready, t.open)
+	}
+	t.open = &mp4Sample{data: data, decode: decode, key: key}
+	t.openFrame = sample.Duration
+
+	if w.shouldFlush(track, key) {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *mp4Writer) shouldFlush(track int, key bool) bool {
+	if track == w.video && key {
+		return true
+	}
+	fragmentDuration := mp4VideoFragmentDuration
+	if w.video < 0 {
+		fragmentDuration = mp4AudioFragmentDuration
+	}
+	t := w.tracks[track]
+	if len(t.ready) == 0 {
+		return false
+	}
+	last := t.ready[len(t.ready)-1]
+	buffered := last.decode + uint64(last.duration) - t.ready[0].decode
+	return buffered*uint64(time.Second)/uint64(t.timescale) >= uint64(fragmentDuration)
+}
+
+// flush writes the samples with a known duration as a moof/mdat fragment
+func (w *mp4Writer) flush() error {
+	var trafs []*mp4Track
+	for _, t := range w.tracks {
+		if len(t.ready) > 0 {
+			trafs = append(trafs, t)
+		}
+	}
+	if len(trafs) == 0 {
+		return nil
+	}
+	w.sequence++
+
+	// data offsets depend on the size of the moof itself, build it twice
+	moof := w.moof(trafs, nil)
+	offsets := make([]uint32, len(trafs))
+	offset := uint32(len(moof)) + 8
+	for i, t := range trafs {
+		offsets[i] = offset
+		for _, s := range t.ready {
+			offset += uint32(len(s.data))
+		}
+	}
+	moof = w.moof(trafs, offsets)
+
+	var mdat []byte
+	for _, t := range trafs {
+		for _, s := range t.ready {
+			mdat = append(mdat, s.data...)
+		}
+		t.ready = nil
+	}
+	_, err := w.f.Write(concat(moof, mp4Box("mdat", mdat)))
+	return err
+}
+
+func (w *mp4Writer) moof(trafs []*mp4Track, offsets []uint32) []byte {
+	var children [][]byte
+	children = append(children, mp4FullBox("mfhd", 0, 0, u32(w.sequence)))
+	for i, t := range trafs {
+		offset := uint32(0)
+		if offsets != nil {
+			offset = offsets[i]
+		}
+		trun := concat(u32(uint32(len(t.ready))), u32(offset))
+		for _, s := range t.ready {
+			flags := uint32(mp4SampleFlagsNonKey)
+			if s.key {
+				flags = mp4SampleFlagsKey
+			}
+			trun = concat(trun, u32(s.duration), u32(uint32(len(s.data))), u32(flags))
+		}
+		children = append(children, mp4Box("traf",
+			// default-base-is-moof
+			mp4FullBox("tfhd", 0, 0x020000, u32(uint32(w.trackIndex(t)+1))),
+			mp4FullBox("tfdt", 1, 0, u64(t.ready[0].decode)),
+			// data offset, sample duration, size and flags present
+			mp4FullBox("trun", 0, 0x000701, trun),
+		))
+	}
+	return mp4Box("moof", children...)
+}
+
+func (w *mp4Writer) trackIndex(track *mp4Track) int {
+	for i, t := range w.tracks {
+		if t == track {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *mp4Writer) writeHeader() error {
+	ftyp := mp4Box("ftyp", []byte("iso6"), u32(0), []byte("iso6"), []byte("iso5"), []byte("mp41"))
+
+	var traks, trexs [][]byte
+	for i, t := range w.tracks {
+		traks = append(traks, w.trak(uint32(i+1), t))
+		trexs = append(trexs, mp4FullBox("trex", 0, 0, u32(uint32(i+1)), u32(1), u32(0), u32(0), u32(0)))
+	}
+
+	mvhd := mp4FullBox("mvhd", 0, 0,
+		u32(0), u32(0), // creation and modification time
+		u32(mp4MovieTimescale),
+		u32(0),          // duration, unknown for fragmented files
+		u32(0x00010000), // rate
+		u16(0x0100),     // volume
+		make([]byte, 10),
+		mp4Matrix,
+		make([]byte, 24),
+		u32(uint32(len(w.tracks)+1)), // next track id
+	)
+	moov := mp4Box("moov", concat(mvhd, concat(traks...), mp4Box("mvex", trexs...)))
+
+	_, err := w.f.Write(concat(ftyp, moov))
+	return err
+}
+
+func (w *mp4Writer) trak(id uint32, t *mp4Track) []byte {
+	video := t.info.kind == webrtc.RTPCodecTypeVideo
+	volume, handler, handlerName := uint16(0x0100), "soun", "SoundHandler"
+	mediaHeader := mp4FullBox("smhd", 0, 0, u16(0), u16(0))
+	if video {
+		volume, handler, handlerName = 0, "vide", "VideoHandler"
+		mediaHeader = mp4FullBox("vmhd", 0, 1, u16(0), u16(0), u16(0), u16(0))
+	}
+
+	tkhd := mp4FullBox("tkhd", 0, 0x000003, // enabled, in movie
+		u32(0), u32(0), // creation and modification time
+		u32(id),
+		u32(0),
+		u32(0), // duration
+		make([]byte, 8),
+		u16(0), u16(0), // layer, alternate group
+		u16(volume), u16(0),
+		mp4Matrix,
+		u32(t.info.width<<16), u32(t.info.height<<16),
+	)
+	mdhd := mp4FullBox("mdhd", 0, 0,
+		u32(0), u32(0),
+		u32(t.timescale),
+		u32(0),
+		u16(0x55C4), // und
+		u16(0),
+	)
+	hdlr := mp4FullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 12), []byte(handlerName), []byte{0})
+	dinf := mp4Box("dinf", mp4FullBox("dref", 0, 0, u32(1), mp4FullBox("url ", 0, 1)))
+	stbl := mp4Box("stbl",
+		mp4FullBox("stsd", 0, 0, u32(1), w.sampleEntry(t)),
+		mp4FullBox("stts", 0, 0, u32(0)),
+		mp4FullBox("stsc", 0, 0, u32(0)),
+		mp4FullBox("stsz", 0, 0, u32(0), u32(0)),
+		mp4FullBox("stco", 0, 0, u32(0)),
+	)
+
+	return mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr, mp4Box("minf", mediaHeader, dinf, stbl)))
+}
+
+func (w *mp4Writer) sampleEntry(t *mp4Track) []byte {
+	if t.info.kind == webrtc.RTPCodecTypeVideo {
+		avcC := mp4Box("avcC",
+			[]byte{1, w.sps[1], w.sps[2], w.sps[3], 0xff, 0xe1}, // 4 byte lengths, 1 sps
+			u16(uint16(len(w.sps))), w.sps,
+			[]byte{1},
+			u16(uint16(len(w.pps))), w.pps,
+		)
+		return mp4Box("avc1",
+			make([]byte, 6), u16(1), // reserved, data reference index
+			make([]byte, 16),
+			u16(uint16(t.info.width)), u16(uint16(t.info.height)),
+			u32(0x00480000), u32(0x00480000), // 72 dpi
+			u32(0),
+			u16(1), // frame count
+			make([]byte, 32),
+			u16(0x0018), u16(0xffff),
+			avcC,
+		)
+	}
+
+	channels := t.info.channels
+	if channels == 0 {
+		channels = 2
+	}
+	dOps := mp4Box("dOps",
+		[]byte{0, byte(channels)},
+		u16(0), // pre-skip
+		u32(48000),
+		u16(0), // output gain
+		[]byte{0},
+	)
+	return mp4Box("Opus",
+		make([]byte, 6), u16(1),
+		make([]byte, 8),
+		u16(channels), u16(16),
+		u16(0), u16(0),
+		u32(48000<<16),
+		dOps,
+	)
+}
+
+func (w *mp4Writer) size() int64 {
+	return w.f.n
+}
+
+func (w *mp4Writer) close() error {
+	if w.started {
+		for _, t := range w.tracks {
+			if t.open == nil {
+				continue
+			}
+			t.open.duration = uint32(uint64(t.openFrame) * uint64(t.timescale) / uint64(time.Second))
+			if t.open.duration == 0 {
+				t.open.duration = 1
+			}
+			t.ready = append(t.ready, t.open)
+			t.open = nil
+		}
+		if err := w.flush(); err != nil {
+			_ = w.f.Close()
+			return err
+		}
+	}
+	return w.f.Close()
+}
+
+// annexBToAVCC converts a frame to length prefixed NAL units, parameter sets and delimiters are returned separately
+func annexBToAVCC(frame []byte) (data, sps, pps []byte) {
+	for _, nalu := range splitAnnexB(frame) {
+		if len(nalu) == 0 {
+			continue
+		}
+		switch nalu[0] & 0x1f {
+		case h264NALUSPS:
+			sps = nalu
+		case h264NALUPPS:
+			pps = nalu
+		case h264NALUAUD:
+		default:
+			data = append(data, u32(uint32(len(nalu)))...)
+			data = append(data, nalu...)
+		}
+	}
+	if len(sps) < 4 {
+		sps = nil
+	}
+	return
+}
+
+var mp4Matrix = concat(
+	u32(0x00010000), u32(0), u32(0),
+	u32(0), u32(0x00010000), u32(0),
+	u32(0), u32(0), u32(0x40000000),
+)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	data := concat(payload...)
+	return concat(u32(uint32(8+len(data))), []byte(typ), data)
+}
+
+func mp4FullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	header := u32(flags)
+	header[0] = version
+	return mp4Box(typ, append([][]byte{header}, payload...)...)
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
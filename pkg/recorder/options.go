@@ -0,0 +1,55 @@
+package recorder
+
+import (
+	"time"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/liuhailove/live-sdk-go/pkg/synchronizer"
+)
+
+type Option func(r *Recorder)
+
+// WithFormat sets the container, by default it's picked from the codecs of the recorded tracks
+func WithFormat(format Format) Option {
+	return func(r *Recorder) {
+		r.format = format
+	}
+}
+
+// WithMaxDuration starts a new file once the current one is this long.
+// Files of video recordings are rotated on the next key frame
+func WithMaxDuration(maxDuration time.Duration) Option {
+	return func(r *Recorder) {
+		r.maxDuration = maxDuration
+	}
+}
+
+// WithMaxSize starts a new file once the current one reaches this many bytes.
+// Files of video recordings are rotated on the next key frame
+func WithMaxSize(maxSize int64) Option {
+	return func(r *Recorder) {
+		r.maxSize = maxSize
+	}
+}
+
+// WithSynchronizer shares a synchronizer with other recorders or sample readers, to keep their PTS aligned
+func WithSynchronizer(sync *synchronizer.Synchronizer) Option {
+	return func(r *Recorder) {
+		r.sync = sync
+	}
+}
+
+// WithFileClosedHandler sets a callback that's called with the name of every finalized file
+func WithFileClosedHandler(f func(fileName string)) Option {
+	return func(r *Recorder) {
+		r.onFileClosed = f
+	}
+}
+
+// WithLogger sets a logger for write errors
+func WithLogger(l logger.Logger) Option {
+	return func(r *Recorder) {
+		r.logger = l
+	}
+}
@@ -0,0 +1,368 @@
+// Package recorder writes subscribed tracks into container files. Timestamps come from the
+// synchronizer, so tracks of a participant stay in sync and gaps in the media are preserved.
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+
+	lksdk "github.com/liuhailove/live-sdk-go"
+	"github.com/liuhailove/live-sdk-go/pkg/synchronizer"
+)
+
+type Format string
+
+const (
+	// FormatOGG writes a single opus track
+	FormatOGG Format = "ogg"
+	// FormatIVF writes a single VP8 or VP9 track
+	FormatIVF Format = "ivf"
+	// FormatH264 writes a single H264 track as an Annex-B stream, which carries no timestamps
+	FormatH264 Format = "h264"
+	// FormatWebM muxes VP8/VP9 and opus tracks
+	FormatWebM Format = "webm"
+	// FormatMP4 muxes H264 and opus tracks into a fragmented mp4
+	FormatMP4 Format = "mp4"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported recording format")
+	ErrUnsupportedCodec  = errors.New("codec is not supported by the recording format")
+	ErrNoTracks          = errors.New("no subscribed tracks to record")
+	ErrTooManyTracks     = errors.New("recording format only supports a single track")
+)
+
+// Recorder writes the samples of one or more subscribed tracks into a file, rotating it when configured.
+// Files are finalized when Close is called, or once all tracks are unsubscribed, e.g. when the room disconnects
+type Recorder struct {
+	fileName     string
+	format       Format
+	maxDuration  time.Duration
+	maxSize      int64
+	sync         *synchronizer.Synchronizer
+	onFileClosed func(fileName string)
+	logger       logger.Logger
+
+	tracks  []*trackInfo
+	video   int
+	readers []*lksdk.RemoteSampleReader
+
+	lock      sync.Mutex
+	writer    containerWriter
+	fileStart time.Duration
+	files     []string
+	err       error
+	finalized bool
+
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+// RecordTrack records a single subscribed track. Unless a format is set, opus is written to ogg,
+// VP8/VP9 to ivf and H264 to an Annex-B stream
+func RecordTrack(pub *lksdk.RemoteTrackPublication, fileName string, opts ...Option) (*Recorder, error) {
+	return newRecorder([]*lksdk.RemoteTrackPublication{pub}, fileName, opts...)
+}
+
+// RecordParticipant muxes the subscribed audio and video tracks of a participant into a single file,
+// webm for VP8/VP9 and mp4 for H264 unless a format is set. Only the first track of each kind is recorded
+func RecordParticipant(rp *lksdk.RemoteParticipant, fileName string, opts ...Option) (*Recorder, error) {
+	var audio, video *lksdk.RemoteTrackPublication
+	for _, t := range rp.Tracks() {
+		pub, ok := t.(*lksdk.RemoteTrackPublication)
+		if !ok || pub.TrackRemote() == nil {
+			continue
+		}
+		switch pub.Kind() {
+		case lksdk.TrackKindAudio:
+			if audio == nil {
+				audio = pub
+			}
+		case lksdk.TrackKindVideo:
+			if video == nil {
+				video = pub
+			}
+		}
+	}
+
+	var pubs []*lksdk.RemoteTrackPublication
+	if video != nil {
+		pubs = append(pubs, video)
+	}
+	if audio != nil {
+		pubs = append(pubs, audio)
+	}
+	return newRecorder(pubs, fileName, opts...)
+}
+
+func newRecorder(pubs []*lksdk.RemoteTrackPublication, fileName string, opts ...Option) (*Recorder, error) {
+	if len(pubs) == 0 {
+		return nil, ErrNoTracks
+	}
+
+	r := &Recorder{
+		fileName: fileName,
+		video:    -1,
+		logger:   logger.LogRLogger(logr.Discard()),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.sync == nil {
+		r.sync = synchronizer.NewSynchronizer(nil)
+	}
+
+	for i, pub := range pubs {
+		track := pub.TrackRemote()
+		if track == nil {
+			return nil, lksdk.ErrTrackNotSubscribed
+		}
+		codec := track.Codec()
+		info := &trackInfo{
+			kind:      track.Kind(),
+			mimeType:  codec.MimeType,
+			clockRate: codec.ClockRate,
+			channels:  codec.Channels,
+		}
+		if ti := pub.TrackInfo(); ti != nil {
+			info.width, info.height = ti.Width, ti.Height
+		}
+		if info.kind == webrtc.RTPCodecTypeVideo && r.video < 0 {
+			r.video = i
+		}
+		r.tracks = append(r.tracks, info)
+	}
+
+	if r.format == "" {
+		r.format = defaultFormat(r.tracks)
+	}
+	if err := checkFormat(r.format, r.tracks); err != nil {
+		return nil, err
+	}
+
+	for _, pub := range pubs {
+		reader, err := pub.NewSampleReader(lksdk.WithSynchronizer(r.sync))
+		if err != nil {
+			for _, started := range r.readers {
+				started.Close()
+			}
+			return nil, err
+		}
+		r.readers = append(r.readers, reader)
+	}
+
+	r.wg.Add(len(r.readers))
+	for i, reader := range r.readers {
+		go r.readWorker(i, reader)
+	}
+	go func() {
+		r.wg.Wait()
+		r.finalize()
+	}()
+
+	return r, nil
+}
+
+func defaultFormat(tracks []*trackInfo) Format {
+	if len(tracks) > 1 {
+		for _, t := range tracks {
+			if strings.EqualFold(t.mimeType, webrtc.MimeTypeH264) {
+				return FormatMP4
+			}
+		}
+		return FormatWebM
+	}
+
+	switch strings.ToLower(tracks[0].mimeType) {
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return FormatOGG
+	case strings.ToLower(webrtc.MimeTypeVP8), strings.ToLower(webrtc.MimeTypeVP9):
+		return FormatIVF
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return FormatH264
+	default:
+		return ""
+	}
+}
+
+func checkFormat(format Format, tracks []*trackInfo) error {
+	var codecs []string
+	switch format {
+	case FormatOGG:
+		codecs = []string{webrtc.MimeTypeOpus}
+	case FormatIVF:
+		codecs = []string{webrtc.MimeTypeVP8, webrtc.MimeTypeVP9}
+	case FormatH264:
+		codecs = []string{webrtc.MimeTypeH264}
+	case FormatWebM:
+		codecs = []string{webrtc.MimeTypeVP8, webrtc.MimeTypeVP9, webrtc.MimeTypeOpus}
+	case FormatMP4:
+		codecs = []string{webrtc.MimeTypeH264, webrtc.MimeTypeOpus}
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	if len(tracks) > 1 && format != FormatWebM && format != FormatMP4 {
+		return ErrTooManyTracks
+	}
+	for _, t := range tracks {
+		supported := false
+		for _, c := range codecs {
+			if strings.EqualFold(t.mimeType, c) {
+				supported = true
+			}
+		}
+		if !supported {
+			return ErrUnsupportedCodec
+		}
+	}
+	return nil
+}
+
+// Files returns the names of the files written so far, the last one is still open until the recording is done
+func (r *Recorder) Files() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.files...)
+}
+
+// Done is closed once the recording is finalized
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Close stops recording and finalizes the current file
+func (r *Recorder) Close() error {
+	for _, reader := range r.readers {
+		reader.Close()
+	}
+	<-r.done
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+func (r *Recorder) readWorker(track int, reader *lksdk.RemoteSampleReader) {
+	defer r.wg.Done()
+	for {
+		sample, pts, err := reader.ReadSample()
+		if err != nil {
+			if err != io.EOF {
+				r.logger.Warnw("could not read sample", err, "track", reader.Track().ID())
+			}
+			return
+		}
+		if err = r.writeSample(track, sample, pts); err != nil {
+			r.logger.Errorw("could not write sample", err, "file", r.fileName)
+			// stop all tracks, the file is finalized once they're done
+			for _, reader := range r.readers {
+				reader.Close()
+			}
+			return
+		}
+	}
+}
+
+func (r *Recorder) writeSample(track int, sample media.Sample, pts time.Duration) error {
+	keyFrame := isKeyFrame(r.tracks[track].mimeType, sample.Data)
+	// files of video recordings start with a key frame
+	canStart := r.video < 0 || (track == r.video && keyFrame)
+
+	r.lock.Lock()
+	if r.finalized || r.err != nil {
+		r.lock.Unlock()
+		return nil
+	}
+
+	var closed string
+	if r.writer != nil && canStart && r.shouldRotate(pts) {
+		var err error
+		if closed, err = r.closeFile(); err != nil {
+			r.lock.Unlock()
+			return err
+		}
+	}
+	if r.writer == nil {
+		if !canStart {
+			r.lock.Unlock()
+			return nil
+		}
+		if err := r.openFile(pts); err != nil {
+			r.err = err
+			r.lock.Unlock()
+			return err
+		}
+	}
+
+	err := r.writer.writeSample(track, sample, pts)
+	if err != nil {
+		r.err = err
+	}
+	r.lock.Unlock()
+
+	if closed != "" && r.onFileClosed != nil {
+		r.onFileClosed(closed)
+	}
+	return err
+}
+
+func (r *Recorder) shouldRotate(pts time.Duration) bool {
+	return (r.maxDuration > 0 && pts-r.fileStart >= r.maxDuration) ||
+		(r.maxSize > 0 && r.writer.size() >= r.maxSize)
+}
+
+func (r *Recorder) openFile(pts time.Duration) error {
+	fileName := r.fileName
+	if r.maxDuration > 0 || r.maxSize > 0 {
+		ext := filepath.Ext(fileName)
+		fileName = fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(fileName, ext), len(r.files), ext)
+	}
+
+	writer, err := newContainerWriter(r.format, fileName, r.tracks)
+	if err != nil {
+		return err
+	}
+	r.writer = writer
+	r.fileStart = pts
+	r.files = append(r.files, fileName)
+	return nil
+}
+
+// closeFile finalizes the current file and returns its name
+func (r *Recorder) closeFile() (string, error) {
+	fileName := r.files[len(r.files)-1]
+	err := r.writer.close()
+	r.writer = nil
+	if err != nil {
+		r.err = err
+		return "", err
+	}
+	return fileName, nil
+}
+
+func (r *Recorder) finalize() {
+	r.lock.Lock()
+	r.finalized = true
+	var closed string
+	if r.writer != nil {
+		closed, _ = r.closeFile()
+	}
+	r.lock.Unlock()
+
+	if closed != "" && r.onFileClosed != nil {
+		r.onFileClosed(closed)
+	}
+	close(r.done)
+}
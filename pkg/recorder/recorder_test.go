@@ -0,0 +1,215 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+
+	lksdk "github.com/liuhailove/live-sdk-go"
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+// vp8 key frame header of a 640x480 frame
+var vp8KeyFrame = []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0xe0, 0x01, 0xff}
+
+func TestKeyFrame(t *testing.T) {
+	require.True(t, isKeyFrame(webrtc.MimeTypeVP8, vp8KeyFrame))
+	require.False(t, isKeyFrame(webrtc.MimeTypeVP8, []byte{0x11, 0x02}))
+	width, height := frameDimensions(webrtc.MimeTypeVP8, vp8KeyFrame)
+	require.Equal(t, uint32(640), width)
+	require.Equal(t, uint32(480), height)
+
+	idr := []byte{0, 0, 0, 1, 0x67, 0x42, 0xc0, 0x1f, 0, 0, 1, 0x68, 0xce, 0, 0, 0, 1, 0x65, 0x88}
+	require.True(t, isKeyFrame(webrtc.MimeTypeH264, idr))
+	require.False(t, isKeyFrame(webrtc.MimeTypeH264, []byte{0, 0, 0, 1, 0x41, 0x9a}))
+	require.Equal(t, [][]byte{{0x67, 0x42, 0xc0, 0x1f}, {0x68, 0xce}, {0x65, 0x88}}, splitAnnexB(idr))
+
+	// IDR_W_RADL and TRAIL_R slices
+	require.True(t, isKeyFrame(webrtc.MimeTypeH265, []byte{0, 0, 0, 1, 0x26, 0x01, 0xaf}))
+	require.False(t, isKeyFrame(webrtc.MimeTypeH265, []byte{0, 0, 0, 1, 0x02, 0x01, 0xd0}))
+	// frame OBUs with frame_type KEY_FRAME and INTER_FRAME
+	require.True(t, isKeyFrame(webrtc.MimeTypeAV1, []byte{0x32, 0x02, 0x10, 0x00}))
+	require.False(t, isKeyFrame(webrtc.MimeTypeAV1, []byte{0x32, 0x02, 0x30, 0x00}))
+
+	require.True(t, isKeyFrame(webrtc.MimeTypeOpus, []byte{0xfc}))
+}
+
+func TestIVFWriter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.ivf")
+	w, err := newIVFWriter(fileName, &trackInfo{kind: webrtc.RTPCodecTypeVideo, mimeType: webrtc.MimeTypeVP8})
+	require.NoError(t, err)
+	require.NoError(t, w.writeSample(0, media.Sample{Data: vp8KeyFrame}, time.Second))
+	require.NoError(t, w.writeSample(0, media.Sample{Data: []byte{0x11, 0x02}}, time.Second+500*time.Millisecond))
+	require.NoError(t, w.close())
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, "DKIF", string(data[:4]))
+	require.Equal(t, "VP80", string(data[8:12]))
+	require.Equal(t, uint16(640), binary.LittleEndian.Uint16(data[12:]))
+	require.Equal(t, uint32(2), binary.LittleEndian.Uint32(data[24:]))
+
+	second := data[ivfFileHeaderSize+ivfFrameHeaderSize+len(vp8KeyFrame):]
+	require.Equal(t, uint64(500), binary.LittleEndian.Uint64(second[4:]))
+}
+
+func TestWebMWriter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.webm")
+	w, err := newWebMWriter(fileName, []*trackInfo{
+		{kind: webrtc.RTPCodecTypeVideo, mimeType: webrtc.MimeTypeVP8},
+		{kind: webrtc.RTPCodecTypeAudio, mimeType: webrtc.MimeTypeOpus, channels: 2},
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.writeSample(0, media.Sample{Data: vp8KeyFrame}, time.Second))
+	require.NoError(t, w.writeSample(1, media.Sample{Data: []byte{0xfc}, Duration: 20 * time.Millisecond}, time.Second+20*time.Millisecond))
+	require.NoError(t, w.writeSample(0, media.Sample{Data: vp8KeyFrame}, 3*time.Second))
+	require.NoError(t, w.close())
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, ebmlID(ebmlIDHeader), data[:4])
+	require.True(t, bytes.Contains(data, []byte("webm")))
+	require.True(t, bytes.Contains(data, []byte("V_VP8")))
+	require.True(t, bytes.Contains(data, []byte("A_OPUS")))
+	require.True(t, bytes.Contains(data, []byte("OpusHead")))
+	// one cluster per key frame
+	require.Equal(t, 2, bytes.Count(data, ebmlID(mkvIDCluster)))
+
+	// the opus block is 20ms into the first cluster
+	block := []byte{0x82, 0x00, 0x14, 0x80, 0xfc}
+	require.True(t, bytes.Contains(data, block))
+
+	duration := make([]byte, 8)
+	_, err = (bytes.NewReader(data)).ReadAt(duration, w.durationOffset)
+	require.NoError(t, err)
+	require.Equal(t, ebmlFloat(mkvIDDuration, 2000)[3:], duration)
+}
+
+func TestMP4Writer(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.mp4")
+	w, err := newMP4Writer(fileName, []*trackInfo{
+		{kind: webrtc.RTPCodecTypeVideo, mimeType: webrtc.MimeTypeH264, width: 640, height: 480},
+		{kind: webrtc.RTPCodecTypeAudio, mimeType: webrtc.MimeTypeOpus, channels: 2},
+	})
+	require.NoError(t, err)
+
+	idr := []byte{0, 0, 0, 1, 0x67, 0x42, 0xc0, 0x1f, 0, 0, 0, 1, 0x68, 0xce, 0, 0, 0, 1, 0x65, 0x88, 0x84}
+	// audio before the first key frame is dropped
+	require.NoError(t, w.writeSample(1, media.Sample{Data: []byte{0xfc, 0x01}}, 0))
+	require.NoError(t, w.writeSample(0, media.Sample{Data: idr}, 0))
+	require.NoError(t, w.writeSample(1, media.Sample{Data: []byte{0xfc, 0x02}, Duration: 20 * time.Millisecond}, 10*time.Millisecond))
+	require.NoError(t, w.writeSample(0, media.Sample{Data: []byte{0, 0, 0, 1, 0x41, 0x9a}}, 33*time.Millisecond))
+	require.NoError(t, w.writeSample(0, media.Sample{Data: idr}, 66*time.Millisecond))
+	require.NoError(t, w.close())
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+
+	var boxes []string
+	var moofs [][]byte
+	for pos := 0; pos < len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		boxes = append(boxes, typ)
+		if typ == "moof" {
+			moofs = append(moofs, data[pos:pos+size])
+		}
+		pos += size
+	}
+	require.Equal(t, []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat"}, boxes)
+	require.True(t, bytes.Contains(data, []byte("avcC")))
+	require.True(t, bytes.Contains(data, []byte("dOps")))
+
+	// the first fragment has both video samples, without parameter sets
+	moof := moofs[0]
+	trun := moof[bytes.Index(moof, []byte("trun"))+8:]
+	require.Equal(t, uint32(2), binary.BigEndian.Uint32(trun))
+	offset := binary.BigEndian.Uint32(trun[4:])
+	require.Equal(t, uint32(2970), binary.BigEndian.Uint32(trun[8:]))
+	require.Equal(t, uint32(mp4SampleFlagsKey), binary.BigEndian.Uint32(trun[16:]))
+	fragment := data[bytes.Index(data, moof):]
+	require.Equal(t, []byte{0, 0, 0, 3, 0x65, 0x88, 0x84}, fragment[offset:offset+7])
+}
+
+func TestRecordTrack(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	subscribed := make(chan *lksdk.RemoteTrackPublication, 1)
+	room, err := lksdk.ConnectToRoomWithToken(srv.URL(), srv.Token("test-room", "recorder"), &lksdk.RoomCallback{
+		ParticipantCallback: lksdk.ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *lksdk.RemoteTrackPublication, rp *lksdk.RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer room.Disconnect()
+	sess := srv.WaitForSession("recorder", time.Second)
+	require.NotNil(t, sess)
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	track, ti, err := sess.PublishTrack(pi.Sid, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "camera")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(33 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = track.WriteSample(media.Sample{Data: vp8KeyFrame, Duration: 33 * time.Millisecond})
+			}
+		}
+	}()
+
+	var pub *lksdk.RemoteTrackPublication
+	select {
+	case pub = <-subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+
+	closed := make(chan string, 100)
+	rec, err := RecordTrack(pub, filepath.Join(t.TempDir(), "camera.ivf"),
+		WithMaxDuration(300*time.Millisecond),
+		WithFileClosedHandler(func(fileName string) {
+			closed <- fileName
+		}),
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(rec.Files()) >= 3
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// unsubscribing finalizes the recording
+	require.NoError(t, sess.UnpublishTrack(pi.Sid, ti.Sid))
+	select {
+	case <-rec.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording not finalized")
+	}
+	require.NoError(t, rec.Close())
+
+	files := rec.Files()
+	require.Len(t, closed, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		require.NoError(t, err)
+		require.Equal(t, "DKIF", string(data[:4]))
+		require.NotZero(t, binary.LittleEndian.Uint32(data[24:]))
+	}
+}
@@ -0,0 +1,318 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+// matrosska element ids used by the webm writer
+const (
+	ebmlIDHeader             = 0x1A45DFA3
+	ebmlIDVersion            = 0x4286
+	ebmlIDReadVersion        = 0x42F7
+	ebmlIDMaxIDLength        = 0x42F2
+	ebmlIDMaxSizeLength      = 0x42F3
+	ebmlIDDocType            = 0x4282
+	ebmlIDDocTypeVersion     = 0x4287
+	ebmlIDDocTypeReadVersion = 0x4285
+
+	mkvIDSegment           = 0x18538067
+	mkvIDInfo              = 0x1549A966
+	mkvIDTimestampScale    = 0x2AD7B1
+	mkvIDDuration          = 0x4489
+	mkvIDMuxingApp         = 0x4D80
+	mkvIDWritingApp        = 0x5741
+	mkvIDTracks            = 0x1654AE6B
+	mkvIDTrackEntry        = 0xAE
+	mkvIDTrackNumber       = 0xD7
+	mkvIDTrackUID          = 0x73C5
+	mkvIDTrackType         = 0x83
+	mkvIDCodecID           = 0x86
+	mkvIDCodecPrivate      = 0x63A2
+	mkvIDVideo             = 0xE0
+	mkvIDPixelWidth        = 0xB0
+	mkvIDPixelHeight       = 0xBA
+	mkvIDAudio             = 0xE1
+	mkvIDSamplingFrequency = 0xB5
+	mkvIDChannels          = 0x9F
+	mkvIDCluster           = 0x1F43B675
+	mkvIDTimestamp         = 0xE7
+	mkvIDSimpleBlock       = 0xA3
+
+	mkvTrackTypeVideo = 1
+	mkvTrackTypeAudio = 2
+
+	muxingApp = "live-sdk-go"
+
+	// a new cluster is started on video key frames, or after this long for audio only files
+	audioClusterDuration = 5 * time.Second
+	// block timestamps are int16 offsets from the cluster
+	maxClusterDuration = 30 * time.Second
+)
+
+// unknown size, used for the segment and clusters so the file stays readable if it's never finalized
+var ebmlUnknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// webmWriter muxes VP8/VP9 and Opus into a webm file, with millisecond timestamps
+type webmWriter struct {
+	f      *countingFile
+	tracks []*trackInfo
+	video  int
+
+	started        bool
+	firstPTS       time.Duration
+	lastTimestamp  time.Duration
+	clusterStarted bool
+	clusterTime    time.Duration
+	durationOffset int64
+}
+
+func newWebMWriter(fileName string, tracks []*trackInfo) (*webmWriter, error) {
+	w := &webmWriter{
+		tracks: tracks,
+		video:  -1,
+	}
+	for i, t := range tracks {
+		if webmCodecID(t.mimeType) == "" {
+			return nil, ErrUnsupportedCodec
+		}
+		if t.kind == webrtc.RTPCodecTypeVideo && w.video < 0 {
+			w.video = i
+		}
+	}
+
+	f, err := createCountingFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	w.f = f
+	return w, nil
+}
+
+func webmCodecID(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		return "V_VP8"
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		return "V_VP9"
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return "A_OPUS"
+	default:
+		return ""
+	}
+}
+
+func (w *webmWriter) writeSample(track int, sample media.Sample, pts time.Duration) error {
+	keyFrame := isKeyFrame(w.tracks[track].mimeType, sample.Data)
+	if !w.started {
+		if track == w.video {
+			if width, height := frameDimensions(w.tracks[track].mimeType, sample.Data); width != 0 {
+				w.tracks[track].width, w.tracks[track].height = width, height
+			}
+		}
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+		w.firstPTS = pts
+		w.started = true
+	}
+
+	timestamp := pts - w.firstPTS
+	if timestamp < 0 {
+		// late audio from before the first video frame
+		return nil
+	}
+	if !w.clusterStarted ||
+		(track == w.video && keyFrame && timestamp > w.clusterTime) ||
+		(w.video < 0 && timestamp-w.clusterTime >= audioClusterDuration) ||
+		timestamp-w.clusterTime >= maxClusterDuration {
+		if err := w.startCluster(timestamp); err != nil {
+			return err
+		}
+	}
+
+	relative := (timestamp - w.clusterTime) / time.Millisecond
+	if relative < math.MinInt16 {
+		return nil
+	}
+	block := make([]byte, 4, 4+len(sample.Data))
+	block[0] = 0x80 | byte(track+1)
+	binary.BigEndian.PutUint16(block[1:], uint16(int16(relative)))
+	if keyFrame {
+		block[3] = 0x80
+	}
+	block = append(block, sample.Data...)
+	if _, err := w.f.Write(ebmlElement(mkvIDSimpleBlock, block)); err != nil {
+		return err
+	}
+
+	if timestamp+sample.Duration > w.lastTimestamp {
+		w.lastTimestamp = timestamp + sample.Duration
+	}
+	return nil
+}
+
+func (w *webmWriter) writeHeader() error {
+	header := ebmlElement(ebmlIDHeader, concat(
+		ebmlUint(ebmlIDVersion, 1),
+		ebmlUint(ebmlIDReadVersion, 1),
+		ebmlUint(ebmlIDMaxIDLength, 4),
+		ebmlUint(ebmlIDMaxSizeLength, 8),
+		ebmlString(ebmlIDDocType, "webm"),
+		ebmlUint(ebmlIDDocTypeVersion, 4),
+		ebmlUint(ebmlIDDocTypeReadVersion, 2),
+	))
+	segment := append(ebmlID(mkvIDSegment), ebmlUnknownSize...)
+
+	// duration is updated on close
+	infoPrefix := concat(ebmlUint(mkvIDTimestampScale, uint64(time.Millisecond)), ebmlID(mkvIDDuration), ebmlSize(8))
+	info := ebmlElement(mkvIDInfo, concat(
+		infoPrefix,
+		make([]byte, 8),
+		ebmlString(mkvIDMuxingApp, muxingApp),
+		ebmlString(mkvIDWritingApp, muxingApp),
+	))
+	infoHeaderSize := len(ebmlID(mkvIDInfo)) + len(ebmlSize(uint64(len(info))))
+	w.durationOffset = int64(len(header)+len(segment)+infoHeaderSize+len(infoPrefix)) + w.f.n
+
+	var entries [][]byte
+	for i, t := range w.tracks {
+		entry := concat(
+			ebmlUint(mkvIDTrackNumber, uint64(i+1)),
+			ebmlUint(mkvIDTrackUID, uint64(i+1)),
+			ebmlString(mkvIDCodecID, webmCodecID(t.mimeType)),
+		)
+		if t.kind == webrtc.RTPCodecTypeVideo {
+			entry = concat(entry,
+				ebmlUint(mkvIDTrackType, mkvTrackTypeVideo),
+				ebmlElement(mkvIDVideo, concat(
+					ebmlUint(mkvIDPixelWidth, uint64(t.width)),
+					ebmlUint(mkvIDPixelHeight, uint64(t.height)),
+				)),
+			)
+		} else {
+			channels := t.channels
+			if channels == 0 {
+				channels = 2
+			}
+			entry = concat(entry,
+				ebmlUint(mkvIDTrackType, mkvTrackTypeAudio),
+				ebmlElement(mkvIDCodecPrivate, opusHead(channels)),
+				ebmlElement(mkvIDAudio, concat(
+					ebmlFloat(mkvIDSamplingFrequency, 48000),
+					ebmlUint(mkvIDChannels, uint64(channels)),
+				)),
+			)
+		}
+		entries = append(entries, ebmlElement(mkvIDTrackEntry, entry))
+	}
+	tracks := ebmlElement(mkvIDTracks, concat(entries...))
+
+	_, err := w.f.Write(concat(header, segment, info, tracks))
+	return err
+}
+
+func (w *webmWriter) startCluster(timestamp time.Duration) error {
+	w.clusterStarted = true
+	w.clusterTime = timestamp
+	cluster := concat(ebmlID(mkvIDCluster), ebmlUnknownSize, ebmlUint(mkvIDTimestamp, uint64(timestamp/time.Millisecond)))
+	_, err := w.f.Write(cluster)
+	return err
+}
+
+func (w *webmWriter) size() int64 {
+	return w.f.n
+}
+
+func (w *webmWriter) close() error {
+	if w.started {
+		duration := make([]byte, 8)
+		binary.BigEndian.PutUint64(duration, math.Float64bits(float64(w.lastTimestamp/time.Millisecond)))
+		if _, err := w.f.WriteAt(duration, w.durationOffset); err != nil {
+			_ = w.f.Close()
+			return err
+		}
+	}
+	return w.f.Close()
+}
+
+// opusHead is the identification header of an opus stream, RFC 7845
+func opusHead(channels uint16) []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = byte(channels)
+	binary.LittleEndian.PutUint16(head[10:], 0) // pre-skip
+	binary.LittleEndian.PutUint32(head[12:], 48000)
+	return head
+}
+
+func ebmlID(id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFFFF:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFF:
+		return []byte{byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id)}
+	}
+}
+
+// ebmlSize encodes a size as a variable length integer
+func ebmlSize(size uint64) []byte {
+	length := 1
+	// all ones is reserved for unknown sizes
+	for size >= 1<<(7*length)-1 && length < 8 {
+		length++
+	}
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = byte(size)
+		size >>= 8
+	}
+	b[0] |= 0x80 >> (length - 1)
+	return b
+}
+
+func ebmlElement(id uint32, data []byte) []byte {
+	return concat(ebmlID(id), ebmlSize(uint64(len(data))), data)
+}
+
+func ebmlUint(id uint32, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	i := 0
+	for i < 7 && b[i] == 0 {
+		i++
+	}
+	return ebmlElement(id, b[i:])
+}
+
+func ebmlFloat(id uint32, v float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(v))
+	return ebmlElement(id, b)
+}
+
+func ebmlString(id uint32, s string) []byte {
+	return ebmlElement(id, []byte(s))
+}
+
+func concat(parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	b := make([]byte, 0, n)
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
@@ -0,0 +1,235 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+// trackInfo describes a track written to a container
+type trackInfo struct {
+	kind      webrtc.RTPCodecType
+	mimeType  string
+	clockRate uint32
+	channels  uint16
+	width     uint32
+	height    uint32
+}
+
+// containerWriter writes the samples of one or more tracks into a file.
+// If there's a video track, the first sample written is one of its key frames
+type containerWriter interface {
+	writeSample(track int, sample media.Sample, pts time.Duration) error
+	// size is the number of bytes written so far
+	size() int64
+	close() error
+}
+
+func newContainerWriter(format Format, fileName string, tracks []*trackInfo) (containerWriter, error) {
+	switch format {
+	case FormatOGG:
+		return newOggWriter(fileName, tracks[0])
+	case FormatIVF:
+		return newIVFWriter(fileName, tracks[0])
+	case FormatH264:
+		return newH264Writer(fileName)
+	case FormatWebM:
+		return newWebMWriter(fileName, tracks)
+	case FormatMP4:
+		return newMP4Writer(fileName, tracks)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// countingFile keeps track of the bytes written to a file
+type countingFile struct {
+	*os.File
+	n int64
+}
+
+func createCountingFile(fileName string) (*countingFile, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f}, nil
+}
+
+func (f *countingFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.n += int64(n)
+	return n, err
+}
+
+// oggWriter writes opus into an ogg container, granule positions follow the synchronized PTS
+type oggWriter struct {
+	w        *oggwriter.OggWriter
+	firstPTS time.Duration
+	started  bool
+	written  int64
+}
+
+func newOggWriter(fileName string, track *trackInfo) (*oggWriter, error) {
+	channels := track.channels
+	if channels == 0 {
+		channels = 2
+	}
+	w, err := oggwriter.New(fileName, 48000, channels)
+	if err != nil {
+		return nil, err
+	}
+	return &oggWriter{w: w}, nil
+}
+
+func (w *oggWriter) writeSample(_ int, sample media.Sample, pts time.Duration) error {
+	if !w.started {
+		w.firstPTS = pts
+		w.started = true
+	}
+	elapsed := pts - w.firstPTS
+	w.written += int64(len(sample.Data)) + oggPageOverhead
+	return w.w.WriteRTP(&rtp.Packet{
+		Header: rtp.Header{
+			Timestamp: uint32(elapsed * 48000 / time.Second),
+		},
+		Payload: sample.Data,
+	})
+}
+
+// oggPageOverhead is the size of a page header with a single segment, every sample goes in its own page
+const oggPageOverhead = 28
+
+func (w *oggWriter) size() int64 {
+	return w.written
+}
+
+func (w *oggWriter) close() error {
+	return w.w.Close()
+}
+
+const (
+	ivfFileHeaderSize  = 32
+	ivfFrameHeaderSize = 12
+	// timestamps are in milliseconds
+	ivfTimebase = 1000
+)
+
+// ivfWriter writes VP8/VP9 frames with millisecond timestamps taken from the PTS
+// instead of the frame count
+type ivfWriter struct {
+	f          *countingFile
+	fourcc     string
+	track      *trackInfo
+	firstPTS   time.Duration
+	frameCount uint32
+}
+
+func newIVFWriter(fileName string, track *trackInfo) (*ivfWriter, error) {
+	fourcc := "VP80"
+	if strings.EqualFold(track.mimeType, webrtc.MimeTypeVP9) {
+		fourcc = "VP90"
+	}
+	f, err := createCountingFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &ivfWriter{f: f, fourcc: fourcc, track: track}, nil
+}
+
+func (w *ivfWriter) writeSample(_ int, sample media.Sample, pts time.Duration) error {
+	if w.frameCount == 0 {
+		w.firstPTS = pts
+		width, height := w.track.width, w.track.height
+		if fw, fh := frameDimensions(w.track.mimeType, sample.Data); fw != 0 {
+			width, height = fw, fh
+		}
+		if err := w.writeHeader(width, height); err != nil {
+			return err
+		}
+	}
+
+	header := make([]byte, ivfFrameHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:], uint32(len(sample.Data)))
+	binary.LittleEndian.PutUint64(header[4:], uint64((pts-w.firstPTS)/time.Millisecond))
+	if _, err := w.f.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.f.Write(sample.Data); err != nil {
+		return err
+	}
+	w.frameCount++
+	return nil
+}
+
+func (w *ivfWriter) writeHeader(width, height uint32) error {
+	header := make([]byte, ivfFileHeaderSize)
+	copy(header[0:], "DKIF")
+	binary.LittleEndian.PutUint16(header[4:], 0)                 // version
+	binary.LittleEndian.PutUint16(header[6:], ivfFileHeaderSize) // header size
+	copy(header[8:], w.fourcc)
+	binary.LittleEndian.PutUint16(header[12:], uint16(width))
+	binary.LittleEndian.PutUint16(header[14:], uint16(height))
+	binary.LittleEndian.PutUint32(header[16:], ivfTimebase) // rate
+	binary.LittleEndian.PutUint32(header[20:], 1)           // scale
+	_, err := w.f.Write(header)
+	return err
+}
+
+func (w *ivfWriter) size() int64 {
+	return w.f.n
+}
+
+func (w *ivfWriter) close() error {
+	if w.frameCount > 0 {
+		// update the frame count in the header
+		count := make([]byte, 4)
+		binary.LittleEndian.PutUint32(count, w.frameCount)
+		if _, err := w.f.WriteAt(count, 24); err != nil {
+			_ = w.f.Close()
+			return err
+		}
+	}
+	return w.f.Close()
+}
+
+// h264Writer writes an Annex-B elementary stream, which has no timestamps
+type h264Writer struct {
+	f *countingFile
+}
+
+func newH264Writer(fileName string) (*h264Writer, error) {
+	f, err := createCountingFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &h264Writer{f: f}, nil
+}
+
+var annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+
+func (w *h264Writer) writeSample(_ int, sample media.Sample, _ time.Duration) error {
+	for _, nalu := range splitAnnexB(sample.Data) {
+		if _, err := w.f.Write(annexBStartCode); err != nil {
+			return err
+		}
+		if _, err := w.f.Write(nalu); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *h264Writer) size() int64 {
+	return w.f.n
+}
+
+func (w *h264Writer) close() error {
+	return w.f.Close()
+}
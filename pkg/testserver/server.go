@@ -0,0 +1,276 @@
+// Package testserver is an in-process stand-in for a LiveKit server, it speaks the /rtc signal protocol
+// and terminates the peer connections locally, so rooms can be tested without a real deployment.
+// Responses are scripted through Session.
+package testserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/utils"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	DefaultAPIKey    = "devkey"
+	DefaultAPISecret = "secret"
+
+	serverVersion = "0.0.0-testserver"
+)
+
+// JoinRequest describes an incoming signal connection
+type JoinRequest struct {
+	Identity  string
+	Name      string
+	Metadata  string
+	Room      string
+	Grants    *auth.ClaimGrants
+	Reconnect bool
+}
+
+type Server struct {
+	apiKey    string
+	apiSecret string
+	settings  webrtc.SettingEngine
+
+	httpServer *httptest.Server
+	upgrader   websocket.Upgrader
+
+	lock     sync.Mutex
+	rooms    map[string]*livekit.Room
+	sessions map[string]*Session // by identity
+	newSess  *sync.Cond
+
+	// OnJoin can modify the join response before it's sent
+	OnJoin func(req *JoinRequest, res *livekit.JoinResponse)
+	// OnRequest is called for every signal request received, after the server has handled it
+	OnRequest func(s *Session, req *livekit.SignalRequest)
+	// OnDataPacket is called for data packets published by a participant
+	OnDataPacket func(s *Session, packet *livekit.DataPacket)
+	// OnTrack is called when media published by a participant arrives
+	OnTrack func(s *Session, track *webrtc.TrackRemote)
+	// PublishDelay delays the response to an AddTrackRequest, e.g. to answer concurrent publishes out of order
+	PublishDelay func(req *livekit.AddTrackRequest) time.Duration
+}
+
+type Option func(s *Server)
+
+// WithAPIKey sets the key pair tokens are verified against, defaults to DefaultAPIKey and DefaultAPISecret
+func WithAPIKey(apiKey, apiSecret string) Option {
+	return func(s *Server) {
+		s.apiKey = apiKey
+		s.apiSecret = apiSecret
+	}
+}
+
+// WithSettingEngine overrides the settings used for the server side peer connections
+func WithSettingEngine(se webrtc.SettingEngine) Option {
+	return func(s *Server) {
+		s.settings = se
+	}
+}
+
+// New starts a server listening on a local port
+func New(opts ...Option) *Server {
+	s := &Server{
+		apiKey:    DefaultAPIKey,
+		apiSecret: DefaultAPISecret,
+		rooms:     make(map[string]*livekit.Room),
+		sessions:  make(map[string]*Session),
+	}
+	s.settings.SetIncludeLoopbackCandidate(true)
+	s.newSess = sync.NewCond(&s.lock)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rtc", s.handleRTC)
+	mux.HandleFunc("/rtc/validate", s.handleValidate)
+	s.httpServer = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the websocket url clients should connect to
+func (s *Server) URL() string {
+	return strings.Replace(s.httpServer.URL, "http", "ws", 1)
+}
+
+// Token creates a token for the default join grant
+func (s *Server) Token(room, identity string) string {
+	at := auth.NewAccessToken(s.apiKey, s.apiSecret)
+	at.AddGrant(&auth.VideoGrant{RoomJoin: true, Room: room}).
+		SetIdentity(identity)
+	token, _ := at.ToJWT()
+	return token
+}
+
+func (s *Server) Close() {
+	s.lock.Lock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessions = make(map[string]*Session)
+	s.lock.Unlock()
+
+	for _, sess := range sessions {
+		sess.close()
+	}
+	s.httpServer.Close()
+}
+
+// Session returns the current session of a participant, nil if it has not joined
+func (s *Server) Session(identity string) *Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sessions[identity]
+}
+
+// Sessions returns all connected sessions
+func (s *Server) Sessions() []*Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+// WaitForSession blocks until the participant has joined, returns nil on timeout
+func (s *Server) WaitForSession(identity string, timeout time.Duration) *Session {
+	timer := time.AfterFunc(timeout, func() {
+		s.lock.Lock()
+		s.newSess.Broadcast()
+		s.lock.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(timeout)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for {
+		if sess := s.sessions[identity]; sess != nil {
+			return sess
+		}
+		if !time.Now().Before(deadline) {
+			return nil
+		}
+		s.newSess.Wait()
+	}
+}
+
+func (s *Server) parseRequest(r *http.Request) (*JoinRequest, int) {
+	token := r.URL.Query().Get("access_token")
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		token = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	v, err := auth.ParseAPIToken(token)
+	if err != nil || v.APIKey() != s.apiKey {
+		return nil, http.StatusUnauthorized
+	}
+	grants, err := v.Verify(s.apiSecret)
+	if err != nil || grants.Video == nil || !grants.Video.RoomJoin {
+		return nil, http.StatusUnauthorized
+	}
+	return &JoinRequest{
+		Identity:  grants.Identity,
+		Name:      grants.Name,
+		Metadata:  grants.Metadata,
+		Room:      grants.Video.Room,
+		Grants:    grants,
+		Reconnect: r.URL.Query().Get("reconnect") == "1",
+	}, http.StatusOK
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if _, status := s.parseRequest(r); status != http.StatusOK {
+		http.Error(w, "invalid token", status)
+		return
+	}
+	_, _ = w.Write([]byte("success"))
+}
+
+func (s *Server) handleRTC(w http.ResponseWriter, r *http.Request) {
+	req, status := s.parseRequest(r)
+	if status != http.StatusOK {
+		http.Error(w, "invalid token", status)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	existing := s.sessions[req.Identity]
+	s.lock.Unlock()
+
+	if req.Reconnect && existing != nil {
+		existing.resume(conn)
+		return
+	}
+	if existing != nil {
+		existing.close()
+	}
+
+	sess, err := newSession(s, req, conn)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	s.lock.Lock()
+	s.sessions[req.Identity] = sess
+	s.newSess.Broadcast()
+	s.lock.Unlock()
+
+	sess.start()
+}
+
+func (s *Server) joinResponse(req *JoinRequest, sess *Session) *livekit.JoinResponse {
+	s.lock.Lock()
+	room := s.rooms[req.Room]
+	if room == nil {
+		room = &livekit.Room{
+			Sid:          utils.NewGuid(utils.RoomPrefix),
+			Name:         req.Room,
+			CreationTime: time.Now().Unix(),
+		}
+		s.rooms[req.Room] = room
+	}
+	s.lock.Unlock()
+
+	res := &livekit.JoinResponse{
+		Room:          room,
+		Participant:   sess.Info(),
+		ServerVersion: serverVersion,
+		PingInterval:  10,
+		PingTimeout:   20,
+		ServerInfo: &livekit.ServerInfo{
+			Edition:  livekit.ServerInfo_Standard,
+			Version:  serverVersion,
+			Protocol: 8,
+		},
+	}
+	if s.OnJoin != nil {
+		s.OnJoin(req, res)
+	}
+	return res
+}
+
+func (s *Server) removeSession(sess *Session) {
+	s.lock.Lock()
+	if s.sessions[sess.identity] == sess {
+		delete(s.sessions, sess.identity)
+	}
+	s.lock.Unlock()
+}
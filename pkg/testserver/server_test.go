@@ -0,0 +1,33 @@
+package testserver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	validate := func(token string) int {
+		req, err := http.NewRequest(http.MethodGet, strings.Replace(s.URL(), "ws", "http", 1)+"/rtc/validate", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		return res.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, validate(s.Token("room", "identity")))
+
+	at := auth.NewAccessToken(DefaultAPIKey, "wrong-secret")
+	at.AddGrant(&auth.VideoGrant{RoomJoin: true, Room: "room"}).SetIdentity("identity")
+	token, err := at.ToJWT()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, validate(token))
+}
@@ -0,0 +1,758 @@
+package testserver
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/utils"
+	"github.com/pion/interceptor"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	reliableDataChannelName = "_reliable"
+	lossyDataChannelName    = "_lossy"
+)
+
+var (
+	ErrSessionClosed      = errors.New("session is closed")
+	ErrNotConnected       = errors.New("signal connection is not established")
+	ErrDataChannelNotOpen = errors.New("data channel is not open")
+	ErrParticipantUnknown = errors.New("participant is not known to the session")
+	ErrTrackUnknown       = errors.New("track was not published by the session")
+)
+
+// Session is the server side of a single participant's connection.
+// The subscriber peer connection delivers scripted tracks and data to the client,
+// the publisher peer connection receives the client's media and data.
+type Session struct {
+	server   *Server
+	joinReq  *JoinRequest
+	identity string
+
+	writeLock sync.Mutex
+	conn      *websocket.Conn
+	closed    atomic.Bool
+	// when set, pings are not answered, like on a half-open connection
+	ignorePings atomic.Bool
+
+	lock               sync.Mutex
+	info               *livekit.ParticipantInfo
+	remoteParticipants map[string]*livekit.ParticipantInfo
+	requests           []*livekit.SignalRequest
+	// tracks published by the client, by cid
+	publishedTracks map[string]*livekit.TrackInfo
+
+	publisher             *webrtc.PeerConnection
+	subscriber            *webrtc.PeerConnection
+	pendingPubCandidates  []webrtc.ICECandidateInit
+	pendingSubCandidates  []webrtc.ICECandidateInit
+	subscriberRenegotiate bool
+	reliableDC            *webrtc.DataChannel
+	lossyDC               *webrtc.DataChannel
+}
+
+func newSession(server *Server, req *JoinRequest, conn *websocket.Conn) (*Session, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	for _, codec := range []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "audio/red", ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"}, PayloadType: 63},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeAV1, ClockRate: 90000}, PayloadType: 35},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH265, ClockRate: 90000}, PayloadType: 104},
+	} {
+		kind := webrtc.RTPCodecTypeVideo
+		if strings.HasPrefix(codec.MimeType, "audio/") {
+			kind = webrtc.RTPCodecTypeAudio
+		}
+		if err := m.RegisterCodec(codec, kind); err != nil {
+			return nil, err
+		}
+	}
+	for _, ext := range []struct {
+		uri  string
+		kind webrtc.RTPCodecType
+	}{
+		{sdp.AudioLevelURI, webrtc.RTPCodecTypeAudio},
+		{sdp.SDESMidURI, webrtc.RTPCodecTypeVideo},
+		{sdp.SDESRTPStreamIDURI, webrtc.RTPCodecTypeVideo},
+	} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: ext.uri}, ext.kind); err != nil {
+			return nil, err
+		}
+	}
+	i := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
+		return nil, err
+	}
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i), webrtc.WithSettingEngine(server.settings))
+
+	publisher, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, err
+	}
+	subscriber, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		_ = publisher.Close()
+		return nil, err
+	}
+
+	s := &Session{
+		server:   server,
+		joinReq:  req,
+		identity: req.Identity,
+		conn:     conn,
+		info: &livekit.ParticipantInfo{
+			Sid:         utils.NewGuid(utils.ParticipantPrefix),
+			Identity:    req.Identity,
+			Name:        req.Name,
+			Metadata:    req.Metadata,
+			State:       livekit.ParticipantInfo_ACTIVE,
+			JoinedAt:    time.Now().Unix(),
+			Permission:  req.Grants.Video.ToPermission(),
+			IsPublisher: false,
+		},
+		remoteParticipants: make(map[string]*livekit.ParticipantInfo),
+		publishedTracks:    make(map[string]*livekit.TrackInfo),
+		publisher:          publisher,
+		subscriber:         subscriber,
+	}
+
+	publisher.OnICECandidate(func(c *webrtc.ICECandidate) {
+		s.sendCandidate(c, livekit.SignalTarget_PUBLISHER)
+	})
+	subscriber.OnICECandidate(func(c *webrtc.ICECandidate) {
+		s.sendCandidate(c, livekit.SignalTarget_SUBSCRIBER)
+	})
+	publisher.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if f := s.server.OnTrack; f != nil {
+			f(s, track)
+			return
+		}
+		// drain so interceptors keep running
+		go func() {
+			for {
+				if _, _, err := track.ReadRTP(); err != nil {
+					return
+				}
+			}
+		}()
+	})
+	publisher.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			packet := &livekit.DataPacket{}
+			if err := proto.Unmarshal(msg.Data, packet); err != nil {
+				return
+			}
+			if f := s.server.OnDataPacket; f != nil {
+				f(s, packet)
+			}
+		})
+	})
+
+	ordered := true
+	maxRetransmits := uint16(1)
+	if s.reliableDC, err = subscriber.CreateDataChannel(reliableDataChannelName, &webrtc.DataChannelInit{Ordered: &ordered}); err != nil {
+		s.close()
+		return nil, err
+	}
+	if s.lossyDC, err = subscriber.CreateDataChannel(lossyDataChannelName, &webrtc.DataChannelInit{Ordered: &ordered, MaxRetransmits: &maxRetransmits}); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Session) start() {
+	res := s.server.joinResponse(s.joinReq, s)
+	if err := s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Join{Join: res},
+	}); err != nil {
+		s.close()
+		return
+	}
+
+	if err := s.negotiateSubscriber(); err != nil {
+		s.close()
+		return
+	}
+	s.readLoop(s.websocketConn())
+}
+
+// resume swaps the signal connection of a reconnecting client
+func (s *Session) resume(conn *websocket.Conn) {
+	s.writeLock.Lock()
+	prev := s.conn
+	s.conn = conn
+	s.writeLock.Unlock()
+	if prev != nil {
+		_ = prev.Close()
+	}
+
+	if err := s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Reconnect{Reconnect: &livekit.ReconnectResponse{}},
+	}); err != nil {
+		return
+	}
+	s.readLoop(conn)
+}
+
+func (s *Session) readLoop(conn *websocket.Conn) {
+	for !s.closed.Load() {
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			// client might resume over a new connection, keep the session
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		req := &livekit.SignalRequest{}
+		if err := proto.Unmarshal(payload, req); err != nil {
+			continue
+		}
+		s.handleRequest(req)
+	}
+}
+
+func (s *Session) handleRequest(req *livekit.SignalRequest) {
+	s.lock.Lock()
+	s.requests = append(s.requests, req)
+	s.lock.Unlock()
+
+	switch msg := req.Message.(type) {
+	case *livekit.SignalRequest_Offer:
+		s.handleOffer(msg.Offer)
+	case *livekit.SignalRequest_Answer:
+		s.handleAnswer(msg.Answer)
+	case *livekit.SignalRequest_Trickle:
+		s.handleTrickle(msg.Trickle)
+	case *livekit.SignalRequest_AddTrack:
+		s.handleAddTrack(msg.AddTrack)
+	case *livekit.SignalRequest_Mute:
+		s.lock.Lock()
+		for _, ti := range s.info.Tracks {
+			if ti.Sid == msg.Mute.Sid {
+				ti.Muted = msg.Mute.Muted
+			}
+		}
+		s.lock.Unlock()
+	case *livekit.SignalRequest_UpdateMetadata:
+		s.lock.Lock()
+		if msg.UpdateMetadata.Name != "" {
+			s.info.Name = msg.UpdateMetadata.Name
+		}
+		if msg.UpdateMetadata.Metadata != "" {
+			s.info.Metadata = msg.UpdateMetadata.Metadata
+		}
+		s.lock.Unlock()
+		_ = s.SendParticipantUpdate(s.Info())
+	case *livekit.SignalRequest_Ping:
+		if s.ignorePings.Load() {
+			break
+		}
+		// like livekit-server, the legacy pong is the time of the server
+		_ = s.SendResponse(&livekit.SignalResponse{
+			Message: &livekit.SignalResponse_Pong{Pong: time.Now().UnixMilli()},
+		})
+	case *livekit.SignalRequest_PingReq:
+		if s.ignorePings.Load() {
+			break
+		}
+		_ = s.SendResponse(&livekit.SignalResponse{
+			Message: &livekit.SignalResponse_PongResp{PongResp: &livekit.Pong{
+				LastPingTimestamp: msg.PingReq.Timestamp,
+				Timestamp:         time.Now().UnixMilli(),
+			}},
+		})
+	case *livekit.SignalRequest_Leave:
+		s.close()
+	}
+
+	if f := s.server.OnRequest; f != nil {
+		f(s, req)
+	}
+}
+
+func (s *Session) handleOffer(sd *livekit.SessionDescription) {
+	offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: sd.Sdp}
+	if err := s.publisher.SetRemoteDescription(offer); err != nil {
+		return
+	}
+	s.lock.Lock()
+	candidates := s.pendingPubCandidates
+	s.pendingPubCandidates = nil
+	s.lock.Unlock()
+	for _, c := range candidates {
+		_ = s.publisher.AddICECandidate(c)
+	}
+
+	answer, err := s.publisher.CreateAnswer(nil)
+	if err != nil {
+		return
+	}
+	if err := s.publisher.SetLocalDescription(answer); err != nil {
+		return
+	}
+	_ = s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Answer{Answer: &livekit.SessionDescription{
+			Type: answer.Type.String(),
+			Sdp:  answer.SDP,
+		}},
+	})
+}
+
+func (s *Session) handleAnswer(sd *livekit.SessionDescription) {
+	answer := webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: sd.Sdp}
+	s.lock.Lock()
+	if err := s.subscriber.SetRemoteDescription(answer); err != nil {
+		s.lock.Unlock()
+		return
+	}
+	candidates := s.pendingSubCandidates
+	s.pendingSubCandidates = nil
+	renegotiate := s.subscriberRenegotiate
+	s.subscriberRenegotiate = false
+	s.lock.Unlock()
+
+	for _, c := range candidates {
+		_ = s.subscriber.AddICECandidate(c)
+	}
+	if renegotiate {
+		_ = s.negotiateSubscriber()
+	}
+}
+
+func (s *Session) handleTrickle(trickle *livekit.TrickleRequest) {
+	candidate := webrtc.ICECandidateInit{}
+	if err := json.Unmarshal([]byte(trickle.CandidateInit), &candidate); err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch trickle.Target {
+	case livekit.SignalTarget_PUBLISHER:
+		if s.publisher.RemoteDescription() == nil {
+			s.pendingPubCandidates = append(s.pendingPubCandidates, candidate)
+			return
+		}
+		_ = s.publisher.AddICECandidate(candidate)
+	case livekit.SignalTarget_SUBSCRIBER:
+		if s.subscriber.RemoteDescription() == nil {
+			s.pendingSubCandidates = append(s.pendingSubCandidates, candidate)
+			return
+		}
+		_ = s.subscriber.AddICECandidate(candidate)
+	}
+}
+
+func (s *Session) handleAddTrack(req *livekit.AddTrackRequest) {
+	ti := &livekit.TrackInfo{
+		Sid:        utils.NewGuid(utils.TrackPrefix),
+		Type:       req.Type,
+		Name:       req.Name,
+		Muted:      req.Muted,
+		Width:      req.Width,
+		Height:     req.Height,
+		Simulcast:  len(req.Layers) > 1,
+		DisableDtx: req.DisableDtx,
+		Source:     req.Source,
+		Layers:     req.Layers,
+		Stereo:     req.Stereo,
+		Encryption: req.Encryption,
+	}
+	s.lock.Lock()
+	s.publishedTracks[req.Cid] = ti
+	s.info.Tracks = append(s.info.Tracks, ti)
+	s.info.IsPublisher = true
+	s.lock.Unlock()
+
+	res := &livekit.SignalResponse{
+		Message: &livekit.SignalResponse_TrackPublished{TrackPublished: &livekit.TrackPublishedResponse{
+			Cid:   req.Cid,
+			Track: ti,
+		}},
+	}
+	if f := s.server.PublishDelay; f != nil {
+		if delay := f(req); delay > 0 {
+			time.AfterFunc(delay, func() {
+				_ = s.SendResponse(res)
+			})
+			return
+		}
+	}
+	_ = s.SendResponse(res)
+}
+
+func (s *Session) negotiateSubscriber() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed.Load() {
+		return ErrSessionClosed
+	}
+	if s.subscriber.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+		s.subscriberRenegotiate = true
+		return nil
+	}
+
+	offer, err := s.subscriber.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := s.subscriber.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	return s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Offer{Offer: &livekit.SessionDescription{
+			Type: offer.Type.String(),
+			Sdp:  offer.SDP,
+		}},
+	})
+}
+
+func (s *Session) sendCandidate(c *webrtc.ICECandidate, target livekit.SignalTarget) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(c.ToJSON())
+	if err != nil {
+		return
+	}
+	_ = s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Trickle{Trickle: &livekit.TrickleRequest{
+			CandidateInit: string(data),
+			Target:        target,
+		}},
+	})
+}
+
+func (s *Session) websocketConn() *websocket.Conn {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return s.conn
+}
+
+func (s *Session) close() {
+	if s.closed.Swap(true) {
+		return
+	}
+	s.server.removeSession(s)
+
+	s.writeLock.Lock()
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	s.writeLock.Unlock()
+	_ = s.publisher.Close()
+	_ = s.subscriber.Close()
+}
+
+// Identity of the participant
+func (s *Session) Identity() string {
+	return s.identity
+}
+
+// Info returns a copy of the participant info the server holds for the client
+func (s *Session) Info() *livekit.ParticipantInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return proto.Clone(s.info).(*livekit.ParticipantInfo)
+}
+
+// PublishedTracks returns the tracks the client has published
+func (s *Session) PublishedTracks() []*livekit.TrackInfo {
+	return s.Info().Tracks
+}
+
+// Requests returns all signal requests received so far
+func (s *Session) Requests() []*livekit.SignalRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	requests := make([]*livekit.SignalRequest, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// IsClosed returns true once the client has left or the session was closed
+func (s *Session) IsClosed() bool {
+	return s.closed.Load()
+}
+
+// SendResponse sends an arbitrary signal response to the client
+func (s *Session) SendResponse(res *livekit.SignalResponse) error {
+	payload, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if s.conn == nil {
+		return ErrNotConnected
+	}
+	return s.conn.WriteMessage(websocket.BinaryMessage, payload)
+}
+
+func (s *Session) SendParticipantUpdate(participants ...*livekit.ParticipantInfo) error {
+	return s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Update{Update: &livekit.ParticipantUpdate{Participants: participants}},
+	})
+}
+
+func (s *Session) SendSpeakersChanged(speakers ...*livekit.SpeakerInfo) error {
+	return s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_SpeakersChanged{SpeakersChanged: &livekit.SpeakersChanged{Speakers: speakers}},
+	})
+}
+
+func (s *Session) SendRoomUpdate(room *livekit.Room) error {
+	return s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_RoomUpdate{RoomUpdate: &livekit.RoomUpdate{Room: room}},
+	})
+}
+
+func (s *Session) SendLeave(reason livekit.DisconnectReason, canReconnect bool) error {
+	return s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Leave{Leave: &livekit.LeaveRequest{
+			CanReconnect: canReconnect,
+			Reason:       reason,
+		}},
+	})
+}
+
+func (s *Session) SendSubscribedQualityUpdate(trackSid string, qualities ...*livekit.SubscribedQuality) error {
+	return s.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_SubscribedQualityUpdate{SubscribedQualityUpdate: &livekit.SubscribedQualityUpdate{
+			TrackSid:            trackSid,
+			SubscribedQualities: qualities,
+		}},
+	})
+}
+
+// SendData delivers a data packet to the client over the subscriber data channel matching its kind
+func (s *Session) SendData(packet *livekit.DataPacket) error {
+	dc := s.reliableDC
+	if packet.Kind == livekit.DataPacket_LOSSY {
+		dc = s.lossyDC
+	}
+	if dc.ReadyState() != webrtc.DataChannelStateOpen {
+		return ErrDataChannelNotOpen
+	}
+	data, err := proto.Marshal(packet)
+	if err != nil {
+		return err
+	}
+	return dc.Send(data)
+}
+
+// IgnorePings stops answering the client's pings while the connection stays open
+func (s *Session) IgnorePings(ignore bool) {
+	s.ignorePings.Store(ignore)
+}
+
+// CloseSignal drops the websocket without ending the session, the client is expected to resume
+func (s *Session) CloseSignal() {
+	s.writeLock.Lock()
+	conn := s.conn
+	s.conn = nil
+	s.writeLock.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+// Close ends the session, dropping both the signal connection and the peer connections
+func (s *Session) Close() {
+	s.close()
+}
+
+// AddParticipant announces another participant to the client
+func (s *Session) AddParticipant(identity string) (*livekit.ParticipantInfo, error) {
+	pi := &livekit.ParticipantInfo{
+		Sid:      utils.NewGuid(utils.ParticipantPrefix),
+		Identity: identity,
+		State:    livekit.ParticipantInfo_ACTIVE,
+		JoinedAt: time.Now().Unix(),
+	}
+	s.lock.Lock()
+	s.remoteParticipants[pi.Sid] = pi
+	s.lock.Unlock()
+
+	return proto.Clone(pi).(*livekit.ParticipantInfo), s.SendParticipantUpdate(pi)
+}
+
+// RemoveParticipant announces that a participant previously added has left
+func (s *Session) RemoveParticipant(sid string) error {
+	s.lock.Lock()
+	pi := s.remoteParticipants[sid]
+	delete(s.remoteParticipants, sid)
+	s.lock.Unlock()
+	if pi == nil {
+		return ErrParticipantUnknown
+	}
+
+	pi = proto.Clone(pi).(*livekit.ParticipantInfo)
+	pi.State = livekit.ParticipantInfo_DISCONNECTED
+	return s.SendParticipantUpdate(pi)
+}
+
+// PublishTrack publishes a track on behalf of a participant added with AddParticipant, and sends it to the client.
+// Samples written to the returned track are delivered over the subscriber peer connection
+func (s *Session) PublishTrack(participantSid string, codec webrtc.RTPCodecCapability, name string) (*webrtc.TrackLocalStaticSample, *livekit.TrackInfo, error) {
+	ti := newTrackInfo(codec, name)
+	track, err := webrtc.NewTrackLocalStaticSample(codec, ti.Sid, participantSid+"|"+ti.Sid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.publishTrack(participantSid, ti, track); err != nil {
+		return nil, nil, err
+	}
+	return track, ti, nil
+}
+
+// PublishRTPTrack is like PublishTrack, for codecs pion has no payloader for. Packets written to the returned
+// track are delivered as is
+func (s *Session) PublishRTPTrack(participantSid string, codec webrtc.RTPCodecCapability, name string) (*webrtc.TrackLocalStaticRTP, *livekit.TrackInfo, error) {
+	ti := newTrackInfo(codec, name)
+	track, err := webrtc.NewTrackLocalStaticRTP(codec, ti.Sid, participantSid+"|"+ti.Sid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.publishTrack(participantSid, ti, track); err != nil {
+		return nil, nil, err
+	}
+	return track, ti, nil
+}
+
+func newTrackInfo(codec webrtc.RTPCodecCapability, name string) *livekit.TrackInfo {
+	ti := &livekit.TrackInfo{
+		Sid:      utils.NewGuid(utils.TrackPrefix),
+		Name:     name,
+		MimeType: codec.MimeType,
+	}
+	if strings.HasPrefix(codec.MimeType, "audio/") {
+		ti.Type = livekit.TrackType_AUDIO
+		ti.Source = livekit.TrackSource_MICROPHONE
+	} else {
+		ti.Type = livekit.TrackType_VIDEO
+		ti.Source = livekit.TrackSource_CAMERA
+	}
+	return ti
+}
+
+func (s *Session) publishTrack(participantSid string, ti *livekit.TrackInfo, track webrtc.TrackLocal) error {
+	s.lock.Lock()
+	pi := s.remoteParticipants[participantSid]
+	if pi != nil {
+		pi.Tracks = append(pi.Tracks, ti)
+		pi.IsPublisher = true
+		pi = proto.Clone(pi).(*livekit.ParticipantInfo)
+	}
+	s.lock.Unlock()
+	if pi == nil {
+		return ErrParticipantUnknown
+	}
+
+	if err := s.SendParticipantUpdate(pi); err != nil {
+		return err
+	}
+	sender, err := s.subscriber.AddTrack(track)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			if _, _, err := sender.ReadRTCP(); err != nil {
+				return
+			}
+		}
+	}()
+	return s.negotiateSubscriber()
+}
+
+// ForwardTrack relays a track published by the client of another session to this client, like an SFU would.
+// The publisher is announced as a participant if it isn't yet. The track is read until it ends
+func (s *Session) ForwardTrack(publisher *Session, track *webrtc.TrackRemote) error {
+	publisher.lock.Lock()
+	ti := publisher.publishedTracks[track.ID()]
+	if ti != nil {
+		ti = proto.Clone(ti).(*livekit.TrackInfo)
+	}
+	pi := &livekit.ParticipantInfo{
+		Sid:      publisher.info.Sid,
+		Identity: publisher.info.Identity,
+		Name:     publisher.info.Name,
+		State:    livekit.ParticipantInfo_ACTIVE,
+		JoinedAt: publisher.info.JoinedAt,
+	}
+	publisher.lock.Unlock()
+	if ti == nil {
+		return ErrTrackUnknown
+	}
+
+	s.lock.Lock()
+	if s.remoteParticipants[pi.Sid] == nil {
+		s.remoteParticipants[pi.Sid] = pi
+	}
+	s.lock.Unlock()
+
+	local, err := webrtc.NewTrackLocalStaticRTP(track.Codec().RTPCodecCapability, ti.Sid, pi.Sid+"|"+ti.Sid)
+	if err != nil {
+		return err
+	}
+	if err := s.publishTrack(pi.Sid, ti, local); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			pkt, _, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+			_ = local.WriteRTP(pkt)
+		}
+	}()
+	return nil
+}
+
+// UnpublishTrack removes a track published with PublishTrack
+func (s *Session) UnpublishTrack(participantSid, trackSid string) error {
+	s.lock.Lock()
+	pi := s.remoteParticipants[participantSid]
+	if pi != nil {
+		for i, ti := range pi.Tracks {
+			if ti.Sid == trackSid {
+				pi.Tracks = append(pi.Tracks[:i], pi.Tracks[i+1:]...)
+				break
+			}
+		}
+		pi = proto.Clone(pi).(*livekit.ParticipantInfo)
+	}
+	s.lock.Unlock()
+	if pi == nil {
+		return ErrParticipantUnknown
+	}
+
+	for _, sender := range s.subscriber.GetSenders() {
+		if t := sender.Track(); t != nil && t.ID() == trackSid {
+			if err := s.subscriber.RemoveTrack(sender); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.SendParticipantUpdate(pi); err != nil {
+		return err
+	}
+	return s.negotiateSubscriber()
+}
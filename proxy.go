@@ -0,0 +1,209 @@
+package live_sdk_go
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/proxy"
+)
+
+const proxyDialTimeout = 10 * time.Second
+
+// proxyDialer returns the dialer of the configured proxy, or nil when connecting directly
+func (p *ConnectParams) proxyDialer() (proxy.Dialer, error) {
+	if p == nil || p.Proxy == nil {
+		return nil, nil
+	}
+	forward := &net.Dialer{Timeout: proxyDialTimeout}
+	switch p.Proxy.Scheme {
+	case "http", "https":
+		return &httpConnectDialer{proxyURL: p.Proxy, forward: forward, tlsConfig: p.tlsConfig()}, nil
+	case "socks5", "socks5h":
+		return proxy.FromURL(p.Proxy, forward)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedProxy, p.Proxy.Scheme)
+	}
+}
+
+// tlsConfig returns the TLS config of the signal connection, or nil to use the defaults
+func (p *ConnectParams) tlsConfig() *tls.Config {
+	if p == nil || (p.TLSConfig == nil && p.RootCAs == nil) {
+		return nil
+	}
+	config := &tls.Config{}
+	if p.TLSConfig != nil {
+		config = p.TLSConfig.Clone()
+	}
+	if p.RootCAs != nil {
+		config.RootCAs = p.RootCAs
+	}
+	return config
+}
+
+// websocketDialer returns the dialer of the signal connection
+func (p *ConnectParams) websocketDialer() (*websocket.Dialer, error) {
+	dialer, err := p.proxyDialer()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := p.tlsConfig()
+	if dialer == nil && tlsConfig == nil {
+		return websocket.DefaultDialer, nil
+	}
+
+	d := *websocket.DefaultDialer
+	d.TLSClientConfig = tlsConfig
+	if dialer != nil {
+		// the connection is tunneled through the proxy, the proxy settings of the environment don't apply
+		d.Proxy = nil
+		d.NetDialContext = dialContext(dialer)
+	}
+	return &d, nil
+}
+
+// httpClient returns the client of the http requests made next to the signal connection
+func (p *ConnectParams) httpClient() (*http.Client, error) {
+	dialer, err := p.proxyDialer()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := p.tlsConfig()
+	if dialer == nil && tlsConfig == nil {
+		return http.DefaultClient, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if dialer != nil {
+		transport.Proxy = nil
+		transport.DialContext = dialContext(dialer)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func dialContext(dialer proxy.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d, ok := dialer.(proxy.ContextDialer); ok {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.Dial(network, addr)
+	}
+}
+
+// httpConnectDialer tunnels connections through an HTTP proxy with the CONNECT method
+type httpConnectDialer struct {
+	proxyURL  *url.URL
+	forward   *net.Dialer
+	tlsConfig *tls.Config
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("%w: cannot tunnel %s", ErrUnsupportedProxy, network)
+	}
+
+	proxyAddr := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		port := "80"
+		if d.proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(d.proxyURL.Hostname(), port)
+	}
+	conn, err := d.forward.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if d.proxyURL.Scheme == "https" {
+		config := &tls.Config{}
+		if d.tlsConfig != nil {
+			config = d.tlsConfig.Clone()
+		}
+		config.ServerName = d.proxyURL.Hostname()
+		conn = tls.Client(conn, config)
+	}
+
+	// the handshake is bound to ctx, the tunnel itself is not
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+	br, err := d.handshake(conn, addr)
+	close(stop)
+	<-stopped
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: br}, nil
+	}
+	return conn, nil
+}
+
+func (d *httpConnectDialer) handshake(conn net.Conn, addr string) (*bufio.Reader, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("proxy handshake failed: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("proxy handshake failed: %w", err)
+	}
+	// the body of a refused CONNECT runs until the proxy closes the connection, it's dropped with the connection
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy refused connection to %s: %s", addr, res.Status)
+	}
+	return br, nil
+}
+
+// bufferedConn returns the bytes read past the proxy response before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,73 @@
+package live_sdk_go
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+// newConnectProxy starts an HTTP proxy that tunnels CONNECT requests authorized with user:pass
+func newConnectProxy(t *testing.T) (*url.URL, *atomic.Int32) {
+	tunnels := atomic.NewInt32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			_ = target.Close()
+			return
+		}
+		tunnels.Inc()
+		go func() {
+			_, _ = io.Copy(target, conn)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		_ = conn.Close()
+	}))
+	t.Cleanup(server.Close)
+
+	proxyURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword("user", "pass")
+	return proxyURL, tunnels
+}
+
+func TestConnectThroughProxy(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+	proxyURL, tunnels := newConnectProxy(t)
+
+	room, err := ConnectToRoomWithToken(srv.URL(), srv.Token("test-room", "bot"), nil, WithProxy(proxyURL))
+	require.NoError(t, err)
+	defer room.Disconnect()
+	require.EqualValues(t, 1, tunnels.Load())
+
+	proxyURL.User = url.UserPassword("user", "wrong")
+	_, err = ConnectToRoomWithToken(srv.URL(), srv.Token("test-room", "bot2"), nil, WithProxy(proxyURL))
+	require.Error(t, err)
+	require.Nil(t, srv.Session("bot2"))
+
+	_, err = ConnectToRoomWithToken(srv.URL(), srv.Token("test-room", "bot3"), nil, WithProxy(&url.URL{Scheme: "ftp", Host: "localhost:21"}))
+	require.ErrorIs(t, err, ErrUnsupportedProxy)
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
+
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
+	"github.com/liuhailove/live-sdk-go/pkg/metrics"
 )
 
 //TrackPublication 音轨发布接口
@@ -98,8 +101,19 @@ type RemoteTrackPublication struct {
 	trackPublicationBase
 	participantID string
 	receiver      *webrtc.RTPReceiver
+	transport     *PCTransport
+	pliWriter     PLIWriter
+	sampleReader  *RemoteSampleReader
 	onRTCP        func(packet rtcp.Packet)
 
+	// encrypted tracks are decrypted with the keys of the participant
+	participantIdentity string
+	keyProvider         *e2ee.KeyProvider
+
+	// levels of audio tracks, from the audio level extension of the packets read
+	audioLevelMeter *AudioLevelMeter
+	onAudioLevel    func(level float32, isSpeaking bool)
+
 	disabled bool
 
 	// preferred video dimensions to subscribe
@@ -167,6 +181,97 @@ func (p *RemoteTrackPublication) OnRTCP(cb func(packet rtcp.Packet)) {
 	p.lock.Unlock()
 }
 
+// AudioLevel returns the level of the subscribed audio track, between 0 and 1, smoothed.
+// It is read from the audio level extension of the packets, which are only accounted for once they are read from the track
+func (p *RemoteTrackPublication) AudioLevel() float32 {
+	return audioLevelToLinear(p.levelMeter().CurrentAudioLevel())
+}
+
+// IsSpeaking returns true while the audio track is loud enough to be voice or the sender flags it as voice,
+// independently of the speakers the server detects. It stays true for the hangover of the options after the level drops
+func (p *RemoteTrackPublication) IsSpeaking() bool {
+	return p.levelMeter().CurrentVoiceActivity()
+}
+
+// OnAudioLevel sets the callback called with the level of each packet of the audio track, and whether it is speaking.
+// It is called on the goroutine reading the track, as each packet is read, so it must not block
+func (p *RemoteTrackPublication) OnAudioLevel(cb func(level float32, isSpeaking bool)) {
+	p.lock.Lock()
+	p.onAudioLevel = cb
+	p.lock.Unlock()
+}
+
+// SetAudioLevelOptions sets the window levels are smoothed over and the thresholds of IsSpeaking, the levels
+// measured so far are reset
+func (p *RemoteTrackPublication) SetAudioLevelOptions(opts AudioLevelMeterOptions) {
+	p.lock.Lock()
+	p.audioLevelMeter = NewAudioLevelMeter(opts)
+	p.lock.Unlock()
+}
+
+func (p *RemoteTrackPublication) levelMeter() *AudioLevelMeter {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.audioLevelMeter == nil {
+		p.audioLevelMeter = NewAudioLevelMeter(AudioLevelMeterOptions{})
+	}
+	return p.audioLevelMeter
+}
+
+func (p *RemoteTrackPublication) handleAudioLevel(level uint8, voice bool) {
+	meter := p.levelMeter()
+	// packets of audio tracks last 20ms
+	meter.WriteVoiceLevel(level, voice, 0)
+
+	p.lock.RLock()
+	onAudioLevel := p.onAudioLevel
+	p.lock.RUnlock()
+	if onAudioLevel != nil {
+		onAudioLevel(audioLevelToLinear(meter.CurrentAudioLevel()), meter.CurrentVoiceActivity())
+	}
+}
+
+// NewSampleReader starts reading the subscribed track and reassembling it into samples.
+// The reader takes over reading RTP from the track, only one reader can be active at a time
+func (p *RemoteTrackPublication) NewSampleReader(opts ...SampleReaderOption) (*RemoteSampleReader, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	track, _ := p.track.(*webrtc.TrackRemote)
+	if track == nil {
+		return nil, ErrTrackNotSubscribed
+	}
+	if p.sampleReader != nil {
+		return nil, ErrSampleReaderExists
+	}
+
+	r, err := newRemoteSampleReader(p, track, p.pliWriter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.sampleReader = r
+	return r, nil
+}
+
+func (p *RemoteTrackPublication) removeSampleReader(r *RemoteSampleReader) {
+	p.lock.Lock()
+	if p.sampleReader == r {
+		p.sampleReader = nil
+	}
+	p.lock.Unlock()
+}
+
+// closeSampleReader is called when the track is unsubscribed
+func (p *RemoteTrackPublication) closeSampleReader() {
+	p.lock.RLock()
+	r := p.sampleReader
+	p.lock.RUnlock()
+	if r != nil {
+		r.Close()
+	}
+	metrics.RemoveTrack(p.SID())
+}
+
 func (p *RemoteTrackPublication) updateSettings() {
 	p.lock.Lock()
 	settings := &livekit.UpdateTrackSettings{
@@ -183,11 +288,51 @@ func (p *RemoteTrackPublication) updateSettings() {
 	}
 }
 
-func (p *RemoteTrackPublication) setReceiverAndTrack(r *webrtc.RTPReceiver, t *webrtc.TrackRemote) {
+// GetStats returns the receive stats of the track, nil if it isn't subscribed or no media arrived yet.
+// Packets are only accounted for once they are read from the track
+func (p *RemoteTrackPublication) GetStats() *TrackStats {
+	return p.getStats(statsCallerAPI)
+}
+
+func (p *RemoteTrackPublication) getStats(caller statsCaller) *TrackStats {
+	p.lock.RLock()
+	transport := p.transport
+	track, _ := p.track.(*webrtc.TrackRemote)
+	p.lock.RUnlock()
+	if transport == nil || track == nil {
+		return nil
+	}
+
+	ts := transport.streamStats(uint32(track.SSRC()), track.Codec().ClockRate, false, caller)
+	if ts == nil {
+		return nil
+	}
+	ts.TrackSID = p.SID()
+	ts.Kind = p.Kind()
+	ts.RID = track.RID()
+	return ts
+}
+
+// removeStats forgets the stats of the track once it is unsubscribed
+func (p *RemoteTrackPublication) removeStats() {
+	p.lock.RLock()
+	transport := p.transport
+	track, _ := p.track.(*webrtc.TrackRemote)
+	p.lock.RUnlock()
+	if transport != nil && track != nil {
+		transport.removeStreamStats(uint32(track.SSRC()))
+	}
+}
+
+func (p *RemoteTrackPublication) setReceiverAndTrack(r *webrtc.RTPReceiver, t *webrtc.TrackRemote, transport *PCTransport) {
 	p.lock.Lock()
 	p.receiver = r
 	p.track = t
+	p.transport = transport
 	p.lock.Unlock()
+	if t != nil && t.Kind() == webrtc.RTPCodecTypeAudio && transport != nil && transport.audioLevels != nil {
+		transport.audioLevels.OnAudioLevel(uint32(t.SSRC()), p.handleAudioLevel)
+	}
 	if r != nil {
 		go p.rtcpWorker()
 	}
@@ -208,7 +353,13 @@ func (p *RemoteTrackPublication) rtcpWorker() {
 		p.lock.RLock()
 		// rtcpCB could have changed along the way
 		rtcpCB := p.onRTCP
+		sampleReader := p.sampleReader
 		p.lock.RUnlock()
+		if sampleReader != nil {
+			for _, packet := range packets {
+				sampleReader.onRTCP(packet)
+			}
+		}
 		if rtcpCB != nil {
 			for _, packet := range packets {
 				rtcpCB(packet)
@@ -219,7 +370,8 @@ func (p *RemoteTrackPublication) rtcpWorker() {
 
 type LocalTrackPublication struct {
 	trackPublicationBase
-	sender *webrtc.RTPSender
+	sender    *webrtc.RTPSender
+	transport *PCTransport
 	// set for simulcasted tracks (广播)
 	simulcastTracks map[livekit.VideoQuality]*LocalSampleTrack
 	onRttUpdate     func(uint322 uint32)
@@ -279,17 +431,96 @@ func (p *LocalTrackPublication) addSimulcastTrack(st *LocalSampleTrack) {
 	}
 }
 
-func (p *LocalTrackPublication) setSender(sender *webrtc.RTPSender) {
+// setSubscribedQualities pauses the layers no one subscribes to, and resumes the others.
+// A track without simulcast is paused when all qualities are disabled
+func (p *LocalTrackPublication) setSubscribedQualities(qualities []*livekit.SubscribedQuality) {
+	p.lock.RLock()
+	simulcastTracks := p.simulcastTracks
+	track, _ := p.track.(*LocalSampleTrack)
+	p.lock.RUnlock()
+
+	if len(simulcastTracks) > 0 {
+		for _, q := range qualities {
+			if st := simulcastTracks[q.Quality]; st != nil {
+				st.setLayerEnabled(q.Enabled)
+			}
+		}
+		return
+	}
+	if track != nil {
+		enabled := false
+		for _, q := range qualities {
+			enabled = enabled || q.Enabled
+		}
+		track.setLayerEnabled(enabled)
+	}
+}
+
+// GetStats returns the send stats of the track, one entry per simulcast layer
+func (p *LocalTrackPublication) GetStats() []*TrackStats {
+	return p.getStats(statsCallerAPI)
+}
+
+func (p *LocalTrackPublication) getStats(caller statsCaller) []*TrackStats {
+	p.lock.RLock()
+	sender := p.sender
+	transport := p.transport
+	p.lock.RUnlock()
+	if sender == nil || transport == nil {
+		return nil
+	}
+
+	params := sender.GetParameters()
+	var clockRate uint32
+	if len(params.Codecs) > 0 {
+		clockRate = params.Codecs[0].ClockRate
+	}
+	var trackStats []*TrackStats
+	for _, encoding := range params.Encodings {
+		ts := transport.streamStats(uint32(encoding.SSRC), clockRate, true, caller)
+		if ts == nil {
+			continue
+		}
+		ts.TrackSID = p.SID()
+		ts.Kind = p.Kind()
+		ts.RID = encoding.RID
+		trackStats = append(trackStats, ts)
+	}
+	return trackStats
+}
+
+// removeStats forgets the stats of all layers of the track once it is unpublished
+func (p *LocalTrackPublication) removeStats() {
+	p.lock.RLock()
+	sender := p.sender
+	transport := p.transport
+	p.lock.RUnlock()
+	if sender == nil || transport == nil {
+		return
+	}
+	for _, encoding := range sender.GetParameters().Encodings {
+		transport.removeStreamStats(uint32(encoding.SSRC))
+	}
+}
+
+func (p *LocalTrackPublication) setSender(sender *webrtc.RTPSender, transport *PCTransport) {
 	p.lock.Lock()
 	p.sender = sender
+	p.transport = transport
 	p.lock.Unlock()
 
 	go func() {
+		buf := getPacketBuffer()
+		defer putPacketBuffer(buf)
 		for {
-			packets, _, err := sender.ReadRTCP()
+			i, _, err := sender.Read(*buf)
 			if err != nil {
 				return
 			}
+			packets, err := rtcp.Unmarshal((*buf)[:i])
+			if err != nil {
+				continue
+			}
 		rttCaculate:
 			for _, packet := range packets {
 				if rr, ok := packet.(*rtcp.ReceiverReport); ok {
@@ -357,4 +588,7 @@ type TrackPublicationOptions struct {
 	// Opus only (一种有损编码格式)
 	DisableDTX bool //  DTX：Discontinuous Transmission。不同于music场景，在voip场景下，声音不是持续的，会有一段一段的间歇期。这个间歇期若是也正常编码音频数据，对带宽有些浪费。所以opus支持DTX功能，若是检测当前会议没有明显通话声音，仅定期发送（400ms）静音指示报文给对方。对方收到静音指示报文可以补舒适噪音包（opus不支持CNG，不能补舒适噪音包）或者静音包给音频渲染器
 	Stereo     bool // 立体声
+	// DisableRED sends opus without redundancy, by default previous frames are added to packets (RED)
+	// when the server supports it, so lost packets can be recovered
+	DisableRED bool
 }
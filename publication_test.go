@@ -0,0 +1,101 @@
+package live_sdk_go
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestRemoteTrackAudioLevel(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	subscribed := make(chan *RemoteTrackPublication, 1)
+	_, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	})
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	track, _, err := sess.PublishRTPTrack(pi.Sid, webrtc.RTPCodecCapability{
+		MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2,
+	}, "mic")
+	require.NoError(t, err)
+
+	// speaking at -20 dBov for two seconds, then silent
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for i := uint16(0); ; i++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				level := rtp.AudioLevelExtension{Level: MinAudioLevel}
+				if i < 100 {
+					level = rtp.AudioLevelExtension{Level: 20, Voice: true}
+				}
+				ext, _ := level.Marshal()
+				pkt := &rtp.Packet{
+					Header:  rtp.Header{Version: 2, SequenceNumber: i, Timestamp: uint32(i) * 960},
+					Payload: []byte{0xfc, byte(i)},
+				}
+				// the audio level extension is the first the server registers
+				_ = pkt.Header.SetExtension(1, ext)
+				_ = track.WriteRTP(pkt)
+			}
+		}
+	}()
+
+	var pub *RemoteTrackPublication
+	select {
+	case pub = <-subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+	require.Zero(t, pub.AudioLevel())
+	require.False(t, pub.IsSpeaking())
+
+	speaking := make(chan float32, 100)
+	pub.OnAudioLevel(func(level float32, isSpeaking bool) {
+		if isSpeaking {
+			select {
+			case speaking <- level:
+			default:
+			}
+		}
+	})
+
+	// levels are read along with the packets
+	reader, err := pub.NewSampleReader()
+	require.NoError(t, err)
+	defer reader.Close()
+	go func() {
+		for {
+			if _, _, err := reader.ReadSample(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case level := <-speaking:
+		require.InDelta(t, 0.1, level, 0.01)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no audio level")
+	}
+	require.Eventually(t, func() bool {
+		return !pub.IsSpeaking() && pub.AudioLevel() == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -1,6 +1,8 @@
 package live_sdk_go
 
 import (
+	"bufio"
+	"bytes"
 	"io"
 	"os"
 	"path/filepath"
@@ -12,12 +14,18 @@ import (
 	"github.com/pion/webrtc/v3/pkg/media/h264reader"
 	"github.com/pion/webrtc/v3/pkg/media/ivfreader"
 	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
 )
 
 const (
 	// defaults to 30 fps
 	defaultH264FrameDuration = 33 * time.Millisecond
+	defaultH265FrameDuration = 33 * time.Millisecond
+	defaultAV1FrameDuration  = 33 * time.Millisecond
 	defaultOpusFrameDuration = 20 * time.Millisecond
+
+	ivfSignature = "DKIF"
 )
 
 // ReaderSampleProvider provides samples by reading from an io.ReadCloser implementation
@@ -32,7 +40,7 @@ type ReaderSampleProvider struct {
 	// Allow various types of ingress
 	reader io.ReadCloser
 
-	// for vp8
+	// for vp8 and av1 in ivf
 	ivfreader     *ivfreader.IVFReader
 	ivfTimebase   float64
 	lastTimestamp uint64
@@ -40,9 +48,22 @@ type ReaderSampleProvider struct {
 	// for h264
 	h264reader *h264reader.H264Reader
 
+	// for h265
+	h265reader *sdkcodecs.H265Reader
+
+	// for av1 in the low overhead bitstream format
+	obureader *sdkcodecs.OBUReader
+
 	// for ogg
 	oggreader   *oggreader.OggReader
 	lastGranule uint64
+
+	// for PCMU and PCMA, encoded from WAV or raw PCM
+	pcmFormat *sdkcodecs.PCMFormat
+	g711      *G711SampleProvider
+
+	// measures PCM audio, or the levels supplied by the caller for encoded audio
+	levelMeter *AudioLevelMeter
 }
 
 type ReaderSampleProviderOption func(*ReaderSampleProvider)
@@ -71,6 +92,21 @@ func ReaderTrackWithRTCPHandler(f func(rtcp.Packet)) func(provider *ReaderSample
 	}
 }
 
+// ReaderTrackWithPCMFormat reads raw PCM audio of the given format instead of a WAV file, for PCMU and PCMA
+func ReaderTrackWithPCMFormat(format sdkcodecs.PCMFormat) func(provider *ReaderSampleProvider) {
+	return func(provider *ReaderSampleProvider) {
+		provider.pcmFormat = &format
+	}
+}
+
+// ReaderTrackWithAudioLevelMeter reports the levels of meter instead of AudioLevel. For encoded audio, the caller
+// writes the levels of the samples to it, PCM audio is measured with it
+func ReaderTrackWithAudioLevelMeter(meter *AudioLevelMeter) func(provider *ReaderSampleProvider) {
+	return func(provider *ReaderSampleProvider) {
+		provider.levelMeter = meter
+	}
+}
+
 // NewLocalFileTrack creates an *os.File reader for NewLocalReaderTrack
 func NewLocalFileTrack(file string, options ...ReaderSampleProviderOption) (*LocalSampleTrack, error) {
 	// File health check
@@ -90,19 +126,51 @@ func NewLocalFileTrack(file string, options ...ReaderSampleProviderOption) (*Loc
 	switch filepath.Ext(file) {
 	case ".h264":
 		mime = webrtc.MimeTypeH264
+	case ".h265", ".hevc":
+		mime = webrtc.MimeTypeH265
 	case ".ivf":
-		mime = webrtc.MimeTypeVP8
+		if mime, err = ivfMime(fp); err != nil {
+			_ = fp.Close()
+			return nil, err
+		}
+	case ".obu":
+		mime = webrtc.MimeTypeAV1
 	case ".ogg":
 		mime = webrtc.MimeTypeOpus
+	case ".wav":
+		// encoded with G.711 µ-law, ReaderTrackWithMime(webrtc.MimeTypePCMA) selects A-law
+		mime = webrtc.MimeTypePCMU
 	default:
+		_ = fp.Close()
 		return nil, ErrCannotDetermineMime
 	}
 
 	return NewLocalReaderTrack(fp, mime, options...)
 }
 
+// ivfMime returns the codec of an IVF file from its FourCC, the file is rewound
+func ivfMime(fp *os.File) (string, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(fp, header); err != nil {
+		return "", err
+	}
+	if _, err := fp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	switch string(header[8:12]) {
+	case "VP80":
+		return webrtc.MimeTypeVP8, nil
+	case "AV01":
+		return webrtc.MimeTypeAV1, nil
+	default:
+		return "", ErrUnsupportedFileType
+	}
+}
+
 // NewLocalReaderTrack uses io.ReadCloser interface to adapt to various ingress types
 // - mime: has to be one of webrtc.MimeType... (e.g. webrtc.MimeTypeOpus)
+// H.264 and H.265 are read as Annex-B byte streams, AV1 either from IVF or as a low overhead bitstream (.obu)
+// PCMU and PCMA are encoded from a WAV file, or raw PCM audio with ReaderTrackWithPCMFormat
 func NewLocalReaderTrack(in io.ReadCloser, mime string, options ...ReaderSampleProviderOption) (*LocalSampleTrack, error) {
 	provider := &ReaderSampleProvider{
 		Mime:   mime,
@@ -116,7 +184,8 @@ func NewLocalReaderTrack(in io.ReadCloser, mime string, options ...ReaderSampleP
 
 	// check if mime type is supported
 	switch provider.Mime {
-	case webrtc.MimeTypeH264, webrtc.MimeTypeOpus, webrtc.MimeTypeVP8:
+	case webrtc.MimeTypeH264, webrtc.MimeTypeH265, webrtc.MimeTypeOpus, webrtc.MimeTypeVP8, webrtc.MimeTypeAV1,
+		webrtc.MimeTypePCMU, webrtc.MimeTypePCMA:
 	// allow
 	default:
 		return nil, ErrUnsupportedFileType
@@ -138,7 +207,8 @@ func NewLocalReaderTrack(in io.ReadCloser, mime string, options ...ReaderSampleP
 
 func (p *ReaderSampleProvider) OnBind() error {
 	// If we are not closing on unbind, don't do anything on rebind
-	if p.ivfreader != nil || p.h264reader != nil || p.oggreader != nil {
+	if p.ivfreader != nil || p.h264reader != nil || p.h265reader != nil || p.obureader != nil || p.oggreader != nil ||
+		p.g711 != nil {
 		return nil
 	}
 
@@ -146,14 +216,23 @@ func (p *ReaderSampleProvider) OnBind() error {
 	switch p.Mime {
 	case webrtc.MimeTypeH264:
 		p.h264reader, err = h264reader.NewReader(p.reader)
+	case webrtc.MimeTypeH265:
+		p.h265reader = sdkcodecs.NewH265Reader(p.reader)
 	case webrtc.MimeTypeVP8:
-		var ivfheader *ivfreader.IVFFileHeader
-		p.ivfreader, ivfheader, err = ivfreader.NewWith(p.reader)
-		if err == nil {
-			p.ivfTimebase = float64(ivfheader.TimebaseNumerator) / float64(ivfheader.TimebaseDenominator)
+		err = p.openIVF(p.reader)
+	case webrtc.MimeTypeAV1:
+		// IVF files start with their signature, OBU streams with a temporal delimiter
+		r := bufio.NewReader(p.reader)
+		signature, _ := r.Peek(len(ivfSignature))
+		if bytes.Equal(signature, []byte(ivfSignature)) {
+			err = p.openIVF(r)
+		} else {
+			p.obureader = sdkcodecs.NewOBUReader(r)
 		}
 	case webrtc.MimeTypeOpus:
 		p.oggreader, _, err = oggreader.NewWith(p.reader)
+	case webrtc.MimeTypePCMU, webrtc.MimeTypePCMA:
+		err = p.openPCM()
 	default:
 		err = ErrUnsupportedFileType
 	}
@@ -164,6 +243,33 @@ func (p *ReaderSampleProvider) OnBind() error {
 	return nil
 }
 
+func (p *ReaderSampleProvider) openIVF(r io.Reader) error {
+	var ivfheader *ivfreader.IVFFileHeader
+	var err error
+	p.ivfreader, ivfheader, err = ivfreader.NewWith(r)
+	if err == nil {
+		p.ivfTimebase = float64(ivfheader.TimebaseNumerator) / float64(ivfheader.TimebaseDenominator)
+	}
+	return err
+}
+
+func (p *ReaderSampleProvider) openPCM() error {
+	reader, err := newPCMReader(p.reader, p.pcmFormat)
+	if err != nil {
+		return err
+	}
+	if p.g711, err = NewG711SampleProvider(reader, p.Mime); err != nil {
+		return err
+	}
+	if p.levelMeter != nil {
+		p.g711.LevelMeter = p.levelMeter
+	}
+	if p.FrameDuration > 0 {
+		p.g711.FrameDuration = p.FrameDuration
+	}
+	return nil
+}
+
 func (p *ReaderSampleProvider) OnUnbind() error {
 	return nil
 }
@@ -175,10 +281,27 @@ func (p *ReaderSampleProvider) Close() error {
 	return nil
 }
 
+// CurrentAudioLevel returns the level of PCM audio or of the level meter, AudioLevel otherwise
 func (p *ReaderSampleProvider) CurrentAudioLevel() uint8 {
+	switch {
+	case p.g711 != nil:
+		return p.g711.CurrentAudioLevel()
+	case p.levelMeter != nil:
+		return p.levelMeter.CurrentAudioLevel()
+	}
 	return p.AudioLevel
 }
 
+func (p *ReaderSampleProvider) CurrentVoiceActivity() bool {
+	switch {
+	case p.g711 != nil:
+		return p.g711.CurrentVoiceActivity()
+	case p.levelMeter != nil:
+		return p.levelMeter.CurrentVoiceActivity()
+	}
+	return false
+}
+
 func (p *ReaderSampleProvider) NextSample() (media.Sample, error) {
 	sample := media.Sample{}
 	switch p.Mime {
@@ -204,7 +327,29 @@ func (p *ReaderSampleProvider) NextSample() (media.Sample, error) {
 			return sample, nil
 		}
 		sample.Duration = defaultH264FrameDuration
-	case webrtc.MimeTypeVP8:
+	case webrtc.MimeTypeH265:
+		nal, err := p.h265reader.NextNAL()
+		if err != nil {
+			return sample, err
+		}
+
+		sample.Data = nal.Data
+		if !nal.IsVCL() {
+			// return it without duration
+			return sample, nil
+		}
+		sample.Duration = defaultH265FrameDuration
+	case webrtc.MimeTypeVP8, webrtc.MimeTypeAV1:
+		if p.obureader != nil {
+			tu, err := p.obureader.NextTemporalUnit()
+			if err != nil {
+				return sample, err
+			}
+			sample.Data = tu
+			sample.Duration = defaultAV1FrameDuration
+			break
+		}
+
 		frame, header, err := p.ivfreader.ParseNextFrame()
 		if err != nil {
 			return sample, err
@@ -226,6 +371,9 @@ func (p *ReaderSampleProvider) NextSample() (media.Sample, error) {
 		if sample.Duration == 0 {
 			sample.Duration = defaultOpusFrameDuration
 		}
+	case webrtc.MimeTypePCMU, webrtc.MimeTypePCMA:
+		// the duration of samples is the frame duration already
+		return p.g711.NextSample()
 	}
 
 	if p.FrameDuration > 0 {
@@ -0,0 +1,177 @@
+package live_sdk_go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestPublishReaderTrack(t *testing.T) {
+	startCode := []byte{0x00, 0x00, 0x00, 0x01}
+	nalu := func(naluType byte, size int) []byte {
+		data := bytes.Repeat([]byte{0xcd}, size)
+		data[0], data[1] = naluType<<1, 0x01
+		return data
+	}
+	// VPS, SPS, PPS and an IDR slice
+	var h265Frame []byte
+	for _, n := range [][]byte{nalu(32, 20), nalu(33, 40), nalu(34, 10), nalu(19, 3000)} {
+		h265Frame = append(h265Frame, startCode...)
+		h265Frame = append(h265Frame, n...)
+	}
+
+	obu := func(obuType byte, data []byte) []byte {
+		if len(data) < 0x80 {
+			return append([]byte{obuType<<3 | 0x02, byte(len(data))}, data...)
+		}
+		return append([]byte{obuType<<3 | 0x02, byte(len(data)&0x7f) | 0x80, byte(len(data) >> 7)}, data...)
+	}
+	keyFrame := bytes.Repeat([]byte{0xab}, 3000)
+	keyFrame[0] = 0x10
+	// sequence header and key frame, the temporal delimiter isn't sent
+	av1Frame := append(obu(1, []byte{0x00, 0x00, 0x00, 0x0a}), obu(6, keyFrame)...)
+	av1TU := append([]byte{0x12, 0x00}, av1Frame...)
+
+	for _, tc := range []struct {
+		mime         string
+		stream       []byte
+		frame        []byte
+		depacketizer rtp.Depacketizer
+	}{
+		{webrtc.MimeTypeH265, h265Frame, h265Frame, &sdkcodecs.H265Depacketizer{}},
+		{webrtc.MimeTypeAV1, av1TU, av1Frame, &sdkcodecs.AV1Depacketizer{}},
+	} {
+		t.Run(tc.mime, func(t *testing.T) {
+			srv := testserver.New()
+			defer srv.Close()
+
+			frames := make(chan []byte, 100)
+			srv.OnTrack = func(s *testserver.Session, track *webrtc.TrackRemote) {
+				require.Equal(t, tc.mime, track.Codec().MimeType)
+				var frame []byte
+				for {
+					pkt, _, err := track.ReadRTP()
+					if err != nil {
+						return
+					}
+					data, err := tc.depacketizer.Unmarshal(pkt.Payload)
+					if err != nil {
+						continue
+					}
+					frame = append(frame, data...)
+					if pkt.Marker {
+						select {
+						case frames <- frame:
+						default:
+						}
+						frame = nil
+					}
+				}
+			}
+			room, _ := connectTestRoom(t, srv, "bot", nil)
+			defer room.Disconnect()
+
+			track, err := NewLocalReaderTrack(io.NopCloser(bytes.NewReader(bytes.Repeat(tc.stream, 100))), tc.mime)
+			require.NoError(t, err)
+			_, err = room.LocalParticipant.PublishTrack(track, &TrackPublicationOptions{Name: "camera"})
+			require.NoError(t, err)
+
+			// frames sent before the connection is established are lost
+			timeout := time.After(10 * time.Second)
+			for {
+				select {
+				case frame := <-frames:
+					if bytes.Equal(tc.frame, frame) {
+						return
+					}
+				case <-timeout:
+					t.Fatal("server did not receive a frame")
+				}
+			}
+		})
+	}
+}
+
+func TestPublishWAVTrack(t *testing.T) {
+	// a second of a 1 kHz tone in 16 kHz stereo
+	pcm := make([]int16, 0, 2*16000)
+	for i := 0; i < 16000; i++ {
+		s := int16(8000 * math.Sin(2*math.Pi*1000*float64(i)/16000))
+		pcm = append(pcm, s, s)
+	}
+	wav := &bytes.Buffer{}
+	wav.WriteString("RIFF\x00\x00\x00\x00WAVEfmt ")
+	for _, v := range []interface{}{uint32(16), uint16(1), uint16(2), uint32(16000), uint32(16000 * 4), uint16(4), uint16(16)} {
+		require.NoError(t, binary.Write(wav, binary.LittleEndian, v))
+	}
+	wav.WriteString("data")
+	require.NoError(t, binary.Write(wav, binary.LittleEndian, uint32(len(pcm)*2)))
+	require.NoError(t, binary.Write(wav, binary.LittleEndian, pcm))
+
+	for _, tc := range []struct {
+		mime        string
+		payloadType webrtc.PayloadType
+	}{
+		{webrtc.MimeTypePCMU, 0},
+		{webrtc.MimeTypePCMA, 8},
+	} {
+		mime := tc.mime
+		t.Run(mime, func(t *testing.T) {
+			srv := testserver.New()
+			defer srv.Close()
+
+			payloads := make(chan []byte, 100)
+			srv.OnTrack = func(s *testserver.Session, track *webrtc.TrackRemote) {
+				// pion doesn't resolve the codec of payload type 0
+				require.Equal(t, tc.payloadType, track.PayloadType())
+				for {
+					pkt, _, err := track.ReadRTP()
+					if err != nil {
+						return
+					}
+					select {
+					case payloads <- pkt.Payload:
+					default:
+					}
+				}
+			}
+			room, _ := connectTestRoom(t, srv, "bot", nil)
+			defer room.Disconnect()
+
+			track, err := NewLocalReaderTrack(io.NopCloser(bytes.NewReader(wav.Bytes())), mime)
+			require.NoError(t, err)
+			_, err = room.LocalParticipant.PublishTrack(track, &TrackPublicationOptions{Name: "phone"})
+			require.NoError(t, err)
+
+			select {
+			case payload := <-payloads:
+				// 20 ms at 8 kHz
+				require.Len(t, payload, 160)
+				decoded := sdkcodecs.DecodePCMU(payload)
+				if mime == webrtc.MimeTypePCMA {
+					decoded = sdkcodecs.DecodePCMA(payload)
+				}
+				var peak int16
+				for _, s := range decoded {
+					if s > peak {
+						peak = s
+					}
+				}
+				// the tone survives downmixing and resampling
+				require.InDelta(t, 8000, peak, 1000)
+			case <-time.After(10 * time.Second):
+				t.Fatal("server did not receive audio")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package live_sdk_go
+
+import "time"
+
+const (
+	maxReconnectCount        = 10
+	initialReconnectInterval = 300 * time.Millisecond
+	maxReconnectInterval     = 60 * time.Second
+)
+
+// ReconnectAttempt describes the state of an ongoing reconnection, passed to ReconnectPolicy
+type ReconnectAttempt struct {
+	// Attempt is the zero based number of the attempt about to be made, or that just failed
+	Attempt int
+	// FullReconnect is set when the disconnect requires a full restart, e.g. server sent a leave with can_reconnect
+	FullReconnect bool
+	// Elapsed is the time since the connection was lost
+	Elapsed time.Duration
+	// LastError is the error of the last failed attempt, nil before the first attempt
+	LastError error
+}
+
+// ReconnectPolicy decides how RTCEngine recovers from a lost connection
+type ReconnectPolicy interface {
+	// ShouldResume returns true to resume the existing session (ICE restart over a new signal connection),
+	// false to leave and join again from scratch
+	ShouldResume(attempt ReconnectAttempt) bool
+	// NextRetryDelay is called after a failed attempt, returns the delay before the next one,
+	// or false to give up and disconnect
+	NextRetryDelay(attempt ReconnectAttempt) (time.Duration, bool)
+}
+
+// DefaultReconnectPolicy resumes unless a full reconnect is requested, and retries with a quadratic backoff
+type DefaultReconnectPolicy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+func NewDefaultReconnectPolicy() *DefaultReconnectPolicy {
+	return &DefaultReconnectPolicy{
+		MaxAttempts:     maxReconnectCount,
+		InitialInterval: initialReconnectInterval,
+		MaxInterval:     maxReconnectInterval,
+	}
+}
+
+func (p *DefaultReconnectPolicy) ShouldResume(attempt ReconnectAttempt) bool {
+	return !attempt.FullReconnect
+}
+
+func (p *DefaultReconnectPolicy) NextRetryDelay(attempt ReconnectAttempt) (time.Duration, bool) {
+	if attempt.Attempt >= p.MaxAttempts-1 {
+		return 0, false
+	}
+	delay := time.Duration(attempt.Attempt*attempt.Attempt) * p.InitialInterval
+	if delay > p.MaxInterval {
+		return 0, false
+	}
+	return delay, true
+}
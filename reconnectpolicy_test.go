@@ -0,0 +1,35 @@
+package live_sdk_go
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultReconnectPolicy(t *testing.T) {
+	p := NewDefaultReconnectPolicy()
+
+	require.True(t, p.ShouldResume(ReconnectAttempt{}))
+	require.False(t, p.ShouldResume(ReconnectAttempt{FullReconnect: true}))
+
+	var attempts int
+	var total time.Duration
+	for attempt := 0; ; attempt++ {
+		attempts++
+		delay, retry := p.NextRetryDelay(ReconnectAttempt{Attempt: attempt})
+		if !retry {
+			break
+		}
+		require.Equal(t, time.Duration(attempt*attempt)*initialReconnectInterval, delay)
+		total += delay
+	}
+	require.Equal(t, maxReconnectCount, attempts)
+	require.Less(t, total, 2*time.Minute)
+
+	p.MaxInterval = time.Second
+	_, retry := p.NextRetryDelay(ReconnectAttempt{Attempt: 1})
+	require.True(t, retry)
+	_, retry = p.NextRetryDelay(ReconnectAttempt{Attempt: 2})
+	require.False(t, retry)
+}
@@ -0,0 +1,134 @@
+package live_sdk_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	regionSettingsPath = "/settings/regions"
+	// each region gets this long to accept the signal connection before the next one is tried
+	regionJoinTimeout = 5 * time.Second
+)
+
+// joinSignal connects the signal client to url, falling through to the failover urls and,
+// when region discovery is enabled, the regions of the deployment ranked by distance, which are tried last.
+// It returns the url that accepted the connection
+func (e *RTCEngine) joinSignal(ctx context.Context, url string, token string, params *ConnectParams) (*livekit.JoinResponse, string, error) {
+	candidates := append([]string{url}, params.FailoverURLs...)
+	regionNames := make(map[string]string)
+	discover := params.RegionDiscovery
+	tried := make(map[string]bool)
+
+	var lastErr error
+	for i := 0; ; i++ {
+		if i == 1 && discover {
+			// only asked for after the first failure, the given url is usually the closest
+			discover = false
+			regions, _ := fetchAnyRegionSettings(ctx, params, candidates, token)
+			for _, region := range regions {
+				candidates = append(candidates, region.Url)
+				regionNames[region.Url] = region.Region
+			}
+		}
+		if i >= len(candidates) {
+			break
+		}
+		candidate := candidates[i]
+		if candidate == "" || tried[candidate] {
+			continue
+		}
+		tried[candidate] = true
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if i < len(candidates)-1 || discover {
+			attemptCtx, cancel = context.WithTimeout(ctx, regionJoinTimeout)
+		}
+		res, err := e.client.JoinContext(attemptCtx, candidate, token, params)
+		cancel()
+		if err == nil {
+			region := regionNames[candidate]
+			if region == "" {
+				region = res.GetServerInfo().GetRegion()
+			}
+			e.region.Store(region)
+			if i > 0 {
+				logger.Infow("joined failover region", "url", candidate, "region", region)
+			}
+			return res, candidate, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, "", ctxErr
+		}
+		// a rejected token is rejected by every region
+		if errors.Is(err, ErrUnauthorized) {
+			return nil, "", err
+		}
+		logger.Warnw("could not join region", err, "url", candidate)
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+// fetchAnyRegionSettings asks urls in turn for the regions of the deployment, until one of them answers
+func fetchAnyRegionSettings(ctx context.Context, params *ConnectParams, urls []string, token string) ([]*livekit.RegionInfo, error) {
+	var lastErr error
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		fetchCtx, cancel := context.WithTimeout(ctx, regionJoinTimeout)
+		regions, err := fetchRegionSettings(fetchCtx, params, url, token)
+		cancel()
+		if err == nil {
+			return regions, nil
+		}
+		logger.Warnw("could not fetch region settings", err, "url", url)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// fetchRegionSettings queries the regions of a deployment, closest first
+func fetchRegionSettings(ctx context.Context, params *ConnectParams, url string, token string) ([]*livekit.RegionInfo, error) {
+	client, err := params.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(ToHttpURL(url), "/")+regionSettingsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = newHeaderWithToken(token)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	settings := &livekit.RegionSettings{}
+	if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, settings); err != nil {
+		return nil, err
+	}
+	regions := settings.Regions
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].Distance < regions[j].Distance
+	})
+	return regions, nil
+}
@@ -0,0 +1,105 @@
+package live_sdk_go
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+// unreachableURL returns the url of a server that's no longer listening
+func unreachableURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestJoinFailoverURLs(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+	srv.OnJoin = func(req *testserver.JoinRequest, res *livekit.JoinResponse) {
+		res.ServerInfo.Region = "eu"
+	}
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	room, err := ConnectToRoomWithToken(unreachableURL(), srv.Token("test-room", "bot"), nil,
+		WithFailoverURLs(unavailable.URL, srv.URL()))
+	require.NoError(t, err)
+	defer room.Disconnect()
+
+	require.Equal(t, srv.URL(), room.ServerURL())
+	require.Equal(t, "eu", room.Region())
+}
+
+func TestJoinRegionDiscovery(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+	token := srv.Token("test-room", "bot")
+
+	near := unreachableURL()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != regionSettingsPath {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"regions":[{"region":"far","url":%q,"distance":"1000"},{"region":"near","url":%q,"distance":"10"}]}`, srv.URL(), near)
+	}))
+	defer primary.Close()
+
+	room, err := ConnectToRoomWithToken(primary.URL, token, nil, WithRegionDiscovery(true))
+	require.NoError(t, err)
+	defer room.Disconnect()
+
+	require.Equal(t, srv.URL(), room.ServerURL())
+	require.Equal(t, "far", room.Region())
+}
+
+func TestJoinRegionDiscoveryFromFailover(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	// the primary is down, the regions are fetched from the failover that answers
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != regionSettingsPath {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"regions":[{"region":"eu","url":%q,"distance":"10"}]}`, srv.URL())
+	}))
+	defer failover.Close()
+
+	room, err := ConnectToRoomWithToken(unreachableURL(), srv.Token("test-room", "bot"), nil,
+		WithFailoverURLs(failover.URL), WithRegionDiscovery(true))
+	require.NoError(t, err)
+	defer room.Disconnect()
+
+	require.Equal(t, srv.URL(), room.ServerURL())
+	require.Equal(t, "eu", room.Region())
+}
+
+func TestJoinUnauthorizedNoFailover(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+
+	_, err := ConnectToRoomWithToken(unauthorized.URL, srv.Token("test-room", "bot"), nil, WithFailoverURLs(srv.URL()))
+	require.ErrorIs(t, err, ErrUnauthorized)
+	require.Nil(t, srv.Session("bot"))
+}
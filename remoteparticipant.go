@@ -5,18 +5,23 @@ import (
 	"github.com/pion/webrtc/v3"
 	"sync"
 	"time"
+
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
 )
 
 type RemoteParticipant struct {
 	baseParticipant
-	pliWriter PLIWriter
-	client    *SignalClient
+	pliWriter   PLIWriter
+	client      *SignalClient
+	keyProvider *e2ee.KeyProvider
+	dataChunks  dataReassembler
 }
 
-func newRemoteParticipant(pi *livekit.ParticipantInfo, roomCallback *RoomCallback, client *SignalClient, pliWriter PLIWriter) *RemoteParticipant {
+func newRemoteParticipant(pi *livekit.ParticipantInfo, roomCallback *RoomCallback, client *SignalClient, keyProvider *e2ee.KeyProvider, pliWriter PLIWriter) *RemoteParticipant {
 	p := &RemoteParticipant{
 		baseParticipant: *newBaseParticipant(roomCallback),
 		client:          client,
+		keyProvider:     keyProvider,
 		pliWriter:       pliWriter,
 	}
 	p.updateInfo(pi)
@@ -41,6 +46,9 @@ func (p *RemoteParticipant) updateInfo(pi *livekit.ParticipantInfo) {
 			remotePub.updateInfo(ti)
 			remotePub.client = p.client
 			remotePub.participantID = p.sid
+			remotePub.participantIdentity = p.Identity()
+			remotePub.keyProvider = p.keyProvider
+			remotePub.pliWriter = p.WritePLI
 			p.addPublication(remotePub)
 			newPubs[ti.Sid] = remotePub
 			pub = remotePub
@@ -79,7 +87,7 @@ func (p *RemoteParticipant) updateInfo(pi *livekit.ParticipantInfo) {
 	}
 }
 
-func (p *RemoteParticipant) addSubscribedMediaTrack(track *webrtc.TrackRemote, trackSID string, receiver *webrtc.RTPReceiver) {
+func (p *RemoteParticipant) addSubscribedMediaTrack(track *webrtc.TrackRemote, trackSID string, receiver *webrtc.RTPReceiver, transport *PCTransport) {
 	pub := p.getPublication(trackSID)
 	if pub == nil {
 		// wait for metadata to arrive
@@ -88,7 +96,7 @@ func (p *RemoteParticipant) addSubscribedMediaTrack(track *webrtc.TrackRemote, t
 			for time.Since(start) < 5*time.Second {
 				pub := p.getPublication(trackSID)
 				if pub != nil {
-					p.addSubscribedMediaTrack(track, trackSID, receiver)
+					p.addSubscribedMediaTrack(track, trackSID, receiver, transport)
 					return
 				}
 				time.Sleep(50 * time.Millisecond)
@@ -98,7 +106,7 @@ func (p *RemoteParticipant) addSubscribedMediaTrack(track *webrtc.TrackRemote, t
 		}()
 		return
 	}
-	pub.setReceiverAndTrack(receiver, track)
+	pub.setReceiverAndTrack(receiver, track, transport)
 
 	logger.Infow("track subscribed", "participant", p.Identity(), "track", pub.sid.Load(), "kind", pub.kind.Load())
 	p.Callback.OnTrackSubscribed(track, pub, p)
@@ -127,6 +135,8 @@ func (p *RemoteParticipant) unpublishTrack(sid string, sendUnpublish bool) {
 		p.videoTracks.Delete(sid)
 	}
 
+	pub.closeSampleReader()
+	pub.removeStats()
 	track := pub.TrackRemote()
 	if track != nil {
 		p.Callback.OnTrackUnsubscribed(track, pub, p)
@@ -145,6 +155,10 @@ func (p *RemoteParticipant) WritePLI(ssrc webrtc.SSRC) {
 func (p *RemoteParticipant) unpublishAllTracks() {
 	p.tracks.Range(func(key, value any) bool {
 		pub := value.(TrackPublication)
+		if remotePub, ok := pub.(*RemoteTrackPublication); ok {
+			remotePub.closeSampleReader()
+			remotePub.removeStats()
+		}
 		if remoteTrack, ok := pub.Track().(*webrtc.TrackRemote); ok {
 			if pub.Track() != nil {
 				p.Callback.OnTrackUnsubscribed(remoteTrack, pub.(*RemoteTrackPublication), p)
@@ -0,0 +1,332 @@
+package live_sdk_go
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"go.uber.org/atomic"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
+	"github.com/liuhailove/live-sdk-go/pkg/jitter"
+	"github.com/liuhailove/live-sdk-go/pkg/metrics"
+	"github.com/liuhailove/live-sdk-go/pkg/synchronizer"
+)
+
+const (
+	defaultVideoMaxLatency = 2 * time.Second
+	defaultAudioMaxLatency = time.Second
+	minPLIInterval         = 500 * time.Millisecond
+	sampleQueueSize        = 100
+)
+
+type SampleReaderOption func(r *RemoteSampleReader)
+
+// WithSynchronizer shares a synchronizer between readers, so the PTS of their samples line up,
+// e.g. the audio and video tracks of a participant. Each reader uses its own by default
+func WithSynchronizer(sync *synchronizer.Synchronizer) SampleReaderOption {
+	return func(r *RemoteSampleReader) {
+		r.sync = sync
+	}
+}
+
+// WithMaxLatency sets how long the jitter buffer waits for missing packets before dropping a sample
+func WithMaxLatency(maxLatency time.Duration) SampleReaderOption {
+	return func(r *RemoteSampleReader) {
+		r.maxLatency = maxLatency
+	}
+}
+
+type remoteSample struct {
+	sample media.Sample
+	pts    time.Duration
+}
+
+// RemoteSampleReader reads a subscribed track and reassembles its RTP packets into media samples,
+// requesting a keyframe whenever video packets are lost
+type RemoteSampleReader struct {
+	pub          *RemoteTrackPublication
+	track        *webrtc.TrackRemote
+	pliWriter    PLIWriter
+	depacketizer rtp.Depacketizer
+	// set when the track is RED, primary packets are extracted and lost ones recovered before the jitter buffer
+	red *sdkcodecs.REDDecoder
+	// set when the track is encrypted end-to-end, samples are decrypted once reassembled
+	cryptor    *e2ee.FrameCryptor
+	buffer     *jitter.Buffer
+	sync       *synchronizer.Synchronizer
+	trackSync  *synchronizer.TrackSynchronizer
+	maxLatency time.Duration
+
+	samples   chan remoteSample
+	done      chan struct{}
+	closeOnce sync.Once
+	err       atomic.Error
+	dropped   atomic.Uint32
+	lastPLI   atomic.Time
+}
+
+func newRemoteSampleReader(pub *RemoteTrackPublication, track *webrtc.TrackRemote, pliWriter PLIWriter, opts ...SampleReaderOption) (*RemoteSampleReader, error) {
+	depacketizer, err := depacketizerForCodec(track.Codec().RTPCodecCapability)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RemoteSampleReader{
+		pub:          pub,
+		track:        track,
+		pliWriter:    pliWriter,
+		depacketizer: depacketizer,
+		samples:      make(chan remoteSample, sampleQueueSize),
+		done:         make(chan struct{}),
+	}
+	if strings.EqualFold(track.Codec().MimeType, mimeTypeRED) {
+		r.red = sdkcodecs.NewREDDecoder()
+	}
+	if pub.keyProvider != nil && pub.TrackInfo().GetEncryption() == livekit.Encryption_GCM {
+		// RED is decoded into the primary codec first
+		mimeType := track.Codec().MimeType
+		if r.red != nil {
+			mimeType = webrtc.MimeTypeOpus
+		}
+		if r.cryptor, err = e2ee.NewFrameCryptor(pub.keyProvider, pub.participantIdentity, mimeType); err != nil {
+			return nil, err
+		}
+	}
+	if track.Kind() == webrtc.RTPCodecTypeVideo {
+		r.maxLatency = defaultVideoMaxLatency
+	} else {
+		r.maxLatency = defaultAudioMaxLatency
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.sync == nil {
+		r.sync = synchronizer.NewSynchronizer(nil)
+	}
+
+	r.trackSync = r.sync.AddTrack(track, pub.participantID)
+	r.buffer = jitter.NewBuffer(depacketizer, track.Codec().ClockRate, r.maxLatency,
+		jitter.WithPacketDroppedHandler(r.onPacketDropped))
+
+	go r.readWorker()
+	return r, nil
+}
+
+// ReadSample blocks until the next sample is complete, and returns it along with its presentation timestamp
+// relative to the start of the synchronizer. io.EOF is returned once the track is unsubscribed or the reader closed
+func (r *RemoteSampleReader) ReadSample() (media.Sample, time.Duration, error) {
+	select {
+	case s, ok := <-r.samples:
+		if ok {
+			return s.sample, s.pts, nil
+		}
+	case <-r.done:
+		return media.Sample{}, 0, io.EOF
+	}
+
+	if err := r.err.Load(); err != nil {
+		return media.Sample{}, 0, err
+	}
+	return media.Sample{}, 0, io.EOF
+}
+
+func (r *RemoteSampleReader) Track() *webrtc.TrackRemote {
+	return r.track
+}
+
+func (r *RemoteSampleReader) Synchronizer() *synchronizer.Synchronizer {
+	return r.sync
+}
+
+// Close stops delivering samples, and reading the track once its next packet arrives. The track is then read by
+// no one, so the stats and audio levels of the publication stop updating until it's read again, e.g. by a new reader
+func (r *RemoteSampleReader) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+	r.pub.removeSampleReader(r)
+}
+
+func (r *RemoteSampleReader) isClosed() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *RemoteSampleReader) onRTCP(packet rtcp.Packet) {
+	// the synchronizer only knows about the media ssrc
+	if sr, ok := packet.(*rtcp.SenderReport); ok && sr.SSRC == uint32(r.track.SSRC()) {
+		r.sync.OnRTCP(sr)
+	}
+}
+
+func (r *RemoteSampleReader) onPacketDropped() {
+	r.dropped.Inc()
+	metrics.RecordPacketDropped(r.pub.SID())
+
+	r.requestKeyFrame()
+}
+
+func (r *RemoteSampleReader) requestKeyFrame() {
+	if r.track.Kind() != webrtc.RTPCodecTypeVideo || r.pliWriter == nil {
+		return
+	}
+	if now := time.Now(); now.Sub(r.lastPLI.Load()) >= minPLIInterval {
+		r.lastPLI.Store(now)
+		r.pliWriter(r.track.SSRC())
+	}
+}
+
+func (r *RemoteSampleReader) readWorker() {
+	defer close(r.samples)
+
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	initialized := false
+	for !r.isClosed() {
+		i, _, err := r.track.Read(*buf)
+		if err != nil {
+			if err != io.EOF {
+				r.err.Store(err)
+			}
+			return
+		}
+		// the packet stays in the jitter buffer, it only keeps a copy of the bytes read
+		pkt := &rtp.Packet{}
+		if err = pkt.Unmarshal(append([]byte(nil), (*buf)[:i]...)); err != nil {
+			continue
+		}
+		pkts := []*rtp.Packet{pkt}
+		if r.red != nil {
+			if pkts, err = r.red.Decode(pkt); err != nil {
+				logger.Debugw("could not decode RED packet", "error", err, "track", r.pub.SID())
+				continue
+			}
+		}
+		for _, pkt := range pkts {
+			if !initialized {
+				r.trackSync.Initialize(pkt)
+				initialized = true
+				// decoding can only start from a keyframe
+				r.requestKeyFrame()
+			}
+			r.buffer.Push(pkt)
+		}
+		if !r.writeSamples(r.buffer.Pop(false)) {
+			return
+		}
+	}
+}
+
+// writeSamples splits the popped packets into samples by timestamp, returns false once the reader is done
+func (r *RemoteSampleReader) writeSamples(pkts []*rtp.Packet) bool {
+	for len(pkts) > 0 {
+		end := 1
+		for end < len(pkts) && pkts[end].Timestamp == pkts[0].Timestamp {
+			end++
+		}
+		if !r.writeSample(pkts[:end]) {
+			return false
+		}
+		pkts = pkts[end:]
+	}
+	return true
+}
+
+func (r *RemoteSampleReader) writeSample(pkts []*rtp.Packet) bool {
+	pts, err := r.trackSync.GetPTS(pkts[0])
+	if err != nil {
+		// synchronizer has ended
+		return false
+	}
+
+	var data []byte
+	for _, pkt := range pkts {
+		if len(pkt.Payload) == 0 {
+			continue
+		}
+		payload, err := r.depacketizer.Unmarshal(pkt.Payload)
+		if err != nil {
+			logger.Debugw("could not depacketize", "error", err, "track", r.pub.SID())
+			continue
+		}
+		data = append(data, payload...)
+	}
+	if len(data) == 0 {
+		return true
+	}
+	if r.cryptor != nil {
+		if data, err = r.cryptor.Decrypt(data); err != nil {
+			logger.Debugw("could not decrypt sample", "error", err, "track", r.pub.SID())
+			r.dropped.Inc()
+			r.requestKeyFrame()
+			return true
+		}
+	}
+
+	s := remoteSample{
+		sample: media.Sample{
+			Data:               data,
+			Timestamp:          time.Unix(0, r.sync.GetStartedAt()).Add(pts),
+			Duration:           r.trackSync.GetFrameDuration(),
+			PacketTimestamp:    pkts[0].Timestamp,
+			PrevDroppedPackets: uint16(r.dropped.Swap(0)),
+		},
+		pts: pts,
+	}
+	select {
+	case r.samples <- s:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func depacketizerForCodec(codec webrtc.RTPCodecCapability) (rtp.Depacketizer, error) {
+	switch strings.ToLower(codec.MimeType) {
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return &codecs.H264Packet{}, nil
+	case strings.ToLower(webrtc.MimeTypeOpus), mimeTypeRED:
+		// RED packets are decoded into opus ones first
+		return &codecs.OpusPacket{}, nil
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		return &codecs.VP8Packet{}, nil
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		return &codecs.VP9Packet{}, nil
+	case strings.ToLower(webrtc.MimeTypeH265):
+		return &sdkcodecs.H265Depacketizer{}, nil
+	case strings.ToLower(webrtc.MimeTypeAV1):
+		return &sdkcodecs.AV1Depacketizer{}, nil
+	case strings.ToLower(webrtc.MimeTypeG722), strings.ToLower(webrtc.MimeTypePCMU), strings.ToLower(webrtc.MimeTypePCMA):
+		return &rawAudioDepacketizer{}, nil
+	default:
+		return nil, ErrNoDepacketizerForCodec
+	}
+}
+
+// rawAudioDepacketizer handles codecs that put a whole sample in a packet without a payload header, like G.711
+type rawAudioDepacketizer struct{}
+
+func (d *rawAudioDepacketizer) Unmarshal(packet []byte) ([]byte, error) {
+	return packet, nil
+}
+
+func (d *rawAudioDepacketizer) IsPartitionHead(payload []byte) bool {
+	return true
+}
+
+func (d *rawAudioDepacketizer) IsPartitionTail(marker bool, payload []byte) bool {
+	return true
+}
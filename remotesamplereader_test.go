@@ -0,0 +1,201 @@
+package live_sdk_go
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	sdkcodecs "github.com/liuhailove/live-sdk-go/pkg/codecs"
+	"github.com/liuhailove/live-sdk-go/pkg/metrics"
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestRemoteSampleReader(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	subscribed := make(chan *RemoteTrackPublication, 1)
+	_, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	})
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	track, ti, err := sess.PublishTrack(pi.Sid, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "camera")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(33 * time.Millisecond)
+		defer ticker.Stop()
+		for i := byte(0); ; i++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = track.WriteSample(media.Sample{Data: []byte{0x10, i, i, i}, Duration: 33 * time.Millisecond})
+			}
+		}
+	}()
+
+	var pub *RemoteTrackPublication
+	select {
+	case pub = <-subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+
+	reader, err := pub.NewSampleReader()
+	require.NoError(t, err)
+	_, err = pub.NewSampleReader()
+	require.ErrorIs(t, err, ErrSampleReaderExists)
+
+	var lastPTS time.Duration
+	var lastData byte
+	for i := 0; i < 10; i++ {
+		sample, pts, err := reader.ReadSample()
+		require.NoError(t, err)
+		require.Len(t, sample.Data, 4)
+		require.Equal(t, byte(0x10), sample.Data[0])
+		if i > 0 {
+			require.Greater(t, pts, lastPTS)
+			require.Equal(t, lastData+1, sample.Data[1])
+		}
+		lastPTS, lastData = pts, sample.Data[1]
+	}
+
+	require.NoError(t, sess.UnpublishTrack(pi.Sid, ti.Sid))
+	require.Eventually(t, func() bool {
+		for {
+			if _, _, err := reader.ReadSample(); err != nil {
+				return err == io.EOF
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteSampleReaderRED(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	subscribed := make(chan *RemoteTrackPublication, 1)
+	_, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	}, WithReceiveRED(true))
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	track, _, err := sess.PublishRTPTrack(pi.Sid, webrtc.RTPCodecCapability{
+		MimeType: mimeTypeRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111",
+	}, "mic")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		encoder := sdkcodecs.NewREDEncoder(111, 2, 1000)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for i := uint16(0); ; i++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ts := uint32(i) * 960
+				payload := encoder.Encode([]byte{0xfc, byte(i)}, ts)
+				// two of every five packets are lost, they're recovered from the following ones
+				if i%5 == 1 || i%5 == 2 {
+					continue
+				}
+				_ = track.WriteRTP(&rtp.Packet{
+					Header:  rtp.Header{Version: 2, SequenceNumber: i, Timestamp: ts},
+					Payload: payload,
+				})
+			}
+		}
+	}()
+
+	var pub *RemoteTrackPublication
+	select {
+	case pub = <-subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+	reader, err := pub.NewSampleReader()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var last byte
+	for i := 0; i < 20; i++ {
+		sample, _, err := reader.ReadSample()
+		require.NoError(t, err)
+		require.Len(t, sample.Data, 2)
+		if i > 0 {
+			require.Equal(t, last+1, sample.Data[1])
+		}
+		last = sample.Data[1]
+	}
+}
+
+func TestRemoteSampleReaderMetrics(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, metrics.Register(registry))
+	defer metrics.Unregister(registry)
+
+	subscribed := make(chan *RemoteTrackPublication, 1)
+	_, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	})
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	track, _, err := sess.PublishTrack(pi.Sid, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "camera")
+	require.NoError(t, err)
+	done := make(chan struct{})
+	defer close(done)
+	go writeTestSamples(track, done)
+
+	var pub *RemoteTrackPublication
+	select {
+	case pub = <-subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+	reader, err := pub.NewSampleReader()
+	require.NoError(t, err)
+
+	reader.onPacketDropped()
+	count, err := testutil.GatherAndCount(registry, "live_sdk_track_packets_dropped_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// the drop counters are deleted along with the track
+	require.NoError(t, sess.RemoveParticipant(pi.Sid))
+	require.Eventually(t, func() bool {
+		count, err := testutil.GatherAndCount(registry, "live_sdk_track_packets_dropped_total")
+		return err == nil && count == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
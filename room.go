@@ -1,16 +1,27 @@
 package live_sdk_go
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
+	"github.com/pion/ice/v2"
+	"github.com/pion/interceptor"
 	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 	"github.com/thoas/go-funk"
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
+	"net"
+	"net/url"
 	"reflect"
 	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
 )
 
 // SimulateScenario 模拟场景
@@ -38,9 +49,63 @@ type ConnectInfo struct {
 }
 
 type ConnectParams struct {
-	AutoSubscribe bool
-	Reconnect     bool
-	Callback      *RoomCallback
+	AutoSubscribe   bool
+	Reconnect       bool
+	Callback        *RoomCallback
+	ReconnectPolicy ReconnectPolicy
+	StatsInterval   time.Duration
+
+	FailoverURLs    []string
+	RegionDiscovery bool
+
+	TokenProvider TokenProvider
+
+	// frames of published and subscribed tracks are encrypted end-to-end with its keys
+	KeyProvider *e2ee.KeyProvider
+
+	Proxy     *url.URL
+	TLSConfig *tls.Config
+	RootCAs   *x509.CertPool
+
+	// network settings of the peer connections
+	UDPPortMin           uint16
+	UDPPortMax           uint16
+	NAT1To1IPs           []string
+	NAT1To1CandidateType webrtc.ICECandidateType
+	InterfaceFilter      func(name string) bool
+	IPFilter             func(ip net.IP) bool
+	ICETimeouts          *ICETimeouts
+	ICETransportPolicy   webrtc.ICETransportPolicy
+	UDPMux               ice.UDPMux
+
+	// media settings of the peer connections, added to the ones of the SDK
+	Interceptors     []interceptor.Factory
+	Codecs           []webrtc.RTPCodecParameters
+	HeaderExtensions []HeaderExtension
+	// subscribed audio is negotiated as RED when the publisher sends it
+	ReceiveRED bool
+
+	// peer connections are created on the api of the RoomManager, which has the network and media settings
+	sharedAPI *pcAPI
+}
+
+// HeaderExtension is an RTP header extension negotiated for a kind of media
+type HeaderExtension struct {
+	Capability webrtc.RTPHeaderExtensionCapability
+	Kind       webrtc.RTPCodecType
+	// directions the extension is allowed in, all when empty
+	Directions []webrtc.RTPTransceiverDirection
+}
+
+// ICETimeouts controls when an ICE connection without network activity is considered lost,
+// zero fields keep the defaults of 5s, 25s and 2s
+type ICETimeouts struct {
+	// without activity for this long the connection is disconnected
+	Disconnected time.Duration
+	// then after this long it fails, and the room reconnects
+	Failed time.Duration
+	// traffic is sent at this interval when no media flows
+	KeepAlive time.Duration
 }
 
 type ConnectOption func(params *ConnectParams)
@@ -51,6 +116,163 @@ func WithAutoSubscribe(val bool) ConnectOption {
 	}
 }
 
+// WithReconnectPolicy overrides how the connection is recovered after a disconnect,
+// defaults to DefaultReconnectPolicy
+func WithReconnectPolicy(policy ReconnectPolicy) ConnectOption {
+	return func(p *ConnectParams) {
+		p.ReconnectPolicy = policy
+	}
+}
+
+// WithStatsInterval enables RoomCallback.OnStats, called with the room stats every interval
+func WithStatsInterval(interval time.Duration) ConnectOption {
+	return func(p *ConnectParams) {
+		p.StatsInterval = interval
+	}
+}
+
+// WithTokenProvider fetches a new token before every resume or restart, and ahead of the expiry of the current one,
+// so the room can reconnect after its join token expired. Joining with ConnectInfo mints new tokens by default
+func WithTokenProvider(provider TokenProvider) ConnectOption {
+	return func(p *ConnectParams) {
+		p.TokenProvider = provider
+	}
+}
+
+// WithE2EE encrypts the frames of published tracks and decrypts the ones of subscribed encrypted tracks
+// with the keys of keyProvider, compatible with the AES-GCM end-to-end encryption of LiveKit clients
+func WithE2EE(keyProvider *e2ee.KeyProvider) ConnectOption {
+	return func(p *ConnectParams) {
+		p.KeyProvider = keyProvider
+	}
+}
+
+// WithFailoverURLs adds urls tried in order when the signal connection to the join url fails,
+// e.g. the other regions of a multi-region deployment
+func WithFailoverURLs(urls ...string) ConnectOption {
+	return func(p *ConnectParams) {
+		p.FailoverURLs = append(p.FailoverURLs, urls...)
+	}
+}
+
+// WithRegionDiscovery fetches the regions of the deployment from the /settings/regions endpoint when joining the url
+// fails, asking the failover urls if the url doesn't answer, and tries them closest first after any failover urls
+func WithRegionDiscovery(val bool) ConnectOption {
+	return func(p *ConnectParams) {
+		p.RegionDiscovery = val
+	}
+}
+
+// WithProxy connects through an HTTP CONNECT (http, https) or SOCKS5 (socks5, socks5h) proxy, credentials are
+// taken from the url. The proxy is used for the signal connection, its http requests and TURN over TCP
+func WithProxy(proxyURL *url.URL) ConnectOption {
+	return func(p *ConnectParams) {
+		p.Proxy = proxyURL
+	}
+}
+
+// WithTLSConfig sets the TLS config of the signal connection and its http requests
+func WithTLSConfig(config *tls.Config) ConnectOption {
+	return func(p *ConnectParams) {
+		p.TLSConfig = config
+	}
+}
+
+// WithRootCAs sets the certificate authorities trusted by the signal connection, overriding the ones of WithTLSConfig
+func WithRootCAs(pool *x509.CertPool) ConnectOption {
+	return func(p *ConnectParams) {
+		p.RootCAs = pool
+	}
+}
+
+// WithUDPPortRange limits the local UDP ports of ICE candidates to [portMin, portMax]
+func WithUDPPortRange(portMin, portMax uint16) ConnectOption {
+	return func(p *ConnectParams) {
+		p.UDPPortMin = portMin
+		p.UDPPortMax = portMax
+	}
+}
+
+// WithNAT1To1IPs advertises ips in place of the local addresses, e.g. the public IP of a host behind a 1:1 NAT.
+// candidateType is either webrtc.ICECandidateTypeHost to replace the host candidates, or
+// webrtc.ICECandidateTypeSrflx to add them as server reflexive candidates
+func WithNAT1To1IPs(ips []string, candidateType webrtc.ICECandidateType) ConnectOption {
+	return func(p *ConnectParams) {
+		p.NAT1To1IPs = ips
+		p.NAT1To1CandidateType = candidateType
+	}
+}
+
+// WithInterfaceFilter gathers ICE candidates only on the network interfaces filter returns true for
+func WithInterfaceFilter(filter func(name string) bool) ConnectOption {
+	return func(p *ConnectParams) {
+		p.InterfaceFilter = filter
+	}
+}
+
+// WithIPFilter gathers ICE candidates only on the local IPs filter returns true for
+func WithIPFilter(filter func(ip net.IP) bool) ConnectOption {
+	return func(p *ConnectParams) {
+		p.IPFilter = filter
+	}
+}
+
+// WithICETimeouts changes how fast a lost ICE connection is detected
+func WithICETimeouts(timeouts ICETimeouts) ConnectOption {
+	return func(p *ConnectParams) {
+		p.ICETimeouts = &timeouts
+	}
+}
+
+// WithICETransportPolicy sets the candidates used, webrtc.ICETransportPolicyRelay connects through TURN only.
+// The server can force relay regardless
+func WithICETransportPolicy(policy webrtc.ICETransportPolicy) ConnectOption {
+	return func(p *ConnectParams) {
+		p.ICETransportPolicy = policy
+	}
+}
+
+// WithUDPMux serves the ICE traffic of both peer connections on the single socket of mux,
+// it can be shared by several rooms
+func WithUDPMux(mux ice.UDPMux) ConnectOption {
+	return func(p *ConnectParams) {
+		p.UDPMux = mux
+	}
+}
+
+// WithInterceptors adds interceptors to the peer connections, after the ones of the SDK.
+// Each factory creates an interceptor for every peer connection
+func WithInterceptors(factories ...interceptor.Factory) ConnectOption {
+	return func(p *ConnectParams) {
+		p.Interceptors = append(p.Interceptors, factories...)
+	}
+}
+
+// WithCodecs registers codecs next to the default ones, defaults can't be replaced: a codec has to use a payload
+// type none of the codecs of pion and the SDK use, or the SDP conflicts. The kind is taken from the mime type,
+// e.g. "video/H265"
+func WithCodecs(codecs ...webrtc.RTPCodecParameters) ConnectOption {
+	return func(p *ConnectParams) {
+		p.Codecs = append(p.Codecs, codecs...)
+	}
+}
+
+// WithReceiveRED negotiates RED (RFC 2198) for subscribed audio, so lost opus frames are recovered from the
+// redundancy of the next packets. Tracks published with RED then carry audio/red payloads: RemoteSampleReader
+// decodes them, apps reading the TrackRemote directly have to unwrap the opus frames themselves
+func WithReceiveRED(val bool) ConnectOption {
+	return func(p *ConnectParams) {
+		p.ReceiveRED = val
+	}
+}
+
+// WithHeaderExtensions registers RTP header extensions next to the ones used by the SDK
+func WithHeaderExtensions(extensions ...HeaderExtension) ConnectOption {
+	return func(p *ConnectParams) {
+		p.HeaderExtensions = append(p.HeaderExtensions, extensions...)
+	}
+}
+
 type PLIWriter func(ssrc webrtc.SSRC)
 
 type Room struct {
@@ -64,6 +286,8 @@ type Room struct {
 	metadata       string
 	activeSpeakers []Participant
 	serverInfo     *livekit.ServerInfo
+	disconnected   atomic.Bool
+	done           chan struct{}
 
 	lock sync.RWMutex
 }
@@ -75,9 +299,11 @@ func CreateRoom(callback *RoomCallback) *Room {
 		engine:       engine,
 		participants: make(map[string]*RemoteParticipant),
 		callback:     NewRoomCallback(),
+		done:         make(chan struct{}),
 	}
 	r.callback.Merge(callback)
 	r.LocalParticipant = newLocalParticipant(engine, r.callback)
+	r.LocalParticipant.getParticipantByIdentity = r.GetParticipantByIdentity
 
 	// callbacks from engine
 	engine.OnMediaTrack = r.handleMediaTrack
@@ -91,8 +317,11 @@ func CreateRoom(callback *RoomCallback) *Room {
 	engine.OnRestarted = r.handleRestarted
 	engine.OnResuming = r.handleResuming
 	engine.OnResumed = r.handleResumed
+	engine.OnConnectionStateChanged = r.handleConnectionStateChanged
+	engine.OnTokenRefreshed = r.handleTokenRefreshed
 	engine.client.OnLocalTrackUnpublished = r.handleLocalTrackUnpublished
 	engine.client.OnTrackMuted = r.handleTrackMuted
+	engine.client.OnSubscribedQualityUpdate = r.LocalParticipant.handleSubscribedQualityUpdate
 
 	return r
 }
@@ -117,6 +346,41 @@ func ConnectToRoomWithToken(url, token string, callback *RoomCallback, opts ...C
 	return room, nil
 }
 
+// ConnectToRoomContext creates and joins the room, giving up once ctx is done
+func ConnectToRoomContext(ctx context.Context, url string, info ConnectInfo, callback *RoomCallback, opts ...ConnectOption) (*Room, error) {
+	room := CreateRoom(callback)
+	err := room.JoinContext(ctx, url, info, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// ConnectToRoomWithTokenContext creates and joins the room, giving up once ctx is done
+func ConnectToRoomWithTokenContext(ctx context.Context, url, token string, callback *RoomCallback, opts ...ConnectOption) (*Room, error) {
+	room := CreateRoom(callback)
+	err := room.JoinWithTokenContext(ctx, url, token, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// ServerURL returns the url of the region the room is connected to
+func (r *Room) ServerURL() string {
+	return r.engine.url.Load()
+}
+
+// Region returns the name of the region the room is connected to, if known
+func (r *Room) Region() string {
+	return r.engine.region.Load()
+}
+
+// SignalRTT returns the round trip time to the server measured by the keepalive pings of the signal connection
+func (r *Room) SignalRTT() time.Duration {
+	return r.engine.client.RTT()
+}
+
 func (r *Room) Name() string {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
@@ -131,6 +395,11 @@ func (r *Room) SID() string {
 
 // Join - joins the room as with default permissions
 func (r *Room) Join(url string, info ConnectInfo, opts ...ConnectOption) error {
+	return r.JoinContext(context.Background(), url, info, opts...)
+}
+
+// JoinContext - same as Join, but gives up connecting once ctx is done
+func (r *Room) JoinContext(ctx context.Context, url string, info ConnectInfo, opts ...ConnectOption) error {
 	var params ConnectParams
 	for _, opt := range opts {
 		opt(&params)
@@ -140,7 +409,22 @@ func (r *Room) Join(url string, info ConnectInfo, opts ...ConnectOption) error {
 		r.callback.Merge(params.Callback)
 	}
 
-	// generate token
+	token, err := info.toJWT()
+	if err != nil {
+		return err
+	}
+	if params.TokenProvider == nil {
+		// the join token would expire otherwise, leaving the room unable to reconnect
+		opts = append(opts, WithTokenProvider(func(ctx context.Context) (string, error) {
+			return info.toJWT()
+		}))
+	}
+
+	return r.JoinWithTokenContext(ctx, url, token, opts...)
+}
+
+// toJWT generates a token for the participant
+func (info ConnectInfo) toJWT() (string, error) {
 	at := auth.NewAccessToken(info.APIKey, info.APISecret)
 	grant := &auth.VideoGrant{
 		RoomJoin: true,
@@ -150,17 +434,16 @@ func (r *Room) Join(url string, info ConnectInfo, opts ...ConnectOption) error {
 		SetIdentity(info.ParticipantIdentity).
 		SetMetadata(info.ParticipantMetadata).
 		SetName(info.ParticipantName)
-
-	token, err := at.ToJWT()
-	if err != nil {
-		return err
-	}
-
-	return r.JoinWithToken(url, token, opts...)
+	return at.ToJWT()
 }
 
 // JoinWithToken - customize participant options by generating your own token
 func (r *Room) JoinWithToken(url, token string, opts ...ConnectOption) error {
+	return r.JoinWithTokenContext(context.Background(), url, token, opts...)
+}
+
+// JoinWithTokenContext - same as JoinWithToken, but gives up connecting once ctx is done
+func (r *Room) JoinWithTokenContext(ctx context.Context, url, token string, opts ...ConnectOption) error {
 	params := &ConnectParams{
 		AutoSubscribe: true,
 	}
@@ -168,7 +451,7 @@ func (r *Room) JoinWithToken(url, token string, opts ...ConnectOption) error {
 		opt(params)
 	}
 
-	joinRes, err := r.engine.Join(url, token, params)
+	joinRes, err := r.engine.JoinContext(ctx, url, token, params)
 	if err != nil {
 		return err
 	}
@@ -186,16 +469,79 @@ func (r *Room) JoinWithToken(url, token string, opts ...ConnectOption) error {
 		r.addRemoteParticipant(pi, true)
 	}
 
+	if params.StatsInterval > 0 {
+		go r.statsWorker(params.StatsInterval)
+	}
+
 	return nil
 }
 
+// UpdateToken replaces the token used by the next resume or restart, e.g. when it's refreshed out of band.
+// OnTokenRefreshed is not called for it
+func (r *Room) UpdateToken(token string) {
+	r.engine.setToken(token, false)
+}
+
 func (r *Room) Disconnect() {
 	_ = r.engine.client.SendLeave()
 	r.engine.Close()
+	r.engine.setConnectionState(ConnectionStateDisconnected, nil)
+	r.notifyDisconnected(livekit.DisconnectReason_CLIENT_INITIATED)
 
 	r.LocalParticipant.closeTracks()
 }
 
+// ConnectionState returns the current state of the connection to the room
+func (r *Room) ConnectionState() ConnectionState {
+	return r.engine.ConnectionState()
+}
+
+// GetStats returns the stats of both peer connections and all published and subscribed tracks,
+// rates are averaged since the previous call
+func (r *Room) GetStats() *RoomStats {
+	return r.getStats(statsCallerAPI)
+}
+
+func (r *Room) getStats(caller statsCaller) *RoomStats {
+	stats := &RoomStats{
+		Timestamp: time.Now(),
+	}
+	if t := r.engine.publisher; t != nil {
+		stats.Publisher = t.getStats(caller)
+	}
+	if t := r.engine.subscriber; t != nil {
+		stats.Subscriber = t.getStats(caller)
+	}
+	for _, pub := range r.LocalParticipant.Tracks() {
+		if lp, ok := pub.(*LocalTrackPublication); ok {
+			stats.LocalTracks = append(stats.LocalTracks, lp.getStats(caller)...)
+		}
+	}
+	for _, rp := range r.GetParticipants() {
+		for _, pub := range rp.Tracks() {
+			if rpub, ok := pub.(*RemoteTrackPublication); ok {
+				if ts := rpub.getStats(caller); ts != nil {
+					stats.RemoteTracks = append(stats.RemoteTracks, ts)
+				}
+			}
+		}
+	}
+	return stats
+}
+
+func (r *Room) statsWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.callback.OnStats(r.getStats(statsCallerWorker))
+		}
+	}
+}
+
 func (r *Room) GetParticipant(sid string) *RemoteParticipant {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
@@ -203,6 +549,18 @@ func (r *Room) GetParticipant(sid string) *RemoteParticipant {
 	return r.participants[sid]
 }
 
+func (r *Room) GetParticipantByIdentity(identity string) *RemoteParticipant {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, rp := range r.participants {
+		if rp.Identity() == identity {
+			return rp
+		}
+	}
+	return nil
+}
+
 func (r *Room) GetParticipants() []*RemoteParticipant {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
@@ -244,7 +602,7 @@ func (r *Room) addRemoteParticipant(pi *livekit.ParticipantInfo, updateExisting
 		return rp
 	}
 
-	rp = newRemoteParticipant(pi, r.callback, r.engine.client, func(ssrc webrtc.SSRC) {
+	rp = newRemoteParticipant(pi, r.callback, r.engine.client, r.engine.keyProvider(), func(ssrc webrtc.SSRC) {
 		pli := []rtcp.Packet{
 			&rtcp.PictureLossIndication{SenderSSRC: uint32(ssrc), MediaSSRC: uint32(ssrc)},
 		}
@@ -266,14 +624,28 @@ func (r *Room) handleMediaTrack(track *webrtc.TrackRemote, receiver *webrtc.RTPR
 	rp := r.addRemoteParticipant(&livekit.ParticipantInfo{
 		Sid: participantID,
 	}, false)
-	rp.addSubscribedMediaTrack(track, trackID, receiver)
+	rp.addSubscribedMediaTrack(track, trackID, receiver, r.engine.subscriber)
 }
 
-func (r *Room) handleDisconnect() {
-	r.callback.OnDisconnected()
+func (r *Room) handleDisconnect(reason livekit.DisconnectReason) {
+	r.notifyDisconnected(reason)
 	r.engine.Close()
 }
 
+// notifyDisconnected fires the disconnect callbacks, at most once per room
+func (r *Room) notifyDisconnected(reason livekit.DisconnectReason) {
+	if r.disconnected.Swap(true) {
+		return
+	}
+	close(r.done)
+	logger.Infow("disconnected from room", "room", r.Name(), "reason", reason)
+	// Disconnect never notified OnDisconnected
+	if reason != livekit.DisconnectReason_CLIENT_INITIATED {
+		r.callback.OnDisconnected()
+	}
+	r.callback.OnDisconnectedWithReason(reason)
+}
+
 func (r *Room) handleRestarting() {
 	r.callback.OnReconnecting()
 
@@ -306,6 +678,14 @@ func (r *Room) handleResumed() {
 	r.sendSyncState()
 }
 
+func (r *Room) handleConnectionStateChanged(state ConnectionState, reason error) {
+	r.callback.OnConnectionStateChanged(state, reason)
+}
+
+func (r *Room) handleTokenRefreshed(token string) {
+	r.callback.OnTokenRefreshed(token)
+}
+
 func (r *Room) handleDataReceived(userPacket *livekit.UserPacket) {
 	if userPacket.ParticipantSid == r.LocalParticipant.sid {
 		return
@@ -314,8 +694,22 @@ func (r *Room) handleDataReceived(userPacket *livekit.UserPacket) {
 	if p == nil {
 		return
 	}
-	p.Callback.OnDataReceived(userPacket.Payload, p)
-	r.callback.OnDataReceived(userPacket.Payload, p)
+
+	payload, topic := userPacket.Payload, userPacket.GetTopic()
+	if topic == dataChunkTopic {
+		var complete bool
+		if payload, topic, complete = p.dataChunks.push(payload); !complete {
+			return
+		}
+	}
+	if r.LocalParticipant.handleRPCPacket(topic, payload, p) || strings.HasPrefix(topic, internalTopicPrefix) {
+		return
+	}
+
+	p.Callback.OnDataReceived(payload, p)
+	r.callback.OnDataReceived(payload, p)
+	p.Callback.OnDataReceivedWithTopic(payload, topic, p)
+	r.callback.OnDataReceivedWithTopic(payload, topic, p)
 }
 
 func (r *Room) handleParticipantUpdate(participants []*livekit.ParticipantInfo) {
@@ -348,6 +742,7 @@ func (r *Room) handleParticipantDisconnect(p *RemoteParticipant) {
 	r.lock.Unlock()
 
 	p.unpublishAllTracks()
+	r.LocalParticipant.handleParticipantDisconnected(p.SID())
 	go r.callback.OnParticipantDisconnected(p)
 }
 
@@ -552,7 +947,7 @@ func (r *Room) Simulate(scenario SimulateScenario) {
 func unpackStreamID(packed string) (participantId string, trackId string) {
 	parts := strings.Split(packed, "|")
 	if len(parts) > 1 {
-		return parts[0], packed[len(parts[0])+2:]
+		return parts[0], packed[len(parts[0])+1:]
 	}
 	return packed, ""
 }
@@ -0,0 +1,458 @@
+package live_sdk_go
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/e2ee"
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestRoomDisconnectReason(t *testing.T) {
+	t.Run("server leave", func(t *testing.T) {
+		var reasons []livekit.DisconnectReason
+		var disconnects int
+		room := CreateRoom(&RoomCallback{
+			OnDisconnected: func() {
+				disconnects++
+			},
+			OnDisconnectedWithReason: func(reason livekit.DisconnectReason) {
+				reasons = append(reasons, reason)
+			},
+		})
+
+		room.engine.handleLeave(&livekit.LeaveRequest{Reason: livekit.DisconnectReason_ROOM_DELETED})
+		require.Equal(t, []livekit.DisconnectReason{livekit.DisconnectReason_ROOM_DELETED}, reasons)
+		require.Equal(t, 1, disconnects)
+		require.Equal(t, ConnectionStateDisconnected, room.ConnectionState())
+
+		// disconnecting afterwards should not notify again
+		room.Disconnect()
+		require.Len(t, reasons, 1)
+		require.Equal(t, 1, disconnects)
+	})
+
+	t.Run("client initiated", func(t *testing.T) {
+		var reasons []livekit.DisconnectReason
+		var disconnects int
+		room := CreateRoom(&RoomCallback{
+			OnDisconnected: func() {
+				disconnects++
+			},
+			OnDisconnectedWithReason: func(reason livekit.DisconnectReason) {
+				reasons = append(reasons, reason)
+			},
+		})
+
+		room.Disconnect()
+		require.Equal(t, []livekit.DisconnectReason{livekit.DisconnectReason_CLIENT_INITIATED}, reasons)
+		// like before reasons were reported
+		require.Zero(t, disconnects)
+	})
+}
+
+func TestUnpackStreamID(t *testing.T) {
+	participantID, trackID := unpackStreamID("PA_alice|TR_mic")
+	require.Equal(t, "PA_alice", participantID)
+	require.Equal(t, "TR_mic", trackID)
+
+	participantID, trackID = unpackStreamID("PA_alice")
+	require.Equal(t, "PA_alice", participantID)
+	require.Empty(t, trackID)
+}
+
+func connectTestRoom(t *testing.T, srv *testserver.Server, identity string, cb *RoomCallback, opts ...ConnectOption) (*Room, *testserver.Session) {
+	room, err := ConnectToRoomWithToken(srv.URL(), srv.Token("test-room", identity), cb, opts...)
+	require.NoError(t, err)
+	t.Cleanup(room.Disconnect)
+
+	sess := srv.WaitForSession(identity, time.Second)
+	require.NotNil(t, sess)
+	return room, sess
+}
+
+func TestRoomJoin(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	var states []ConnectionState
+	var lock sync.Mutex
+	room, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnConnectionStateChanged: func(state ConnectionState, reason error) {
+			lock.Lock()
+			states = append(states, state)
+			lock.Unlock()
+		},
+	})
+
+	require.Equal(t, "test-room", room.Name())
+	require.Equal(t, ConnectionStateConnected, room.ConnectionState())
+	require.Equal(t, sess.Info().Sid, room.LocalParticipant.SID())
+	lock.Lock()
+	require.Equal(t, []ConnectionState{ConnectionStateConnecting, ConnectionStateConnected}, states)
+	lock.Unlock()
+}
+
+func TestRoomRemoteTrackSubscribed(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	subscribed := make(chan *RemoteTrackPublication, 1)
+	connected := make(chan *RemoteParticipant, 1)
+	_, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnParticipantConnected: func(rp *RemoteParticipant) {
+			connected <- rp
+		},
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	})
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	select {
+	case rp := <-connected:
+		require.Equal(t, "alice", rp.Identity())
+	case <-time.After(5 * time.Second):
+		t.Fatal("participant not connected")
+	}
+
+	track, ti, err := sess.PublishTrack(pi.Sid, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "mic")
+	require.NoError(t, err)
+	done := make(chan struct{})
+	defer close(done)
+	go writeTestSamples(track, done)
+
+	select {
+	case pub := <-subscribed:
+		require.Equal(t, ti.Sid, pub.SID())
+		require.Equal(t, TrackKindAudio, pub.Kind())
+		require.True(t, pub.IsSubscribed())
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+}
+
+func TestRoomPublishTrack(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	received := make(chan *webrtc.TrackRemote, 1)
+	srv.OnTrack = func(s *testserver.Session, track *webrtc.TrackRemote) {
+		received <- track
+	}
+	room, sess := connectTestRoom(t, srv, "bot", nil)
+
+	track, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	require.NoError(t, err)
+	require.NoError(t, track.StartWrite(NewNullSampleProvider(16000), nil))
+
+	pub, err := room.LocalParticipant.PublishTrack(track, &TrackPublicationOptions{Name: "mic"})
+	require.NoError(t, err)
+	require.Len(t, sess.PublishedTracks(), 1)
+	require.Equal(t, sess.PublishedTracks()[0].Sid, pub.SID())
+	require.Equal(t, livekit.TrackSource_MICROPHONE, pub.Source())
+
+	select {
+	case remote := <-received:
+		require.Equal(t, webrtc.RTPCodecTypeAudio, remote.Kind())
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not receive published media")
+	}
+}
+
+func TestRoomResume(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	reconnecting := make(chan struct{}, 1)
+	reconnected := make(chan struct{}, 1)
+	room, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnReconnecting: func() {
+			reconnecting <- struct{}{}
+		},
+		OnReconnected: func() {
+			reconnected <- struct{}{}
+		},
+	})
+
+	sess.CloseSignal()
+	select {
+	case <-reconnecting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not start reconnecting")
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("did not reconnect")
+	}
+	require.Equal(t, ConnectionStateConnected, room.ConnectionState())
+	// same session was resumed
+	require.Same(t, sess, srv.Session("bot"))
+	require.Eventually(t, func() bool {
+		for _, req := range sess.Requests() {
+			if req.GetSyncState() != nil {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRoomTokenRefresh(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	// expires in a couple of seconds, the provider is called ahead of it
+	at := auth.NewAccessToken(testserver.DefaultAPIKey, testserver.DefaultAPISecret)
+	at.AddGrant(&auth.VideoGrant{RoomJoin: true, Room: "test-room"}).
+		SetIdentity("bot").
+		SetValidFor(2 * time.Second)
+	token, err := at.ToJWT()
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	var provided []string
+	provider := func(ctx context.Context) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		provided = append(provided, srv.Token("test-room", "bot"))
+		return provided[len(provided)-1], nil
+	}
+	refreshed := make(chan string, 10)
+	reconnected := make(chan struct{}, 1)
+	room, err := ConnectToRoomWithToken(srv.URL(), token, &RoomCallback{
+		OnTokenRefreshed: func(token string) {
+			refreshed <- token
+		},
+		OnReconnected: func() {
+			reconnected <- struct{}{}
+		},
+	}, WithTokenProvider(provider))
+	require.NoError(t, err)
+	defer room.Disconnect()
+	sess := srv.WaitForSession("bot", time.Second)
+	require.NotNil(t, sess)
+
+	select {
+	case refreshedToken := <-refreshed:
+		require.NotEqual(t, token, refreshedToken)
+		require.Equal(t, refreshedToken, room.engine.token.Load())
+	case <-time.After(5 * time.Second):
+		t.Fatal("token was not refreshed ahead of its expiry")
+	}
+
+	// the provider is called before resuming, once the first token expired
+	time.Sleep(2 * time.Second)
+	sess.CloseSignal()
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("did not reconnect")
+	}
+	lock.Lock()
+	require.Len(t, provided, 2)
+	require.Equal(t, provided[1], room.engine.token.Load())
+	lock.Unlock()
+
+	// tokens refreshed by the server are reported as well
+	require.NoError(t, sess.SendResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_RefreshToken{RefreshToken: "server-token"},
+	}))
+	require.Eventually(t, func() bool {
+		return room.engine.token.Load() == "server-token"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	room.UpdateToken("updated-token")
+	require.Equal(t, "updated-token", room.engine.token.Load())
+}
+
+func TestRoomE2EE(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	// each participant has its own copy of the shared key
+	aliceKeys, err := e2ee.NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+	bobKeys, err := e2ee.NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+
+	subscribed := make(chan *RemoteTrackPublication, 1)
+	_, bobSess := connectTestRoom(t, srv, "bob", &RoomCallback{
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				subscribed <- pub
+			},
+		},
+	}, WithE2EE(bobKeys))
+	srv.OnTrack = func(s *testserver.Session, track *webrtc.TrackRemote) {
+		if s.Identity() == "alice" {
+			require.NoError(t, bobSess.ForwardTrack(s, track))
+		}
+	}
+	alice, _ := connectTestRoom(t, srv, "alice", nil, WithE2EE(aliceKeys))
+
+	track, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000})
+	require.NoError(t, err)
+	_, err = alice.LocalParticipant.PublishTrack(track, &TrackPublicationOptions{Name: "camera"})
+	require.NoError(t, err)
+	require.True(t, track.IsEncrypted())
+
+	// key frames of increasing counters, the VP8 header stays in the clear
+	frame := func(i byte) []byte {
+		return []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0xe0, 0x01, i, i, i, i}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(33 * time.Millisecond)
+		defer ticker.Stop()
+		for i := byte(0); ; i++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = track.WriteSample(media.Sample{Data: frame(i), Duration: 33 * time.Millisecond}, nil)
+			}
+		}
+	}()
+
+	var pub *RemoteTrackPublication
+	select {
+	case pub = <-subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("track not subscribed")
+	}
+	require.Equal(t, livekit.Encryption_GCM, pub.TrackInfo().Encryption)
+	require.Equal(t, "alice", pub.participantIdentity)
+
+	reader, err := pub.NewSampleReader()
+	require.NoError(t, err)
+	defer reader.Close()
+	var last byte
+	for i := 0; i < 10; i++ {
+		sample, _, err := reader.ReadSample()
+		require.NoError(t, err)
+		require.Equal(t, frame(sample.Data[len(sample.Data)-1]), sample.Data)
+		if i > 0 {
+			require.Equal(t, last+1, sample.Data[len(sample.Data)-1])
+		}
+		last = sample.Data[len(sample.Data)-1]
+	}
+}
+
+func TestEncryptHeldParameterSets(t *testing.T) {
+	keys, err := e2ee.NewSharedKeyProvider([]byte("secret"))
+	require.NoError(t, err)
+	encryptor, err := e2ee.NewFrameCryptor(keys, "alice", webrtc.MimeTypeH264)
+	require.NoError(t, err)
+	decryptor, err := e2ee.NewFrameCryptor(keys, "alice", webrtc.MimeTypeH264)
+	require.NoError(t, err)
+
+	// parameter sets are held back until the slice, the sample buffer is reused in between
+	track := &LocalSampleTrack{}
+	buf := []byte{0, 0, 0, 1, 0x67, 0x42}
+	_, err = track.encryptFrame(encryptor, buf, 1, 3000)
+	require.ErrorIs(t, err, e2ee.ErrNoSlice)
+	copy(buf, []byte{0, 0, 0, 1, 0x41, 0x9a})
+
+	encrypted, err := track.encryptFrame(encryptor, buf, 1, 3000)
+	require.NoError(t, err)
+	decrypted, err := decryptor.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 0, 1, 0x41, 0x9a}, decrypted)
+}
+
+func TestRoomServerLeave(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	reasons := make(chan livekit.DisconnectReason, 1)
+	room, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnDisconnectedWithReason: func(reason livekit.DisconnectReason) {
+			reasons <- reason
+		},
+	})
+
+	require.NoError(t, sess.SendLeave(livekit.DisconnectReason_PARTICIPANT_REMOVED, false))
+	select {
+	case reason := <-reasons:
+		require.Equal(t, livekit.DisconnectReason_PARTICIPANT_REMOVED, reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not disconnected")
+	}
+	require.Equal(t, ConnectionStateDisconnected, room.ConnectionState())
+}
+
+func TestRoomSpeakersAndData(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	speakers := make(chan []Participant, 1)
+	data := make(chan []byte, 1)
+	room, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnActiveSpeakersChanged: func(p []Participant) {
+			speakers <- p
+		},
+		ParticipantCallback: ParticipantCallback{
+			OnDataReceived: func(payload []byte, rp *RemoteParticipant) {
+				data <- payload
+			},
+		},
+	})
+
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return room.GetParticipant(pi.Sid) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, sess.SendSpeakersChanged(&livekit.SpeakerInfo{Sid: pi.Sid, Level: 0.5, Active: true}))
+	select {
+	case p := <-speakers:
+		require.Len(t, p, 1)
+		require.Equal(t, "alice", p[0].Identity())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no speaker update")
+	}
+
+	require.Eventually(t, func() bool {
+		return sess.SendData(&livekit.DataPacket{
+			Kind: livekit.DataPacket_RELIABLE,
+			Value: &livekit.DataPacket_User{User: &livekit.UserPacket{
+				ParticipantSid: pi.Sid,
+				Payload:        []byte("hello"),
+			}},
+		}) == nil
+	}, 10*time.Second, 50*time.Millisecond)
+	select {
+	case payload := <-data:
+		require.Equal(t, []byte("hello"), payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+}
+
+func writeTestSamples(track *webrtc.TrackLocalStaticSample, done <-chan struct{}) {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_ = track.WriteSample(media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond})
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package live_sdk_go
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAPIKey    = "APIkey"
+	testAPISecret = "secret"
+)
+
+// fakeRoomService is a twirp stand-in that records the grants each call was made with
+type fakeRoomService struct {
+	lock   sync.Mutex
+	grants map[string]*auth.VideoGrant
+}
+
+func newTestRoomServiceClient(t *testing.T) (*RoomServiceClient, *fakeRoomService) {
+	svc := &fakeRoomService{grants: make(map[string]*auth.VideoGrant)}
+	twirpServer := livekit.NewRoomServiceServer(svc)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		v, err := auth.ParseAPIToken(token)
+		if err != nil || v.APIKey() != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		claims, err := v.Verify(testAPISecret)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		svc.lock.Lock()
+		svc.grants[method] = claims.Video
+		svc.lock.Unlock()
+		twirpServer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewRoomServiceClient(server.URL, testAPIKey, testAPISecret), svc
+}
+
+func (s *fakeRoomService) grant(method string) *auth.VideoGrant {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.grants[method]
+}
+
+func (s *fakeRoomService) CreateRoom(_ context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error) {
+	return &livekit.Room{Name: req.Name}, nil
+}
+
+func (s *fakeRoomService) ListRooms(_ context.Context, _ *livekit.ListRoomsRequest) (*livekit.ListRoomsResponse, error) {
+	return &livekit.ListRoomsResponse{Rooms: []*livekit.Room{{Name: "room"}}}, nil
+}
+
+func (s *fakeRoomService) DeleteRoom(_ context.Context, _ *livekit.DeleteRoomRequest) (*livekit.DeleteRoomResponse, error) {
+	return &livekit.DeleteRoomResponse{}, nil
+}
+
+func (s *fakeRoomService) ListParticipants(_ context.Context, _ *livekit.ListParticipantsRequest) (*livekit.ListParticipantsResponse, error) {
+	return &livekit.ListParticipantsResponse{Participants: []*livekit.ParticipantInfo{{Identity: "p1"}}}, nil
+}
+
+func (s *fakeRoomService) GetParticipant(_ context.Context, req *livekit.RoomParticipantIdentity) (*livekit.ParticipantInfo, error) {
+	return &livekit.ParticipantInfo{Identity: req.Identity}, nil
+}
+
+func (s *fakeRoomService) RemoveParticipant(_ context.Context, _ *livekit.RoomParticipantIdentity) (*livekit.RemoveParticipantResponse, error) {
+	return &livekit.RemoveParticipantResponse{}, nil
+}
+
+func (s *fakeRoomService) MutePublishedTrack(_ context.Context, req *livekit.MuteRoomTrackRequest) (*livekit.MuteRoomTrackResponse, error) {
+	return &livekit.MuteRoomTrackResponse{Track: &livekit.TrackInfo{Sid: req.TrackSid, Muted: req.Muted}}, nil
+}
+
+func (s *fakeRoomService) UpdateParticipant(_ context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error) {
+	return &livekit.ParticipantInfo{Identity: req.Identity, Metadata: req.Metadata}, nil
+}
+
+func (s *fakeRoomService) UpdateSubscriptions(_ context.Context, _ *livekit.UpdateSubscriptionsRequest) (*livekit.UpdateSubscriptionsResponse, error) {
+	return &livekit.UpdateSubscriptionsResponse{}, nil
+}
+
+func (s *fakeRoomService) SendData(_ context.Context, _ *livekit.SendDataRequest) (*livekit.SendDataResponse, error) {
+	return &livekit.SendDataResponse{}, nil
+}
+
+func (s *fakeRoomService) UpdateRoomMetadata(_ context.Context, req *livekit.UpdateRoomMetadataRequest) (*livekit.Room, error) {
+	return &livekit.Room{Name: req.Room, Metadata: req.Metadata}, nil
+}
+
+func TestRoomServiceClientGrants(t *testing.T) {
+	client, svc := newTestRoomServiceClient(t)
+	ctx := context.Background()
+
+	room, err := client.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "room"})
+	require.NoError(t, err)
+	require.Equal(t, "room", room.Name)
+	require.True(t, svc.grant("CreateRoom").RoomCreate)
+
+	rooms, err := client.ListRooms(ctx, &livekit.ListRoomsRequest{})
+	require.NoError(t, err)
+	require.Len(t, rooms.Rooms, 1)
+	require.True(t, svc.grant("ListRooms").RoomList)
+
+	_, err = client.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: "room"})
+	require.NoError(t, err)
+	require.True(t, svc.grant("DeleteRoom").RoomCreate)
+
+	participants, err := client.ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: "room"})
+	require.NoError(t, err)
+	require.Len(t, participants.Participants, 1)
+
+	pi, err := client.GetParticipant(ctx, &livekit.RoomParticipantIdentity{Room: "room", Identity: "p1"})
+	require.NoError(t, err)
+	require.Equal(t, "p1", pi.Identity)
+
+	_, err = client.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{Room: "room", Identity: "p1"})
+	require.NoError(t, err)
+
+	muted, err := client.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{Room: "room", Identity: "p1", TrackSid: "TR_1", Muted: true})
+	require.NoError(t, err)
+	require.True(t, muted.Track.Muted)
+
+	pi, err = client.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{Room: "room", Identity: "p1", Metadata: "md"})
+	require.NoError(t, err)
+	require.Equal(t, "md", pi.Metadata)
+
+	_, err = client.UpdateSubscriptions(ctx, &livekit.UpdateSubscriptionsRequest{Room: "room", Identity: "p1"})
+	require.NoError(t, err)
+
+	_, err = client.SendData(ctx, &livekit.SendDataRequest{Room: "room", Data: []byte("hello")})
+	require.NoError(t, err)
+
+	room, err = client.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: "room", Metadata: "md"})
+	require.NoError(t, err)
+	require.Equal(t, "md", room.Metadata)
+
+	for _, method := range []string{
+		"ListParticipants", "GetParticipant", "RemoveParticipant", "MutePublishedTrack",
+		"UpdateParticipant", "UpdateSubscriptions", "SendData", "UpdateRoomMetadata",
+	} {
+		grant := svc.grant(method)
+		require.NotNil(t, grant, method)
+		require.True(t, grant.RoomAdmin, method)
+		require.Equal(t, "room", grant.Room, method)
+	}
+}
+
+func TestRoomServiceClientUnauthorized(t *testing.T) {
+	client, _ := newTestRoomServiceClient(t)
+	client.apiSecret = "wrong"
+
+	_, err := client.ListRooms(context.Background(), &livekit.ListRoomsRequest{})
+	require.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package live_sdk_go
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pion/ice/v2"
+)
+
+// RoomManager joins many rooms from a single process. Instead of opening sockets per peer connection,
+// the rooms share one ICE UDP socket, and their peer connections are created on the same webrtc.API
+type RoomManager struct {
+	opts    []ConnectOption
+	api     *pcAPI
+	udpMux  ice.UDPMux
+	ownsMux bool
+
+	lock   sync.Mutex
+	rooms  map[*Room]struct{}
+	closed bool
+}
+
+// NewRoomManager creates a manager whose rooms are joined with opts. The network and media settings of opts
+// apply to all rooms, the ones given when joining a room are ignored.
+// Without WithUDPMux, the manager listens on the first port of WithUDPPortRange, or a random port
+func NewRoomManager(opts ...ConnectOption) (*RoomManager, error) {
+	params := &ConnectParams{}
+	for _, opt := range opts {
+		opt(params)
+	}
+
+	m := &RoomManager{
+		rooms: make(map[*Room]struct{}),
+	}
+	if params.UDPMux == nil {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: int(params.UDPPortMin)})
+		if err != nil {
+			return nil, err
+		}
+		params.UDPMux = ice.NewUDPMuxDefault(ice.UDPMuxParams{UDPConn: conn})
+		m.ownsMux = true
+	}
+	m.udpMux = params.UDPMux
+
+	var err error
+	if m.api, err = newPCAPI(params); err != nil {
+		if m.ownsMux {
+			_ = m.udpMux.Close()
+		}
+		return nil, err
+	}
+	m.opts = append(append([]ConnectOption{}, opts...), func(p *ConnectParams) {
+		p.sharedAPI = m.api
+	})
+	return m, nil
+}
+
+// UDPMux returns the socket the ICE traffic of all rooms goes through
+func (m *RoomManager) UDPMux() ice.UDPMux {
+	return m.udpMux
+}
+
+// ConnectToRoom creates and joins a room managed by m
+func (m *RoomManager) ConnectToRoom(url string, info ConnectInfo, callback *RoomCallback, opts ...ConnectOption) (*Room, error) {
+	return m.ConnectToRoomContext(context.Background(), url, info, callback, opts...)
+}
+
+// ConnectToRoomWithToken creates and joins a room managed by m
+func (m *RoomManager) ConnectToRoomWithToken(url, token string, callback *RoomCallback, opts ...ConnectOption) (*Room, error) {
+	return m.ConnectToRoomWithTokenContext(context.Background(), url, token, callback, opts...)
+}
+
+func (m *RoomManager) ConnectToRoomContext(ctx context.Context, url string, info ConnectInfo, callback *RoomCallback, opts ...ConnectOption) (*Room, error) {
+	return m.connect(callback, func(room *Room) error {
+		return room.JoinContext(ctx, url, info, m.connectOptions(opts)...)
+	})
+}
+
+func (m *RoomManager) ConnectToRoomWithTokenContext(ctx context.Context, url, token string, callback *RoomCallback, opts ...ConnectOption) (*Room, error) {
+	return m.connect(callback, func(room *Room) error {
+		return room.JoinWithTokenContext(ctx, url, token, m.connectOptions(opts)...)
+	})
+}
+
+// connectOptions returns opts with the ones of the manager applied last, so rooms can't leave the shared api
+func (m *RoomManager) connectOptions(opts []ConnectOption) []ConnectOption {
+	return append(append([]ConnectOption{}, opts...), m.opts...)
+}
+
+func (m *RoomManager) connect(callback *RoomCallback, join func(room *Room) error) (*Room, error) {
+	m.lock.Lock()
+	closed := m.closed
+	m.lock.Unlock()
+	if closed {
+		return nil, ErrRoomManagerClosed
+	}
+
+	room := CreateRoom(callback)
+	if err := join(room); err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		room.Disconnect()
+		return nil, ErrRoomManagerClosed
+	}
+	m.rooms[room] = struct{}{}
+	m.lock.Unlock()
+
+	go func() {
+		<-room.done
+		m.lock.Lock()
+		delete(m.rooms, room)
+		m.lock.Unlock()
+	}()
+	return room, nil
+}
+
+// Rooms returns the rooms that are still connected
+func (m *RoomManager) Rooms() []*Room {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Close disconnects all rooms, and closes the UDP socket unless it was given with WithUDPMux
+func (m *RoomManager) Close() {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return
+	}
+	m.closed = true
+	rooms := make([]*Room, 0, len(m.rooms))
+	for room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	m.rooms = make(map[*Room]struct{})
+	m.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, room := range rooms {
+		wg.Add(1)
+		go func(room *Room) {
+			defer wg.Done()
+			room.Disconnect()
+		}(room)
+	}
+	wg.Wait()
+
+	if m.ownsMux {
+		_ = m.udpMux.Close()
+	}
+}
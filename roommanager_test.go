@@ -0,0 +1,74 @@
+package live_sdk_go
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestRoomManager(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	manager, err := NewRoomManager()
+	require.NoError(t, err)
+	defer manager.Close()
+	port := manager.UDPMux().GetListenAddresses()[0].(*net.UDPAddr).Port
+
+	const numRooms = 20
+	rooms := make([]*Room, numRooms)
+	errs := make([]error, numRooms)
+	var wg sync.WaitGroup
+	for i := 0; i < numRooms; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			identity := fmt.Sprintf("bot-%d", i)
+			rooms[i], errs[i] = manager.ConnectToRoomWithToken(srv.URL(), srv.Token(fmt.Sprintf("room-%d", i), identity), nil)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Len(t, manager.Rooms(), numRooms)
+
+	statsGetters := make(map[interface{}]bool)
+	for i, room := range rooms {
+		require.Equal(t, ConnectionStateConnected, room.ConnectionState())
+		// every transport has its own interceptors on the shared api
+		for _, pc := range []*PCTransport{room.engine.publisher, room.engine.subscriber} {
+			require.NotNil(t, pc.statsGetter)
+			statsGetters[pc.statsGetter] = true
+		}
+
+		sess := srv.Session(fmt.Sprintf("bot-%d", i))
+		require.NotNil(t, sess)
+		for _, c := range trickledCandidates(t, sess) {
+			if c.NetworkType().IsUDP() && c.Type() == ice.CandidateTypeHost {
+				require.Equal(t, port, c.Port())
+			}
+		}
+	}
+	require.Len(t, statsGetters, 2*numRooms)
+
+	rooms[0].Disconnect()
+	require.Eventually(t, func() bool {
+		return len(manager.Rooms()) == numRooms-1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	manager.Close()
+	require.Empty(t, manager.Rooms())
+	for _, room := range rooms {
+		require.Equal(t, ConnectionStateDisconnected, room.ConnectionState())
+	}
+	_, err = manager.ConnectToRoomWithToken(srv.URL(), srv.Token("room-0", "bot-0"), nil)
+	require.ErrorIs(t, err, ErrRoomManagerClosed)
+}
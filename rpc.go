@@ -0,0 +1,303 @@
+package live_sdk_go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/utils"
+)
+
+const (
+	rpcRequestTopic  = "lk.rpc.request"
+	rpcAckTopic      = "lk.rpc.ack"
+	rpcResponseTopic = "lk.rpc.response"
+
+	rpcVersion  = 1
+	rpcIDPrefix = "RPC_"
+
+	// the recipient acks a request as soon as it arrives
+	rpcAckTimeout = 2 * time.Second
+	// used when the context of PerformRPC has no deadline
+	defaultRPCResponseTimeout = 10 * time.Second
+)
+
+// RPC error codes, the same as the LiveKit client SDKs. 14xx reject the request itself,
+// 15xx are failures while it's being handled or waited for
+const (
+	RPCErrUnsupportedMethod  = 1400
+	RPCErrRecipientNotFound  = 1401
+	RPCErrUnsupportedVersion = 1404
+
+	RPCErrApplicationError      = 1500
+	RPCErrConnectionTimeout     = 1501
+	RPCErrResponseTimeout       = 1502
+	RPCErrRecipientDisconnected = 1503
+	RPCErrSendFailed            = 1505
+)
+
+var rpcErrorMessages = map[int]string{
+	RPCErrApplicationError:      "Application error in method handler",
+	RPCErrConnectionTimeout:     "Connection timeout",
+	RPCErrResponseTimeout:       "Response timeout",
+	RPCErrRecipientDisconnected: "Recipient disconnected",
+	RPCErrSendFailed:            "Failed to send",
+	RPCErrUnsupportedMethod:     "Method not supported at destination",
+	RPCErrRecipientNotFound:     "Recipient not found",
+	RPCErrUnsupportedVersion:    "Unsupported RPC version",
+}
+
+// RPCError is returned by PerformRPC when the call fails. Handlers can return one to send
+// a custom code and data back to the caller, other errors are reported as RPCErrApplicationError
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+func NewRPCError(code int, message string, data string) *RPCError {
+	return &RPCError{Code: code, Message: message, Data: data}
+}
+
+func newBuiltInRPCError(code int) *RPCError {
+	return &RPCError{Code: code, Message: rpcErrorMessages[code]}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// RPCInvocationData is passed to the handler of a method
+type RPCInvocationData struct {
+	RequestID       string
+	CallerIdentity  string
+	Payload         string
+	ResponseTimeout time.Duration
+}
+
+// RPCHandler returns the response payload of a call, it must respond within data.ResponseTimeout
+type RPCHandler func(data RPCInvocationData) (string, error)
+
+type rpcRequest struct {
+	ID                string `json:"id"`
+	Method            string `json:"method"`
+	Payload           string `json:"payload"`
+	ResponseTimeoutMs int64  `json:"responseTimeoutMs"`
+	Version           int    `json:"version"`
+}
+
+type rpcAck struct {
+	RequestID string `json:"requestId"`
+}
+
+type rpcResponse struct {
+	RequestID string    `json:"requestId"`
+	Payload   string    `json:"payload,omitempty"`
+	Error     *RPCError `json:"error,omitempty"`
+}
+
+type pendingRPC struct {
+	participantSid string
+	ack            chan struct{}
+	response       chan *rpcResponse
+	disconnected   chan struct{}
+}
+
+type rpcState struct {
+	lock     sync.Mutex
+	handlers map[string]RPCHandler
+	pending  map[string]*pendingRPC
+}
+
+// RegisterRPCMethod sets the handler of a method that other participants can call with PerformRPC
+func (p *LocalParticipant) RegisterRPCMethod(method string, handler RPCHandler) {
+	p.rpc.lock.Lock()
+	defer p.rpc.lock.Unlock()
+	if p.rpc.handlers == nil {
+		p.rpc.handlers = make(map[string]RPCHandler)
+	}
+	p.rpc.handlers[method] = handler
+}
+
+func (p *LocalParticipant) UnregisterRPCMethod(method string) {
+	p.rpc.lock.Lock()
+	defer p.rpc.lock.Unlock()
+	delete(p.rpc.handlers, method)
+}
+
+// PerformRPC calls a method registered by the participant with the given identity, and returns its response.
+// The call times out when ctx is done, or after 10 seconds if ctx has no deadline. Failures are returned as *RPCError
+func (p *LocalParticipant) PerformRPC(ctx context.Context, identity string, method string, payload string) (string, error) {
+	rp := p.getParticipantByIdentity(identity)
+	if rp == nil {
+		return "", newBuiltInRPCError(RPCErrRecipientNotFound)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRPCResponseTimeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+
+	req := &rpcRequest{
+		ID:                utils.NewGuid(rpcIDPrefix),
+		Method:            method,
+		Payload:           payload,
+		ResponseTimeoutMs: time.Until(deadline).Milliseconds(),
+		Version:           rpcVersion,
+	}
+	call := &pendingRPC{
+		participantSid: rp.SID(),
+		ack:            make(chan struct{}, 1),
+		response:       make(chan *rpcResponse, 1),
+		disconnected:   make(chan struct{}),
+	}
+	p.rpc.lock.Lock()
+	if p.rpc.pending == nil {
+		p.rpc.pending = make(map[string]*pendingRPC)
+	}
+	p.rpc.pending[req.ID] = call
+	p.rpc.lock.Unlock()
+	defer func() {
+		p.rpc.lock.Lock()
+		delete(p.rpc.pending, req.ID)
+		p.rpc.lock.Unlock()
+	}()
+
+	if err := p.sendRPCMessage(rpcRequestTopic, req, rp.SID()); err != nil {
+		logger.Errorw("could not send rpc request", err, "method", method, "identity", identity)
+		return "", newBuiltInRPCError(RPCErrSendFailed)
+	}
+
+	ackTimer := time.NewTimer(rpcAckTimeout)
+	defer ackTimer.Stop()
+	ackTimeout := ackTimer.C
+	for {
+		select {
+		case <-call.ack:
+			ackTimeout = nil
+		case res := <-call.response:
+			if res.Error != nil {
+				return "", res.Error
+			}
+			return res.Payload, nil
+		case <-ackTimeout:
+			return "", newBuiltInRPCError(RPCErrConnectionTimeout)
+		case <-call.disconnected:
+			return "", newBuiltInRPCError(RPCErrRecipientDisconnected)
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", newBuiltInRPCError(RPCErrResponseTimeout)
+			}
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (p *LocalParticipant) sendRPCMessage(topic string, msg interface{}, destinationSid string) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return p.publishDataChunks(data, livekit.DataPacket_RELIABLE, topic, []string{destinationSid})
+}
+
+// handleRPCPacket handles the internal rpc topics, returns false for any other topic
+func (p *LocalParticipant) handleRPCPacket(topic string, payload []byte, sender *RemoteParticipant) bool {
+	switch topic {
+	case rpcRequestTopic:
+		req := &rpcRequest{}
+		if err := json.Unmarshal(payload, req); err != nil {
+			logger.Debugw("invalid rpc request", "error", err, "participant", sender.Identity())
+			return true
+		}
+		go p.handleRPCRequest(req, sender)
+	case rpcAckTopic:
+		ack := &rpcAck{}
+		if err := json.Unmarshal(payload, ack); err != nil {
+			return true
+		}
+		if call := p.getPendingRPC(ack.RequestID); call != nil {
+			select {
+			case call.ack <- struct{}{}:
+			default:
+			}
+		}
+	case rpcResponseTopic:
+		res := &rpcResponse{}
+		if err := json.Unmarshal(payload, res); err != nil {
+			return true
+		}
+		if call := p.getPendingRPC(res.RequestID); call != nil {
+			select {
+			case call.response <- res:
+			default:
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *LocalParticipant) getPendingRPC(requestID string) *pendingRPC {
+	p.rpc.lock.Lock()
+	defer p.rpc.lock.Unlock()
+	return p.rpc.pending[requestID]
+}
+
+func (p *LocalParticipant) handleRPCRequest(req *rpcRequest, caller *RemoteParticipant) {
+	if err := p.sendRPCMessage(rpcAckTopic, &rpcAck{RequestID: req.ID}, caller.SID()); err != nil {
+		logger.Errorw("could not send rpc ack", err, "method", req.Method)
+		return
+	}
+
+	res := &rpcResponse{RequestID: req.ID}
+	p.rpc.lock.Lock()
+	handler := p.rpc.handlers[req.Method]
+	p.rpc.lock.Unlock()
+
+	switch {
+	case req.Version != rpcVersion:
+		res.Error = newBuiltInRPCError(RPCErrUnsupportedVersion)
+	case handler == nil:
+		res.Error = newBuiltInRPCError(RPCErrUnsupportedMethod)
+	default:
+		payload, err := handler(RPCInvocationData{
+			RequestID:       req.ID,
+			CallerIdentity:  caller.Identity(),
+			Payload:         req.Payload,
+			ResponseTimeout: time.Duration(req.ResponseTimeoutMs) * time.Millisecond,
+		})
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			res.Error = rpcErr
+		} else if err != nil {
+			logger.Warnw("rpc handler failed", err, "method", req.Method)
+			res.Error = newBuiltInRPCError(RPCErrApplicationError)
+		} else {
+			res.Payload = payload
+		}
+	}
+
+	if err := p.sendRPCMessage(rpcResponseTopic, res, caller.SID()); err != nil {
+		logger.Errorw("could not send rpc response", err, "method", req.Method)
+	}
+}
+
+// handleParticipantDisconnected fails the calls pending on a participant that left
+func (p *LocalParticipant) handleParticipantDisconnected(sid string) {
+	p.rpc.lock.Lock()
+	defer p.rpc.lock.Unlock()
+	for id, call := range p.rpc.pending {
+		if call.participantSid == sid {
+			close(call.disconnected)
+			delete(p.rpc.pending, id)
+		}
+	}
+}
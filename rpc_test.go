@@ -0,0 +1,81 @@
+package live_sdk_go
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestPerformRPC(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	alice, bob := connectRelayedRooms(t, srv, nil, nil)
+
+	bob.LocalParticipant.RegisterRPCMethod("greet", func(data RPCInvocationData) (string, error) {
+		if data.ResponseTimeout <= 0 {
+			return "", errors.New("no response timeout")
+		}
+		return "hello " + data.Payload + " from " + data.CallerIdentity, nil
+	})
+	bob.LocalParticipant.RegisterRPCMethod("fail", func(data RPCInvocationData) (string, error) {
+		return "", NewRPCError(42, "custom", "details")
+	})
+	bob.LocalParticipant.RegisterRPCMethod("crash", func(data RPCInvocationData) (string, error) {
+		return "", errors.New("boom")
+	})
+	bob.LocalParticipant.RegisterRPCMethod("slow", func(data RPCInvocationData) (string, error) {
+		time.Sleep(time.Second)
+		return "", nil
+	})
+	bob.LocalParticipant.RegisterRPCMethod("echo", func(data RPCInvocationData) (string, error) {
+		return data.Payload, nil
+	})
+
+	ctx := context.Background()
+	res, err := alice.LocalParticipant.PerformRPC(ctx, "bob", "greet", "bob")
+	require.NoError(t, err)
+	require.Equal(t, "hello bob from alice", res)
+
+	// large payloads are chunked both ways
+	large := strings.Repeat("x", 3*maxDataPayloadSize)
+	res, err = alice.LocalParticipant.PerformRPC(ctx, "bob", "echo", large)
+	require.NoError(t, err)
+	require.Equal(t, large, res)
+
+	requireRPCError := func(err error, code int) *RPCError {
+		var rpcErr *RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		require.Equal(t, code, rpcErr.Code)
+		return rpcErr
+	}
+
+	_, err = alice.LocalParticipant.PerformRPC(ctx, "bob", "fail", "")
+	rpcErr := requireRPCError(err, 42)
+	require.Equal(t, "custom", rpcErr.Message)
+	require.Equal(t, "details", rpcErr.Data)
+
+	_, err = alice.LocalParticipant.PerformRPC(ctx, "bob", "crash", "")
+	requireRPCError(err, RPCErrApplicationError)
+
+	_, err = alice.LocalParticipant.PerformRPC(ctx, "bob", "unknown", "")
+	requireRPCError(err, RPCErrUnsupportedMethod)
+
+	_, err = alice.LocalParticipant.PerformRPC(ctx, "carol", "greet", "")
+	requireRPCError(err, RPCErrRecipientNotFound)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	_, err = alice.LocalParticipant.PerformRPC(timeoutCtx, "bob", "slow", "")
+	requireRPCError(err, RPCErrResponseTimeout)
+
+	bob.LocalParticipant.UnregisterRPCMethod("greet")
+	_, err = alice.LocalParticipant.PerformRPC(ctx, "bob", "greet", "")
+	requireRPCError(err, RPCErrUnsupportedMethod)
+}
@@ -17,6 +17,20 @@ type AudioSampleProvider interface {
 	CurrentAudioLevel() uint8
 }
 
+// VoiceActivitySampleProvider also tells whether its audio holds voice, sent along with the audio level
+type VoiceActivitySampleProvider interface {
+	AudioSampleProvider
+	CurrentVoiceActivity() bool
+}
+
+// PausableSampleProvider is notified when the layer it provides is no longer subscribed to, e.g. to stop encoding.
+// A NextSample call already in flight may still complete after OnPause, no other is made until OnResume
+type PausableSampleProvider interface {
+	SampleProvider
+	OnPause()
+	OnResume()
+}
+
 // BaseSampleProvider provides empty implementations for OnBind and OnUnbind
 type BaseSampleProvider struct {
 }
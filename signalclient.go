@@ -1,6 +1,7 @@
 package live_sdk_go
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -10,12 +11,15 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/atomic"
 
 	"github.com/livekit/protocol/livekit"
+
+	"github.com/liuhailove/live-sdk-go/pkg/metrics"
 )
 
 const PROTOCOL = 8
@@ -27,23 +31,32 @@ type SignalClient struct {
 	isStarted       atomic.Bool
 	pendingResponse *livekit.SignalResponse
 
-	OnClose                 func()
-	OnAnswer                func(sd webrtc.SessionDescription)
-	OnOffer                 func(sd webrtc.SessionDescription)
-	OnTrickle               func(init webrtc.ICECandidateInit, target livekit.SignalTarget)
-	OnParticipantUpdate     func([]*livekit.ParticipantInfo)
-	OnLocalTrackPublished   func(response *livekit.TrackPublishedResponse)
-	OnSpeakersChanged       func([]*livekit.SpeakerInfo)
-	OnConnectionQuality     func([]*livekit.ConnectionQualityInfo)
-	OnRoomUpdate            func(room *livekit.Room)
-	OnTrackMuted            func(request *livekit.MuteTrackRequest)
-	OnLocalTrackUnpublished func(response *livekit.TrackUnpublishedResponse)
-	OnTokenRefresh          func(refreshToken string)
-	OnLeave                 func(request *livekit.LeaveRequest)
+	// keepalive, configured by the join response
+	pingInterval atomic.Duration
+	pingTimeout  atomic.Duration
+	pong         chan struct{}
+	rtt          atomic.Duration
+
+	OnClose                   func()
+	OnAnswer                  func(sd webrtc.SessionDescription)
+	OnOffer                   func(sd webrtc.SessionDescription)
+	OnTrickle                 func(init webrtc.ICECandidateInit, target livekit.SignalTarget)
+	OnParticipantUpdate       func([]*livekit.ParticipantInfo)
+	OnLocalTrackPublished     func(response *livekit.TrackPublishedResponse)
+	OnSpeakersChanged         func([]*livekit.SpeakerInfo)
+	OnConnectionQuality       func([]*livekit.ConnectionQualityInfo)
+	OnRoomUpdate              func(room *livekit.Room)
+	OnTrackMuted              func(request *livekit.MuteTrackRequest)
+	OnLocalTrackUnpublished   func(response *livekit.TrackUnpublishedResponse)
+	OnTokenRefresh            func(refreshToken string)
+	OnLeave                   func(request *livekit.LeaveRequest)
+	OnSubscribedQualityUpdate func(update *livekit.SubscribedQualityUpdate)
 }
 
 func NewSignalClient() *SignalClient {
-	c := &SignalClient{}
+	c := &SignalClient{
+		pong: make(chan struct{}, 1),
+	}
 	return c
 }
 
@@ -51,7 +64,11 @@ func (c *SignalClient) Start() {
 	if c.isStarted.Swap(true) {
 		return
 	}
-	go c.readWorker()
+	done := make(chan struct{})
+	go c.readWorker(done)
+	if c.pingInterval.Load() > 0 {
+		go c.pingWorker(done)
+	}
 }
 
 func (c *SignalClient) IsStarted() bool {
@@ -59,6 +76,12 @@ func (c *SignalClient) IsStarted() bool {
 }
 
 func (c *SignalClient) Join(urlPrefix string, token string, params *ConnectParams) (*livekit.JoinResponse, error) {
+	return c.JoinContext(context.Background(), urlPrefix, token, params)
+}
+
+// JoinContext establishes the signal connection, dialing and waiting for the join response
+// until ctx is done
+func (c *SignalClient) JoinContext(ctx context.Context, urlPrefix string, token string, params *ConnectParams) (*livekit.JoinResponse, error) {
 	if urlPrefix == "" {
 		return nil, ErrURLNotProvided
 	}
@@ -80,50 +103,47 @@ func (c *SignalClient) Join(urlPrefix string, token string, params *ConnectParam
 		return nil, err
 	}
 
+	dialer, err := params.websocketDialer()
+	if err != nil {
+		return nil, err
+	}
 	header := newHeaderWithToken(token)
-	conn, hresp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	conn, hresp, err := dialer.DialContext(ctx, u.String(), header)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		logger.Errorw("error establishing signal connect", err, "httpResponse", hresp)
 		// use validate endpoint to get the actual error
 		validateSuffix := strings.Replace(urlSuffix, "/rtc", "/rtc/validate", 1)
-
-		validateReq, err1 := http.NewRequest(http.MethodGet, ToHttpURL(urlPrefix)+validateSuffix, nil)
-		if err != nil {
-			logger.Errorw("error creating validate request", err1)
-			return nil, ErrCannotDialSignal
-		}
-		validateReq.Header = header
-		hresp, err := http.DefaultClient.Do(validateReq)
-		if err != nil {
-			logger.Errorw("error getting validation", err, "httpResponse", hresp)
-			return nil, ErrCannotDialSignal
-		} else if hresp.StatusCode == http.StatusOK {
-			// no specific errors to return if validate succeeds
-			logger.Infow("validate succeeded")
-			return nil, ErrCannotConnectSignal
-		} else {
-			var errString string
-			switch hresp.StatusCode {
-			case http.StatusUnauthorized:
-				errString = "unauthorized: "
-			case http.StatusNotFound:
-				errString = "not found: "
-			case http.StatusServiceUnavailable:
-				errString = "unavailable"
-			}
-			body, err := io.ReadAll(hresp.Body)
-			if err == nil {
-				errString += string(body)
-			}
-			return nil, errors.New(errString)
-		}
+		return nil, c.validate(ctx, params, ToHttpURL(urlPrefix)+validateSuffix, header)
 	}
 	c.isClosed.Swap(false)
 	c.conn.Store(conn)
 
-	// server should send join as soon as connected
+	// server should send join as soon as connected, unblock the read if ctx finishes first.
+	// The watcher is done before returning, so a ctx canceled later doesn't close the connection
+	readDone, stopped := make(chan struct{}), make(chan struct{})
+	var canceled bool
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			canceled = true
+			_ = conn.Close()
+		case <-readDone:
+		}
+	}()
 	res, err := c.readResponse()
+	close(readDone)
+	<-stopped
+	if err == nil && canceled {
+		err = ctx.Err()
+	}
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, err
 	}
 
@@ -144,9 +164,56 @@ func (c *SignalClient) Join(urlPrefix string, token string, params *ConnectParam
 		return nil, fmt.Errorf("unexpected response: %v", res.Message)
 	}
 
+	c.pingInterval.Store(time.Duration(join.PingInterval) * time.Second)
+	c.pingTimeout.Store(time.Duration(join.PingTimeout) * time.Second)
 	return join, nil
 }
 
+// validate queries the validate endpoint after a failed dial to find out why the server rejected the connection
+func (c *SignalClient) validate(ctx context.Context, params *ConnectParams, validateURL string, header http.Header) error {
+	client, err := params.httpClient()
+	if err != nil {
+		return err
+	}
+	validateReq, err := http.NewRequestWithContext(ctx, http.MethodGet, validateURL, nil)
+	if err != nil {
+		logger.Errorw("error creating validate request", err)
+		return ErrCannotDialSignal
+	}
+	validateReq.Header = header
+	hresp, err := client.Do(validateReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		logger.Errorw("error getting validation", err, "httpResponse", hresp)
+		return ErrCannotDialSignal
+	}
+	defer hresp.Body.Close()
+
+	if hresp.StatusCode == http.StatusOK {
+		// no specific errors to return if validate succeeds
+		logger.Infow("validate succeeded")
+		return ErrCannotConnectSignal
+	}
+
+	joinErr := &JoinError{StatusCode: hresp.StatusCode}
+	switch hresp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		joinErr.Err = ErrUnauthorized
+	case http.StatusNotFound:
+		joinErr.Err = ErrRoomNotFound
+	case http.StatusServiceUnavailable:
+		joinErr.Err = ErrServerUnavailable
+	default:
+		joinErr.Err = ErrCannotConnectSignal
+	}
+	if body, err := io.ReadAll(hresp.Body); err == nil {
+		joinErr.Message = strings.TrimSpace(string(body))
+	}
+	return joinErr
+}
+
 func (c *SignalClient) Close() {
 	if c.isClosed.Swap(true) {
 		return
@@ -199,10 +266,37 @@ func (c *SignalClient) SendSyncState(state *livekit.SyncState) error {
 func (c *SignalClient) SendLeave() error {
 	return c.SendRequest(&livekit.SignalRequest{
 		Message: &livekit.SignalRequest_Leave{
-			Leave: &livekit.LeaveRequest{},
+			Leave: &livekit.LeaveRequest{
+				Reason: livekit.DisconnectReason_CLIENT_INITIATED,
+			},
+		},
+	})
+}
+func (c *SignalClient) SendPing() error {
+	now := time.Now().UnixMilli()
+	// older servers only answer the legacy ping
+	if err := c.SendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Ping{
+			Ping: now,
+		},
+	}); err != nil {
+		return err
+	}
+	return c.SendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_PingReq{
+			PingReq: &livekit.Ping{
+				Timestamp: now,
+				Rtt:       c.rtt.Load().Milliseconds(),
+			},
 		},
 	})
 }
+
+// RTT returns the round trip time of the last ping on the signal connection
+func (c *SignalClient) RTT() time.Duration {
+	return c.rtt.Load()
+}
+
 func (c *SignalClient) SendRequest(req *livekit.SignalRequest) error {
 	conn := c.websocketConn()
 	if conn == nil {
@@ -263,6 +357,7 @@ func (c *SignalClient) readResponse() (*livekit.SignalResponse, error) {
 	}
 }
 func (c *SignalClient) handleResponse(res *livekit.SignalResponse) {
+	metrics.RecordSignalMessage(signalMessageType(res))
 	switch msg := res.Message.(type) {
 	case *livekit.SignalResponse_Answer:
 		if c.OnAnswer != nil {
@@ -312,12 +407,46 @@ func (c *SignalClient) handleResponse(res *livekit.SignalResponse) {
 		if c.OnLocalTrackUnpublished != nil {
 			c.OnLocalTrackUnpublished(msg.TrackUnpublished)
 		}
+	case *livekit.SignalResponse_SubscribedQualityUpdate:
+		if c.OnSubscribedQualityUpdate != nil {
+			c.OnSubscribedQualityUpdate(msg.SubscribedQualityUpdate)
+		}
+	case *livekit.SignalResponse_Pong:
+		// the legacy pong carries the clock of the server, not the ping timestamp
+		c.handlePong()
+	case *livekit.SignalResponse_PongResp:
+		c.handlePongResp(msg.PongResp)
+	}
+}
 
+// signalMessageType returns the name of the message set on res, e.g. "speakers_changed"
+func signalMessageType(res *livekit.SignalResponse) string {
+	m := res.ProtoReflect()
+	if field := m.WhichOneof(m.Descriptor().Oneofs().ByName("message")); field != nil {
+		return string(field.Name())
 	}
+	return "unknown"
 }
 
-func (c *SignalClient) readWorker() {
+func (c *SignalClient) handlePongResp(pong *livekit.Pong) {
+	if rtt := time.Now().UnixMilli() - pong.LastPingTimestamp; rtt >= 0 {
+		c.rtt.Store(time.Duration(rtt) * time.Millisecond)
+		metrics.RecordSignalRTT(c.rtt.Load())
+	}
+	c.handlePong()
+}
+
+// handlePong wakes up the ping worker, which resets the timeout
+func (c *SignalClient) handlePong() {
+	select {
+	case c.pong <- struct{}{}:
+	default:
+	}
+}
+
+func (c *SignalClient) readWorker(done chan struct{}) {
 	defer func() {
+		close(done)
 		c.isStarted.Store(false)
 		if c.OnClose != nil {
 			c.OnClose()
@@ -341,6 +470,47 @@ func (c *SignalClient) readWorker() {
 
 }
 
+// pingWorker pings the server until the read worker exits, closing the connection when it stops answering.
+// A half-open connection would otherwise block the reads until WebRTC fails as well
+func (c *SignalClient) pingWorker(done chan struct{}) {
+	interval, timeout := c.pingInterval.Load(), c.pingTimeout.Load()
+	if timeout <= 0 {
+		timeout = 2 * interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timeoutTimer := time.NewTimer(timeout)
+	defer timeoutTimer.Stop()
+
+	// drop a pong left over from the previous connection
+	select {
+	case <-c.pong:
+	default:
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.SendPing(); err != nil {
+				logger.Debugw("could not send ping", "error", err)
+			}
+		case <-c.pong:
+			if !timeoutTimer.Stop() {
+				<-timeoutTimer.C
+			}
+			timeoutTimer.Reset(timeout)
+		case <-timeoutTimer.C:
+			logger.Warnw("signal connection timed out", ErrSignalTimeout, "timeout", timeout)
+			// unblocks the read worker, which reports the connection as closed
+			if conn := c.websocketConn(); conn != nil {
+				_ = conn.Close()
+			}
+			return
+		}
+	}
+}
+
 func (c *SignalClient) websocketConn() *websocket.Conn {
 	obj := c.conn.Load()
 	if obj == nil {
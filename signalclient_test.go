@@ -0,0 +1,170 @@
+package live_sdk_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestSignalClientJoinErrors(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusNotFound, ErrRoomNotFound},
+		{http.StatusServiceUnavailable, ErrServerUnavailable},
+		{http.StatusBadRequest, ErrCannotConnectSignal},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			_, _ = w.Write([]byte("rejected"))
+		}))
+
+		c := NewSignalClient()
+		_, err := c.Join(server.URL, "token", &ConnectParams{})
+		server.Close()
+
+		require.ErrorIs(t, err, tc.expected)
+		var joinErr *JoinError
+		require.True(t, errors.As(err, &joinErr))
+		require.Equal(t, tc.status, joinErr.StatusCode)
+		require.Equal(t, "rejected", joinErr.Message)
+	}
+}
+
+func TestSignalClientJoinContextCancel(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	connected := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		close(connected)
+		// never send a join response
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-connected
+		cancel()
+	}()
+
+	c := NewSignalClient()
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := c.JoinContext(ctx, server.URL, "token", &ConnectParams{})
+		errChan <- err
+	}()
+
+	select {
+	case err := <-errChan:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("join did not return after context was cancelled")
+	}
+}
+
+func TestSignalClientJoinContextCancelAfterJoin(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	// canceling right after joining races with the end of the join, repeat to catch it
+	for i := 0; i < 10; i++ {
+		identity := fmt.Sprintf("bot-%d", i)
+		ctx, cancel := context.WithCancel(context.Background())
+		c := NewSignalClient()
+		_, err := c.JoinContext(ctx, srv.URL(), srv.Token("test-room", identity), &ConnectParams{})
+		cancel()
+		require.NoError(t, err)
+
+		// the connection stays up
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, c.SendPing())
+		sess := srv.Session(identity)
+		require.NotNil(t, sess)
+		require.Eventually(t, func() bool {
+			for _, req := range sess.Requests() {
+				if req.GetPingReq() != nil {
+					return true
+				}
+			}
+			return false
+		}, 5*time.Second, 10*time.Millisecond)
+		c.Close()
+	}
+}
+
+func TestSignalClientPingTimeout(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+	srv.OnJoin = func(req *testserver.JoinRequest, res *livekit.JoinResponse) {
+		res.PingInterval = 1
+		res.PingTimeout = 2
+	}
+
+	reconnecting := make(chan struct{}, 1)
+	reconnected := make(chan struct{}, 1)
+	room, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnReconnecting: func() {
+			reconnecting <- struct{}{}
+		},
+		OnReconnected: func() {
+			reconnected <- struct{}{}
+		},
+	})
+
+	require.Eventually(t, func() bool {
+		for _, req := range sess.Requests() {
+			if req.GetPingReq() != nil {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the connection stays open but the server stops answering
+	sess.IgnorePings(true)
+	select {
+	case <-reconnecting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ping timeout not detected")
+	}
+	sess.IgnorePings(false)
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("did not reconnect")
+	}
+	require.Equal(t, ConnectionStateConnected, room.ConnectionState())
+}
+
+func TestSignalClientRTT(t *testing.T) {
+	c := NewSignalClient()
+	c.handlePongResp(&livekit.Pong{LastPingTimestamp: time.Now().Add(-50 * time.Millisecond).UnixMilli()})
+	require.InDelta(t, 50*time.Millisecond, c.RTT(), float64(20*time.Millisecond))
+	// the pong wakes up the ping worker
+	require.Len(t, c.pong, 1)
+	<-c.pong
+
+	// the legacy pong holds the clock of the server, it only resets the timeout
+	c.handleResponse(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Pong{Pong: time.Now().Add(-time.Hour).UnixMilli()},
+	})
+	require.InDelta(t, 50*time.Millisecond, c.RTT(), float64(20*time.Millisecond))
+	require.Len(t, c.pong, 1)
+}
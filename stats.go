@@ -0,0 +1,107 @@
+package live_sdk_go
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// TrackStats is a snapshot of the RTP statistics of a single stream of a track,
+// rates are averaged over the time since the previous snapshot of the same stream taken by the same caller:
+// the GetStats methods on one side, the periodic OnStats of WithStatsInterval on the other
+type TrackStats struct {
+	TrackSID string
+	Kind     TrackKind
+	SSRC     uint32
+	// RID of the simulcast layer, empty for single layer tracks
+	RID       string
+	Timestamp time.Time
+
+	// local tracks
+	PacketsSent uint64
+	BytesSent   uint64
+
+	// remote tracks
+	PacketsReceived uint64
+	BytesReceived   uint64
+
+	// for local tracks as reported by the receiver
+	PacketsLost int64
+	Jitter      time.Duration
+
+	// NACK/PLI/FIR sent for remote tracks, received for local tracks
+	NACKCount uint32
+	PLICount  uint32
+	FIRCount  uint32
+
+	RoundTripTime time.Duration
+	// bits per second
+	Bitrate uint64
+	// video only
+	FramesPerSecond float64
+}
+
+// TransportStats is a snapshot of the statistics of a peer connection
+type TransportStats struct {
+	Timestamp     time.Time
+	BytesSent     uint64
+	BytesReceived uint64
+	// bits per second
+	SendBitrate    uint64
+	ReceiveBitrate uint64
+	RoundTripTime  time.Duration
+	// nil until ICE is connected
+	SelectedCandidatePair *webrtc.ICECandidatePair
+}
+
+// RoomStats contains the stats of both peer connections and all published and subscribed tracks
+type RoomStats struct {
+	Timestamp    time.Time
+	Publisher    *TransportStats
+	Subscriber   *TransportStats
+	LocalTracks  []*TrackStats
+	RemoteTracks []*TrackStats
+}
+
+// statsCaller tells apart who takes snapshots, so the periodic stats and GetStats don't reset each other's rates
+type statsCaller int
+
+const (
+	statsCallerAPI statsCaller = iota
+	statsCallerWorker
+	numStatsCallers
+)
+
+// statsSample keeps cumulative counters of the previous snapshot to compute rates
+type statsSample struct {
+	at     time.Time
+	bytes  uint64
+	frames uint64
+}
+
+// statsBaseline keeps the previous snapshots of a caller
+type statsBaseline struct {
+	sent     statsSample
+	received statsSample
+	streams  map[uint32]statsSample
+}
+
+func bitrate(prev, cur statsSample) uint64 {
+	elapsed := cur.at.Sub(prev.at).Seconds()
+	if prev.at.IsZero() || elapsed <= 0 || cur.bytes < prev.bytes {
+		return 0
+	}
+	return uint64(float64(cur.bytes-prev.bytes) * 8 / elapsed)
+}
+
+func frameRate(prev, cur statsSample) float64 {
+	elapsed := cur.at.Sub(prev.at).Seconds()
+	if prev.at.IsZero() || elapsed <= 0 || cur.frames < prev.frames {
+		return 0
+	}
+	return float64(cur.frames-prev.frames) / elapsed
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
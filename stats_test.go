@@ -0,0 +1,112 @@
+package live_sdk_go
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+func TestRoomStats(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	statsChan := make(chan *RoomStats, 10)
+	room, sess := connectTestRoom(t, srv, "bot", &RoomCallback{
+		OnStats: func(stats *RoomStats) {
+			select {
+			case statsChan <- stats:
+			default:
+			}
+		},
+		ParticipantCallback: ParticipantCallback{
+			OnTrackSubscribed: func(track *webrtc.TrackRemote, pub *RemoteTrackPublication, rp *RemoteParticipant) {
+				// media only passes the interceptors when it's read
+				go func() {
+					buf := make([]byte, 1500)
+					for {
+						if _, _, err := track.Read(buf); err != nil {
+							return
+						}
+					}
+				}()
+			},
+		},
+	}, WithStatsInterval(200*time.Millisecond))
+
+	// send audio
+	local, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	require.NoError(t, err)
+	require.NoError(t, local.StartWrite(NewNullSampleProvider(16000), nil))
+	localPub, err := room.LocalParticipant.PublishTrack(local, &TrackPublicationOptions{Name: "mic"})
+	require.NoError(t, err)
+
+	// receive video
+	pi, err := sess.AddParticipant("alice")
+	require.NoError(t, err)
+	remote, ti, err := sess.PublishTrack(pi.Sid, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "camera")
+	require.NoError(t, err)
+	done := make(chan struct{})
+	defer close(done)
+	go writeTestSamples(remote, done)
+
+	var stats *RoomStats
+	require.Eventually(t, func() bool {
+		select {
+		case stats = <-statsChan:
+		default:
+			return false
+		}
+		return len(stats.LocalTracks) == 1 && stats.LocalTracks[0].PacketsSent > 0 &&
+			len(stats.RemoteTracks) == 1 && stats.RemoteTracks[0].FramesPerSecond > 0
+	}, 10*time.Second, 50*time.Millisecond)
+
+	localStats := stats.LocalTracks[0]
+	require.Equal(t, localPub.SID(), localStats.TrackSID)
+	require.Equal(t, TrackKindAudio, localStats.Kind)
+	require.NotZero(t, localStats.BytesSent)
+
+	remoteStats := stats.RemoteTracks[0]
+	require.Equal(t, ti.Sid, remoteStats.TrackSID)
+	require.Equal(t, TrackKindVideo, remoteStats.Kind)
+	require.NotZero(t, remoteStats.PacketsReceived)
+	require.NotZero(t, remoteStats.Bitrate)
+
+	require.NotNil(t, stats.Publisher)
+	require.NotNil(t, stats.Publisher.SelectedCandidatePair)
+	require.NotZero(t, stats.Publisher.BytesSent)
+	require.NotNil(t, stats.Subscriber)
+	require.NotZero(t, stats.Subscriber.BytesReceived)
+
+	// GetStats keeps its own baselines, not resetting the rates of the periodic stats
+	ssrc := localStats.SSRC
+	publisher := room.engine.publisher
+	require.Len(t, room.GetStats().LocalTracks, 1)
+	publisher.statsLock.Lock()
+	apiSample := publisher.statsBaselines[statsCallerAPI].streams[ssrc]
+	workerSample := publisher.statsBaselines[statsCallerWorker].streams[ssrc]
+	publisher.statsLock.Unlock()
+	require.False(t, apiSample.at.IsZero())
+	require.False(t, workerSample.at.IsZero())
+	require.NotEqual(t, apiSample.at, workerSample.at)
+
+	// and both are dropped with the track
+	require.NoError(t, room.LocalParticipant.UnpublishTrack(localPub.SID()))
+	publisher.statsLock.Lock()
+	for _, baseline := range publisher.statsBaselines {
+		require.NotContains(t, baseline.streams, ssrc)
+	}
+	publisher.statsLock.Unlock()
+
+	// stats stop with the room
+	room.Disconnect()
+	time.Sleep(300 * time.Millisecond)
+	for len(statsChan) > 0 {
+		<-statsChan
+	}
+	time.Sleep(300 * time.Millisecond)
+	require.Empty(t, statsChan)
+}
@@ -6,11 +6,9 @@ import (
 	"time"
 
 	"github.com/bep/debounce"
-	"github.com/pion/dtls/v2"
-	"github.com/pion/interceptor"
-	"github.com/pion/interceptor/pkg/nack"
-	"github.com/pion/sdp/v3"
+	"github.com/pion/interceptor/pkg/stats"
 	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
 
 	sdkinterceptor "github.com/liuhailove/live-sdk-go/pkg/interceptor"
 	lksdp "github.com/livekit/protocol/sdp"
@@ -18,6 +16,11 @@ import (
 
 const (
 	negotiationFrequency = 150 * time.Millisecond
+
+	// pion's defaults, kept for the zero fields of ICETimeouts
+	defaultICEDisconnectedTimeout = 5 * time.Second
+	defaultICEFailedTimeout       = 25 * time.Second
+	defaultICEKeepAliveInterval   = 2 * time.Second
 )
 
 // PCTransport is a wrapper around PeerConnection, with some helper methods
@@ -32,6 +35,13 @@ type PCTransport struct {
 	pendingRestartIceOffer    *webrtc.SessionDescription
 	restartAfterGathering     bool
 	nackGenerator             *sdkinterceptor.NackGeneratorInterceptorFactory
+	statsGetter               stats.Getter
+	frameCounter              *sdkinterceptor.FrameCounterInterceptorFactory
+	audioLevels               *sdkinterceptor.AudioLevelInterceptorFactory
+	rtt                       atomic.Uint32
+
+	statsLock      sync.Mutex
+	statsBaselines [numStatsCallers]statsBaseline
 
 	onRemoteDescriptionSettled func() error
 
@@ -39,52 +49,73 @@ type PCTransport struct {
 }
 
 func NewPCTransport(configuration webrtc.Configuration) (*PCTransport, error) {
-	m := &webrtc.MediaEngine{}
-	if err := m.RegisterDefaultCodecs(); err != nil {
-		return nil, err
+	return newPCTransport(configuration, nil, false)
+}
+
+// settingEngine returns the network settings of the peer connections
+func (p *ConnectParams) settingEngine() (webrtc.SettingEngine, error) {
+	se := webrtc.SettingEngine{}
+	if p == nil {
+		return se, nil
 	}
-	audioLevelExtension := webrtc.RTPHeaderExtensionCapability{URI: sdp.AudioLevelURI}
-	if err := m.RegisterHeaderExtension(audioLevelExtension, webrtc.RTPCodecTypeAudio); err != nil {
-		return nil, err
+	dialer, err := p.proxyDialer()
+	if err != nil {
+		return se, err
 	}
-	sdesMidExtension := webrtc.RTPHeaderExtensionCapability{URI: sdp.SDESMidURI}
-	if err := m.RegisterHeaderExtension(sdesMidExtension, webrtc.RTPCodecTypeVideo); err != nil {
-		return nil, err
+	if dialer != nil {
+		// only TURN over TCP/TLS can be relayed through the proxy
+		se.SetICEProxyDialer(dialer)
 	}
-	sdesRtpStreamIdExtension := webrtc.RTPHeaderExtensionCapability{URI: sdp.SDESRTPStreamIDURI}
-	if err := m.RegisterHeaderExtension(sdesRtpStreamIdExtension, webrtc.RTPCodecTypeVideo); err != nil {
-		return nil, err
+	if p.UDPPortMin != 0 || p.UDPPortMax != 0 {
+		if err = se.SetEphemeralUDPPortRange(p.UDPPortMin, p.UDPPortMax); err != nil {
+			return se, err
+		}
 	}
-
-	i := &interceptor.Registry{}
-
-	// nack interceptor
-	generator := &sdkinterceptor.NackGeneratorInterceptorFactory{}
-	responder, err := nack.NewResponderInterceptor()
-	if err != nil {
-		return nil, err
+	if len(p.NAT1To1IPs) > 0 {
+		candidateType := p.NAT1To1CandidateType
+		if candidateType == webrtc.ICECandidateType(0) {
+			candidateType = webrtc.ICECandidateTypeHost
+		}
+		se.SetNAT1To1IPs(p.NAT1To1IPs, candidateType)
 	}
-
-	m.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack"}, webrtc.RTPCodecTypeVideo)
-	m.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack", Parameter: "pli"}, webrtc.RTPCodecTypeVideo)
-	i.Add(responder)
-	i.Add(generator)
-
-	// rtcp report interceptor
-	if err := webrtc.ConfigureRTCPReports(i); err != nil {
-		return nil, err
+	if p.InterfaceFilter != nil {
+		se.SetInterfaceFilter(p.InterfaceFilter)
 	}
-
-	// twcc interceptor
-	if err := webrtc.ConfigureTWCCSender(m, i); err != nil {
-		return nil, err
+	if p.IPFilter != nil {
+		se.SetIPFilter(p.IPFilter)
+	}
+	if t := p.ICETimeouts; t != nil {
+		se.SetICETimeouts(durationOrDefault(t.Disconnected, defaultICEDisconnectedTimeout),
+			durationOrDefault(t.Failed, defaultICEFailedTimeout),
+			durationOrDefault(t.KeepAlive, defaultICEKeepAliveInterval))
+	}
+	if p.UDPMux != nil {
+		se.SetICEUDPMux(p.UDPMux)
 	}
+	return se, nil
+}
 
-	se := webrtc.SettingEngine{}
-	se.SetSRTPProtectionProfiles(dtls.SRTP_AEAD_AES_128_GCM, dtls.SRTP_AES128_CM_HMAC_SHA1_80)
+func durationOrDefault(d, defaultValue time.Duration) time.Duration {
+	if d == 0 {
+		return defaultValue
+	}
+	return d
+}
 
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithSettingEngine(se), webrtc.WithInterceptorRegistry(i))
-	pc, err := api.NewPeerConnection(configuration)
+// newPCTransport creates a transport with the network settings of params, or on the shared api of params.
+// subscriber transports only negotiate RED when it's enabled in params
+func newPCTransport(configuration webrtc.Configuration, params *ConnectParams, subscriber bool) (*PCTransport, error) {
+	var api *pcAPI
+	if params != nil {
+		api = params.sharedAPI
+	}
+	if api == nil {
+		var err error
+		if api, err = newPCAPI(params); err != nil {
+			return nil, err
+		}
+	}
+	pc, ti, err := api.newPeerConnection(configuration, subscriber)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +123,13 @@ func NewPCTransport(configuration webrtc.Configuration) (*PCTransport, error) {
 	t := &PCTransport{
 		pc:                 pc,
 		debouncedNegotiate: debounce.New(negotiationFrequency),
-		nackGenerator:      generator,
+		nackGenerator:      ti.nackGenerator,
+		statsGetter:        ti.statsGetter,
+		frameCounter:       ti.frameCounter,
+		audioLevels:        ti.audioLevels,
+	}
+	for i := range t.statsBaselines {
+		t.statsBaselines[i].streams = make(map[uint32]statsSample)
 	}
 
 	pc.OnICEGatheringStateChange(t.onICEGatheringStateChange)
@@ -150,11 +187,109 @@ func (t *PCTransport) Close() error {
 }
 
 func (t *PCTransport) SetRTT(rtt uint32) {
+	t.rtt.Store(rtt)
 	if g := t.nackGenerator; g != nil {
 		g.SetRTT(rtt)
 	}
 }
 
+// GetStats returns a snapshot of the connection stats, rates are computed since the previous call
+func (t *PCTransport) GetStats() *TransportStats {
+	return t.getStats(statsCallerAPI)
+}
+
+func (t *PCTransport) getStats(caller statsCaller) *TransportStats {
+	now := time.Now()
+	ts := &TransportStats{
+		Timestamp:     now,
+		RoundTripTime: time.Duration(t.rtt.Load()) * time.Millisecond,
+	}
+	if s, ok := t.pc.GetStats()["iceTransport"].(webrtc.TransportStats); ok {
+		ts.BytesSent = s.BytesSent
+		ts.BytesReceived = s.BytesReceived
+	}
+	if sctp := t.pc.SCTP(); sctp != nil {
+		if pair, err := sctp.Transport().ICETransport().GetSelectedCandidatePair(); err == nil {
+			ts.SelectedCandidatePair = pair
+		}
+	}
+
+	sent := statsSample{at: now, bytes: ts.BytesSent}
+	received := statsSample{at: now, bytes: ts.BytesReceived}
+	t.statsLock.Lock()
+	baseline := &t.statsBaselines[caller]
+	ts.SendBitrate = bitrate(baseline.sent, sent)
+	ts.ReceiveBitrate = bitrate(baseline.received, received)
+	baseline.sent, baseline.received = sent, received
+	t.statsLock.Unlock()
+
+	return ts
+}
+
+// streamStats returns a snapshot of the RTP stats of a stream, nil if no packets went through it yet
+func (t *PCTransport) streamStats(ssrc uint32, clockRate uint32, outbound bool, caller statsCaller) *TrackStats {
+	if t.statsGetter == nil {
+		return nil
+	}
+	s := t.statsGetter.Get(ssrc)
+	if s == nil {
+		return nil
+	}
+
+	now := time.Now()
+	ts := &TrackStats{
+		SSRC:      ssrc,
+		Timestamp: now,
+	}
+	if outbound {
+		ts.PacketsSent = s.OutboundRTPStreamStats.PacketsSent
+		ts.BytesSent = s.OutboundRTPStreamStats.BytesSent
+		ts.PacketsLost = s.RemoteInboundRTPStreamStats.PacketsLost
+		ts.Jitter = secondsToDuration(s.RemoteInboundRTPStreamStats.Jitter)
+		ts.NACKCount = s.OutboundRTPStreamStats.NACKCount
+		ts.PLICount = s.OutboundRTPStreamStats.PLICount
+		ts.FIRCount = s.OutboundRTPStreamStats.FIRCount
+		ts.RoundTripTime = s.RemoteInboundRTPStreamStats.RoundTripTime
+	} else {
+		ts.PacketsReceived = s.InboundRTPStreamStats.PacketsReceived
+		ts.BytesReceived = s.InboundRTPStreamStats.BytesReceived
+		ts.PacketsLost = s.InboundRTPStreamStats.PacketsLost
+		if clockRate > 0 {
+			// inbound jitter is kept in RTP timestamp units
+			ts.Jitter = secondsToDuration(s.InboundRTPStreamStats.Jitter / float64(clockRate))
+		}
+		ts.NACKCount = s.InboundRTPStreamStats.NACKCount
+		ts.PLICount = s.InboundRTPStreamStats.PLICount
+		ts.FIRCount = s.InboundRTPStreamStats.FIRCount
+		ts.RoundTripTime = s.RemoteOutboundRTPStreamStats.RoundTripTime
+	}
+	if ts.RoundTripTime == 0 {
+		ts.RoundTripTime = time.Duration(t.rtt.Load()) * time.Millisecond
+	}
+
+	cur := statsSample{at: now, bytes: ts.BytesSent + ts.BytesReceived}
+	cur.frames, _ = t.frameCounter.Frames(ssrc)
+
+	t.statsLock.Lock()
+	streams := t.statsBaselines[caller].streams
+	prev := streams[ssrc]
+	streams[ssrc] = cur
+	t.statsLock.Unlock()
+
+	ts.Bitrate = bitrate(prev, cur)
+	ts.FramesPerSecond = frameRate(prev, cur)
+	return ts
+}
+
+// removeStreamStats forgets the previous snapshots of a stream once its track is gone
+func (t *PCTransport) removeStreamStats(ssrc uint32) {
+	t.statsLock.Lock()
+	for i := range t.statsBaselines {
+		delete(t.statsBaselines[i].streams, ssrc)
+	}
+	t.statsLock.Unlock()
+}
+
 func (t *PCTransport) SetRemoteDescription(sd webrtc.SessionDescription) error {
 	t.lock.Lock()
 
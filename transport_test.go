@@ -0,0 +1,176 @@
+package live_sdk_go
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/liuhailove/live-sdk-go/pkg/testserver"
+)
+
+// trickledCandidates returns the ICE candidates the client sent to the server
+func trickledCandidates(t *testing.T, sess *testserver.Session) []ice.Candidate {
+	var candidates []ice.Candidate
+	for _, req := range sess.Requests() {
+		if trickle := req.GetTrickle(); trickle != nil {
+			c, err := ice.UnmarshalCandidate(FromProtoTrickle(trickle).Candidate)
+			require.NoError(t, err)
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+func TestICESettings(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	room, sess := connectTestRoom(t, srv, "bot", nil,
+		WithUDPPortRange(40000, 40100),
+		WithNAT1To1IPs([]string{"203.0.113.7"}, webrtc.ICECandidateTypeSrflx),
+		WithICETimeouts(ICETimeouts{Disconnected: 2 * time.Second}),
+	)
+	defer room.Disconnect()
+
+	candidates := trickledCandidates(t, sess)
+	require.NotEmpty(t, candidates)
+	mapped := false
+	for _, c := range candidates {
+		if c.NetworkType().IsUDP() {
+			require.GreaterOrEqual(t, c.Port(), 40000)
+			require.LessOrEqual(t, c.Port(), 40100)
+		}
+		if c.Address() == "203.0.113.7" {
+			require.Equal(t, ice.CandidateTypeServerReflexive, c.Type())
+			mapped = true
+		}
+	}
+	require.True(t, mapped)
+
+	_, err := ConnectToRoomWithToken(srv.URL(), srv.Token("test-room", "bot2"), nil, WithUDPPortRange(100, 10))
+	require.Error(t, err)
+}
+
+func TestUDPMux(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	require.NoError(t, err)
+	mux := ice.NewUDPMuxDefault(ice.UDPMuxParams{UDPConn: conn})
+	defer mux.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// both rooms share the socket
+	for _, identity := range []string{"alice", "bob"} {
+		room, sess := connectTestRoom(t, srv, identity, nil, WithUDPMux(mux))
+		defer room.Disconnect()
+
+		hostCandidates := 0
+		for _, c := range trickledCandidates(t, sess) {
+			if c.NetworkType().IsUDP() && c.Type() == ice.CandidateTypeHost {
+				require.Equal(t, port, c.Port())
+				hostCandidates++
+			}
+		}
+		require.NotZero(t, hostCandidates)
+	}
+}
+
+// packetCounter counts the RTP packets sent by all its interceptors
+type packetCounter struct {
+	interceptors atomic.Int32
+	packets      atomic.Int32
+}
+
+func (c *packetCounter) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	c.interceptors.Inc()
+	return &packetCounterInterceptor{counter: c}, nil
+}
+
+type packetCounterInterceptor struct {
+	interceptor.NoOp
+	counter *packetCounter
+}
+
+func (i *packetCounterInterceptor) BindLocalStream(_ *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		i.counter.packets.Inc()
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func TestCustomInterceptorsAndCodecs(t *testing.T) {
+	srv := testserver.New()
+	defer srv.Close()
+
+	counter := &packetCounter{}
+	room, sess := connectTestRoom(t, srv, "bot", nil,
+		WithInterceptors(counter),
+		WithCodecs(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "video/x-custom", ClockRate: 90000},
+			PayloadType:        120,
+		}),
+		WithHeaderExtensions(HeaderExtension{
+			Capability: webrtc.RTPHeaderExtensionCapability{URI: "urn:example:custom-ext"},
+			Kind:       webrtc.RTPCodecTypeVideo,
+		}),
+	)
+	defer room.Disconnect()
+	// publisher and subscriber
+	require.EqualValues(t, 2, counter.interceptors.Load())
+
+	track, err := NewLocalSampleTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000})
+	require.NoError(t, err)
+	require.NoError(t, track.StartWrite(NewNullSampleProvider(100000), nil))
+	_, err = room.LocalParticipant.PublishTrack(track, &TrackPublicationOptions{Name: "camera"})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return counter.packets.Load() > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	var offer string
+	for _, req := range sess.Requests() {
+		if o := req.GetOffer(); o != nil && strings.Contains(o.Sdp, "m=video") {
+			offer = o.Sdp
+		}
+	}
+	require.Contains(t, offer, "x-custom/90000")
+	require.Contains(t, offer, "urn:example:custom-ext")
+}
+
+func TestSubscriberRED(t *testing.T) {
+	audioOffer := func(api *pcAPI, subscriber bool) string {
+		pc, _, err := api.newPeerConnection(webrtc.Configuration{}, subscriber)
+		require.NoError(t, err)
+		defer pc.Close()
+		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+		require.NoError(t, err)
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		return offer.SDP
+	}
+
+	// publishers always negotiate RED, subscribers only when enabled
+	for _, receiveRED := range []bool{false, true} {
+		api, err := newPCAPI(&ConnectParams{ReceiveRED: receiveRED})
+		require.NoError(t, err)
+
+		offer := audioOffer(api, false)
+		require.Contains(t, offer, "red/48000")
+		require.Contains(t, offer, "transport-cc")
+
+		offer = audioOffer(api, true)
+		require.Equal(t, receiveRED, strings.Contains(offer, "red/48000"))
+		require.Contains(t, offer, "transport-cc")
+	}
+}